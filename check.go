@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxURLChecks bounds the URLs checked at the same time
+const maxURLChecks = 8
+
+// linkProblem is a broken link or an unused reference definition
+type linkProblem struct {
+	File    string
+	Line    int // 0-based
+	Col     int // 0-based byte column
+	Message string
+}
+
+func (p linkProblem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line+1, p.Col+1, p.Message)
+}
+
+// linkChecker checks the links of Markdown documents. It remembers the
+// files it reads for anchors and the URLs it checks, so a run over many
+// documents reads and fetches each only once.
+type linkChecker struct {
+	external bool // check http(s) URLs over the network
+	client   *http.Client
+
+	docs map[string][]string // Markdown files read to check anchors in them
+	mu   sync.Mutex
+	urls map[string]string // the problem with each URL checked, "" if none
+}
+
+func newLinkChecker(external bool, timeout time.Duration) *linkChecker {
+	return &linkChecker{
+		external: external,
+		client:   &http.Client{Timeout: timeout},
+		docs:     make(map[string][]string),
+		urls:     make(map[string]string),
+	}
+}
+
+// check reports the broken links in content, the text of file: relative
+// files that don't exist, anchors without a heading, references without a
+// definition and definitions nothing refers to
+func (c *linkChecker) check(content []string, file string) []linkProblem {
+	doc := newDocStructure()
+	doc.update(content)
+	defs := linkDefinitions(content, doc)
+
+	var problems []linkProblem
+	report := func(link mdLink, format string, args ...any) {
+		problems = append(problems, linkProblem{File: file, Line: link.line, Col: link.start, Message: fmt.Sprintf(format, args...)})
+	}
+
+	used := make(map[string]bool)
+	var urls []mdLink
+	for i, line := range content {
+		if inCodeOrFrontMatter(doc, i) || !strings.ContainsAny(line, "[<:") {
+			continue
+		}
+		for _, link := range lineLinks(line, i, defs) {
+			// A reference's destination is checked at its definition
+			if link.ref != "" && !link.def {
+				used[normalizeLabel(link.ref)] = true
+				if link.target == "" {
+					report(link, "undefined reference [%s]", link.ref)
+				}
+				continue
+			}
+
+			dest := resolveLink(link.target, file)
+			path, _, _ := strings.Cut(link.target, "#")
+			switch {
+			case dest.url != "":
+				if c.external && (strings.HasPrefix(dest.url, "http://") || strings.HasPrefix(dest.url, "https://")) {
+					urls = append(urls, link)
+				}
+
+			case dest.file == "" || sameFile(dest.file, file):
+				if _, ok := anchorLine(content, dest.anchor); dest.anchor != "" && !ok {
+					report(link, "no heading #%s", dest.anchor)
+				}
+
+			default:
+				info, err := os.Stat(dest.file)
+				if err != nil {
+					report(link, "file not found: %s", path)
+					break
+				}
+				if dest.anchor == "" || info.IsDir() || !isMarkdownFile(dest.file) {
+					break
+				}
+				if _, ok := anchorLine(c.document(dest.file), dest.anchor); !ok {
+					report(link, "no heading #%s in %s", dest.anchor, path)
+				}
+			}
+		}
+	}
+
+	for label, def := range defs {
+		if !used[label] {
+			problems = append(problems, linkProblem{File: file, Line: def.line, Message: fmt.Sprintf("unused definition [%s]", label)})
+		}
+	}
+
+	c.checkURLs(urls)
+	for _, link := range urls {
+		if problem := c.urls[link.target]; problem != "" {
+			report(link, "%s: %s", link.target, problem)
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b linkProblem) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Col - b.Col
+	})
+	return problems
+}
+
+// document returns the lines of a Markdown file, reading it on first use.
+// A file that can't be read has no headings.
+func (c *linkChecker) document(path string) []string {
+	if lines, ok := c.docs[path]; ok {
+		return lines
+	}
+	var lines []string
+	if data, err := os.ReadFile(path); err == nil {
+		lines = strings.Split(string(data), "\n")
+	}
+	c.docs[path] = lines
+	return lines
+}
+
+// checkURLs requests the URLs of links not checked yet, a few at a time
+func (c *linkChecker) checkURLs(links []mdLink) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxURLChecks)
+	for _, link := range links {
+		c.mu.Lock()
+		_, seen := c.urls[link.target]
+		c.urls[link.target] = ""
+		c.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			problem := c.checkURL(target)
+			<-slots
+
+			c.mu.Lock()
+			c.urls[target] = problem
+			c.mu.Unlock()
+		}(link.target)
+	}
+	wg.Wait()
+}
+
+// checkURL requests url and describes what is wrong with the response, if
+// anything. Servers that refuse HEAD requests are asked with GET instead.
+func (c *linkChecker) checkURL(target string) string {
+	resp, err := c.client.Head(target)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusForbidden) {
+		resp.Body.Close()
+		resp, err = c.client.Get(target)
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err // without the method and URL, which the report shows
+	}
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.Status
+	}
+	return ""
+}
+
+// runCheck implements `hani check`, which reports broken links in Markdown
+// files and exits with status 1 if it finds any
+func runCheck(args []string) int {
+	fs := newSubcommandFlags("check", "hani check [--external] [--timeout D] FILE|DIR...")
+	external := fs.Bool("external", false, "also request http(s) URLs and report the ones that fail")
+	timeout := fs.Duration("timeout", 10*time.Second, "time limit for each URL request")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	// Directories stand for the Markdown files under them
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			found, err := markdownFiles(path)
+			if err != nil {
+				return fatalf("%v", err)
+			}
+			files = append(files, found...)
+			continue
+		}
+		files = append(files, path)
+	}
+
+	checker := newLinkChecker(*external, *timeout)
+	status := 0
+	count := 0
+	for _, file := range files {
+		source, err := readDocument(file)
+		if err != nil {
+			status = fatalf("%v", err)
+			continue
+		}
+		for _, problem := range checker.check(strings.Split(string(source), "\n"), file) {
+			fmt.Println(problem)
+			count++
+		}
+	}
+
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "hani: %d link problems (%d files checked)\n", count, len(files))
+		status = 1
+	}
+	return status
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// subcommands maps the first command-line argument onto a non-interactive tool.
+// Both entry points consult this table before starting an editor.
+var subcommands = map[string]func(args []string) int{
+	"render": runRender,
+	"export": runExport,
+	"serve":  runServe,
+	"toc":    runTOC,
+	"check":  runCheck,
+	"lint":   runLint,
+	"fmt":    runFmt,
+	"stats":  runStats,
+}
+
+// runSubcommand runs the subcommand named by args[0], if there is one.
+// It returns the exit code and whether a subcommand matched.
+func runSubcommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	run, ok := subcommands[args[0]]
+	if !ok {
+		return 0, false
+	}
+
+	return run(args[1:]), true
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments, so `hani render FILE --width 60` works like the usual order.
+// A "--" argument ends flag parsing; everything after it is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// newSubcommandFlags creates a flag set whose usage text starts with the
+// given synopsis line, e.g. "hani render [flags] FILE|-".
+func newSubcommandFlags(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// flagExitCode converts a flag parsing error into a process exit code
+func flagExitCode(err error) int {
+	if err == flag.ErrHelp {
+		return 0
+	}
+	return 2
+}
+
+// fatalf prints a subcommand error to stderr and returns exit code 1
+func fatalf(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, "hani: "+format+"\n", args...)
+	return 1
+}
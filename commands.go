@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// handleCommandMode edits the ":" command line
+func (m Model) handleCommandMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = ModeNormal
+		m.commandLine = ""
+		return m, nil
+
+	case "enter":
+		line := m.commandLine
+		m.mode = ModeNormal
+		m.commandLine = ""
+		return m.executeCommand(line)
+
+	case "backspace":
+		if m.commandLine == "" {
+			m.mode = ModeNormal
+			return m, nil
+		}
+		runes := []rune(m.commandLine)
+		m.commandLine = string(runes[:len(runes)-1])
+		return m, nil
+	}
+
+	if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+		m.commandLine += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// executeCommand runs a command entered on the ":" command line
+func (m Model) executeCommand(line string) (tea.Model, tea.Cmd) {
+	cmd := parseExCommand(line)
+
+	switch cmd.name {
+	case "":
+		return m, nil
+
+	case "w", "write":
+		if strings.HasPrefix(cmd.rest, "!") {
+			return m.writeFilter(strings.TrimSpace(cmd.rest[1:]))
+		}
+		if len(cmd.args) > 0 {
+			m.filename = cmd.args[0]
+		}
+		return m.writeBuffer(cmd.bang)
+
+	case "q", "quit":
+		if !m.saved && !cmd.bang {
+			m.setStatusMsg("No write since last change (add ! to override)", true)
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "wq", "x":
+		model, _ := m.writeBuffer(cmd.bang)
+		m = model.(Model)
+		if !m.saved {
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "export":
+		return m.exportCommand(cmd.args)
+
+	case "serve":
+		return m.serveCommand(cmd.args)
+
+	case "set", "se":
+		return m.setCommand(cmd.args)
+
+	case "outline":
+		return m.toggleOutline()
+
+	case "zen":
+		return m.toggleZen()
+
+	case "focus":
+		return m.focusCommand(cmd.args)
+
+	case "spellgood", "spellgo":
+		return m.goodWord(cmd.args)
+
+	case "snippets":
+		return m.snippetsCommand()
+
+	case "ys", "cs", "ds":
+		return m.surroundCommand(cmd.name, cmd.args)
+
+	case "bold", "italic", "code":
+		return m.styleCommand(cmd.name)
+
+	case "link":
+		return m.linkCommand(cmd.args)
+
+	case "meta":
+		return m.metaCommand(cmd.rest)
+
+	case "toc":
+		return m.tocCommand(cmd.args)
+
+	case "table":
+		return m.tableCommand(cmd.args)
+
+	case "renumber":
+		return m.renumberCommand()
+
+	case "tasks":
+		return m.openTaskView(cmd.args)
+
+	case "checklinks":
+		return m.checkLinksCommand(cmd.bang)
+
+	case "stats":
+		return m.statsCommand()
+
+	case "goal":
+		return m.goalCommand(cmd.args)
+
+	case "goals":
+		return m.goalsCommand()
+
+	case "lint":
+		return m.lintCommand()
+
+	case "Format", "format":
+		return m.formatCommand(cmd)
+	}
+
+	m.setStatusMsg("Not an editor command: "+cmd.name, true)
+	return m, nil
+}
+
+// tocCommand implements ":toc [MIN [MAX]]"
+func (m Model) tocCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+
+	content, row, status, err := tocCommand(args, m.content, m.cursor.row)
+	if err != nil {
+		m.setStatusMsg("TOC: "+err.Error(), true)
+		return m, nil
+	}
+	m.content = content
+	m.cursor = Position{row: row, col: 0}
+	m.saved = false
+	m.adjustViewport()
+	m.setStatusMsg(status, false)
+	return m, nil
+}
+
+// formatCommand implements ":[range]Format", which formats the buffer, or
+// the lines in range, in the house style
+func (m Model) formatCommand(cmd exCommand) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+	from, to, err := cmd.lines(m.cursor.row, len(m.content))
+	if err != nil {
+		m.setStatusMsg("Format: "+err.Error(), true)
+		return m, nil
+	}
+	if m.formatBuffer(from, to) {
+		m.setStatusMsg(fmt.Sprintf("Formatted lines %d-%d", from+1, to+1), false)
+	} else {
+		m.setStatusMsg("Already formatted", false)
+	}
+	return m, nil
+}
+
+// formatBuffer formats lines from to to and reports whether that changed
+// anything. The cursor stays with the text it was on.
+func (m *Model) formatBuffer(from, to int) bool {
+	content, cursor := formatMarkdown(m.content, m.cursor, from, to, formatOptionsFor(m.config, m.lint))
+	if slices.Equal(content, m.content) {
+		return false
+	}
+	m.content = content
+	m.cursor = cursor
+	m.saved = false
+	m.adjustViewport()
+	return true
+}
+
+// reflowCommand implements gq{motion}, which rewraps the lines the motion
+// covers to word_wrap
+func (m Model) reflowCommand(motion string) (tea.Model, tea.Cmd) {
+	from, to, ok := reflowMotion(m.content, m.cursor, motion)
+	switch {
+	case !ok:
+		return m, nil
+	case m.readOnly:
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	case m.config.WordWrap <= 0:
+		m.setStatusMsg("Set word_wrap in the config file to reflow", true)
+		return m, nil
+	}
+
+	content, cursor := reflowLines(m.content, m.cursor, from, to, m.config.WordWrap)
+	if !slices.Equal(content, m.content) {
+		m.content = content
+		m.saved = false
+	}
+	m.cursor = cursor
+	m.adjustViewport()
+	return m, nil
+}
+
+// surroundKeys implements ys{motion}{char}, cs{from}{to} and ds{char},
+// waiting for keys until the command is complete
+func (m Model) surroundKeys(op, keys string) (tea.Model, tea.Cmd) {
+	motion, chars, pending, ok := parseSurround(op, keys)
+	switch {
+	case pending:
+		m.pendingKey = op + keys
+		return m, nil
+	case !ok:
+		return m, nil
+	}
+	return m.surround(op, motion, chars)
+}
+
+// surroundCommand implements ":ys MOTION CHAR", ":cs FROM TO" and
+// ":ds CHAR", the surround operators as commands
+func (m Model) surroundCommand(op string, args []string) (tea.Model, tea.Cmd) {
+	motion, chars, err := surroundArgs(op, args)
+	if err != nil {
+		m.setStatusMsg("Surround: "+err.Error(), true)
+		return m, nil
+	}
+	return m.surround(op, motion, chars)
+}
+
+// surround carries out a surround operator on the cursor's line
+func (m Model) surround(op, motion string, chars []string) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+	line, col, insert, err := surroundLine(m.content[m.cursor.row], m.cursor.col, op, motion, chars)
+	if err != nil {
+		m.setStatusMsg("Surround: "+err.Error(), true)
+		return m, nil
+	}
+	m.content[m.cursor.row], m.cursor.col = line, col
+	m.saved = false
+	if insert {
+		m.mode = ModeInsert
+	}
+	m.adjustViewport()
+	return m, nil
+}
+
+// styleCommand implements ":bold", ":italic" and ":code", which give the
+// word under the cursor the style or take it away
+func (m Model) styleCommand(style string) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+	line, col, ok := toggleStyle(m.content[m.cursor.row], m.cursor.col, style)
+	if !ok {
+		m.setStatusMsg("No word under the cursor", true)
+		return m, nil
+	}
+	m.content[m.cursor.row], m.cursor.col = line, col
+	m.saved = false
+	return m, nil
+}
+
+// linkCommand implements ":link [URL]", which links the word under the
+// cursor to URL, or to a URL typed next in insert mode, or unlinks the link
+// under the cursor
+func (m Model) linkCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+	line, col, insert, ok := linkWord(m.content[m.cursor.row], m.cursor.col, strings.Join(args, " "))
+	if !ok {
+		m.setStatusMsg("No word under the cursor", true)
+		return m, nil
+	}
+	m.content[m.cursor.row], m.cursor.col = line, col
+	m.saved = false
+	if insert {
+		m.mode = ModeInsert
+	}
+	return m, nil
+}
+
+// tableCommand implements ":table", which edits the table under the cursor
+func (m Model) tableCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+
+	content, cursor, status, err := tableCommand(args, m.content, m.cursor)
+	if err != nil {
+		m.setStatusMsg("Table: "+err.Error(), true)
+		return m, nil
+	}
+	m.setTableContent(content, cursor)
+	m.setStatusMsg(status, false)
+	return m, nil
+}
+
+// renumberCommand implements ":renumber", which fixes ordered list numbering
+func (m Model) renumberCommand() (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+
+	content, changed := renumberLists(m.content)
+	if changed == 0 {
+		m.setStatusMsg("Lists are already numbered in order", false)
+		return m, nil
+	}
+	m.content = content
+	m.saved = false
+	m.setStatusMsg(fmt.Sprintf("Renumbered %d list items", changed), false)
+	return m, nil
+}
+
+// exportCommand implements ":export FORMAT [FILE]"
+func (m Model) exportCommand(args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		m.setStatusMsg("Usage: :export FORMAT [FILE] ("+strings.Join(exportFormats(), ", ")+")", true)
+		return m, nil
+	}
+
+	target := ""
+	if len(args) > 1 {
+		target = args[1]
+	}
+
+	path, err := exportBuffer(args[0], m.content, m.filename, target, ExportOptions{Theme: m.config.Theme, Width: m.config.WordWrap})
+	if err != nil {
+		m.setStatusMsg("Export failed: "+err.Error(), true)
+		return m, nil
+	}
+
+	m.setStatusMsg("Exported "+args[0]+" to "+path, false)
+	return m, nil
+}
+
+// serveCommand implements ":serve [PORT]" and ":serve stop"
+func (m Model) serveCommand(args []string) (tea.Model, tea.Cmd) {
+	if len(args) > 0 && args[0] == "stop" {
+		if m.preview != nil {
+			m.preview.Close()
+			m.preview = nil
+		}
+		m.setStatusMsg("Preview server stopped", false)
+		return m, nil
+	}
+
+	if m.preview == nil {
+		port := 0
+		if len(args) > 0 {
+			var err error
+			if port, err = strconv.Atoi(args[0]); err != nil {
+				m.setStatusMsg("Usage: :serve [PORT] | :serve stop", true)
+				return m, nil
+			}
+		}
+
+		server, err := startPreviewServer(port, m.config.Theme)
+		if err != nil {
+			m.setStatusMsg("Serve failed: "+err.Error(), true)
+			return m, nil
+		}
+		m.preview = server
+		m.preview.Update(m.content, m.filename, m.cursor.row)
+	}
+
+	m.setStatusMsg("Serving preview at "+m.preview.URL(), false)
+	return m, nil
+}
+
+// linkCheckMsg carries the results of ":checklinks"
+type linkCheckMsg struct {
+	file     string
+	problems []linkProblem
+}
+
+// checkLinksCommand implements ":checklinks", which lists the buffer's
+// broken links. ":checklinks!" also requests the http(s) URLs, so the check
+// runs in the background.
+func (m Model) checkLinksCommand(external bool) (tea.Model, tea.Cmd) {
+	content, file := slices.Clone(m.content), m.filename
+	if external {
+		m.setStatusMsg("Checking links and URLs...", false)
+	}
+	return m, func() tea.Msg {
+		checker := newLinkChecker(external, 10*time.Second)
+		return linkCheckMsg{file: file, problems: checker.check(content, file)}
+	}
+}
+
+// showLinkProblems opens the ":checklinks" results in a location list
+func (m Model) showLinkProblems(msg linkCheckMsg) Model {
+	if len(msg.problems) == 0 {
+		m.setStatusMsg("No broken links", false)
+		return m
+	}
+
+	entries := make([]location, len(msg.problems))
+	for i, p := range msg.problems {
+		entries[i] = location{file: p.File, line: p.Line, text: p.Message}
+	}
+	name := msg.file
+	if name == "" {
+		name = "this buffer"
+	}
+	m.tasks = taskView{}
+	m.stats.open = false
+	m.locations = locationList{
+		open:    true,
+		title:   fmt.Sprintf("Link problems in %s: %d", name, len(entries)),
+		entries: entries,
+	}
+	return m
+}
+
+// writeFilterMsg reports the end of a ":w !cmd" command
+type writeFilterMsg struct {
+	cmdline string
+	output  string
+	err     error
+}
+
+// writeFilter implements ":w !cmd", suspending the UI so commands such as
+// sudo can prompt on the terminal
+func (m Model) writeFilter(cmdline string) (tea.Model, tea.Cmd) {
+	if cmdline == "" {
+		m.setStatusMsg("Usage: :w !command (% is the file name)", true)
+		return m, nil
+	}
+	if filterWritesFile(cmdline) && m.filename == "" {
+		m.setStatusMsg("No file name for %", true)
+		return m, nil
+	}
+
+	cmd, output := writeFilterCommand(cmdline, m.filename, m.content)
+	return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return writeFilterMsg{cmdline: cmdline, output: output.String(), err: err}
+	})
+}
+
+// setCommand implements ":set [no]readonly" (":set ro" / ":set noro") and
+// ":set [no]hardwrap"
+func (m Model) setCommand(args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		m.setStatusMsg("Usage: :set [no]readonly | [no]hardwrap | [no]spell", true)
+		return m, nil
+	}
+
+	for _, option := range args {
+		switch option {
+		case "readonly", "ro":
+			m.readOnly = true
+			if m.mode == ModeInsert {
+				m.mode = ModeNormal
+			}
+		case "noreadonly", "noro":
+			m.readOnly = false
+		case "hardwrap", "hw":
+			m.config.HardWrap = true
+		case "nohardwrap", "nohw":
+			m.config.HardWrap = false
+		case "spell":
+			m.config.Spell = true
+			m.loadSpell()
+		case "nospell":
+			m.config.Spell = false
+			m.loadSpell()
+		default:
+			m.setStatusMsg("Unknown option: "+option, true)
+			return m, nil
+		}
+	}
+	return m, nil
+}
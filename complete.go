@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// What is being completed
+const (
+	completeWord   = "word"   // a word from the buffer
+	completePath   = "path"   // a file for a link target
+	completeImage  = "image"  // an image file for an image target
+	completeAnchor = "anchor" // a heading anchor after "#"
+)
+
+// maxCompletions caps the candidates offered at once
+const maxCompletions = 50
+
+var (
+	// completeWordRe matches the words keyword completion offers
+	completeWordRe = regexp.MustCompile(`[\pL\pN_]+(?:['-][\pL\pN_]+)*`)
+
+	// completeDefRe matches the start of a link reference definition, up to
+	// its destination
+	completeDefRe = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:[ \t]*`)
+
+	// imageExtensions are the files an image target is completed with
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".avif", ".bmp"}
+)
+
+// completionContext is what the text before the cursor asks to complete:
+// kind, from byte start of the line, with prefix typed so far. A path's dir
+// is the part of the target before the name; an anchor's file is the part
+// before "#", empty for the buffer itself.
+type completionContext struct {
+	kind   string
+	start  int
+	prefix string
+	dir    string
+	file   string
+}
+
+// completionAt returns the completion context at byte col of line. Inside
+// a link or image target ("](" or a reference definition) it completes file
+// paths, or anchors after "#"; elsewhere the word before the cursor.
+func completionAt(line string, col int) completionContext {
+	col = min(col, len(line))
+	before := line[:col]
+
+	targetStart, kind := -1, completePath
+	if i := strings.LastIndex(before, "]("); i >= 0 && !strings.ContainsAny(before[i+2:], " )") {
+		targetStart = i + 2
+		if open := strings.LastIndex(before[:i], "["); open > 0 && before[open-1] == '!' {
+			kind = completeImage
+		}
+	} else if m := completeDefRe.FindStringIndex(before); m != nil && !strings.ContainsAny(before[m[1]:], " \t") {
+		targetStart = m[1]
+	}
+
+	if targetStart >= 0 {
+		target := strings.TrimPrefix(before[targetStart:], "<")
+		targetStart = col - len(target)
+		if linkSchemeRe.MatchString(target) {
+			return completionContext{}
+		}
+		if file, anchor, ok := strings.Cut(target, "#"); ok {
+			return completionContext{kind: completeAnchor, start: col - len(anchor), prefix: anchor, file: file}
+		}
+		slash := strings.LastIndex(target, "/") + 1
+		return completionContext{kind: kind, start: targetStart + slash, prefix: target[slash:], dir: target[:slash]}
+	}
+
+	start := col
+	for _, m := range completeWordRe.FindAllStringIndex(before, -1) {
+		if m[1] == col {
+			start = m[0]
+		}
+	}
+	return completionContext{kind: completeWord, start: start, prefix: before[start:]}
+}
+
+// autoCompletes reports whether typing key should open the popup by
+// itself: the "(" of a link target, a "/" in one, or the "#" of an anchor
+func autoCompletes(key string, ctx completionContext) bool {
+	switch ctx.kind {
+	case completePath, completeImage:
+		return key == "(" || key == "/"
+	case completeAnchor:
+		return key == "#"
+	}
+	return false
+}
+
+// completionCandidates returns what can complete ctx in content, whose
+// file is docFile, filtered by the prefix typed so far. Words come nearest
+// the cursor first, looking forward from it.
+func completionCandidates(content []string, pos Position, docFile string, ctx completionContext) []string {
+	var candidates []string
+	switch ctx.kind {
+	case completeWord:
+		if ctx.prefix == "" {
+			return nil
+		}
+		candidates = bufferWords(content, pos, ctx)
+	case completePath, completeImage:
+		candidates = pathCandidates(docFile, ctx)
+	case completeAnchor:
+		source := content
+		if ctx.file != "" {
+			path := ctx.file
+			if !filepath.IsAbs(path) && docFile != "" {
+				path = filepath.Join(filepath.Dir(docFile), path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			source = strings.Split(string(data), "\n")
+		}
+		candidates = documentAnchors(source)
+	}
+	return fuzzyFilter(candidates, ctx.prefix)
+}
+
+// bufferWords returns the words of content, starting at the cursor and
+// wrapping around, without the one being typed
+func bufferWords(content []string, pos Position, ctx completionContext) []string {
+	seen := map[string]bool{ctx.prefix: true}
+	var words []string
+	for n := range len(content) + 1 {
+		row := (pos.row + n) % len(content)
+		for _, m := range completeWordRe.FindAllStringIndex(content[row], -1) {
+			if row == pos.row {
+				// The cursor's line comes first after the cursor, and last
+				// before it
+				after := m[0] > ctx.start
+				if m[0] == ctx.start || (n == 0) != after {
+					continue
+				}
+			}
+			word := content[row][m[0]:m[1]]
+			if !seen[word] && strings.Trim(word, "0123456789") != "" {
+				seen[word] = true
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// pathCandidates lists the directory of a link target, relative to the
+// document's own: subdirectories with a "/" after them, then the files, or
+// for an image target only image files
+func pathCandidates(docFile string, ctx completionContext) []string {
+	dir := ctx.dir
+	if !filepath.IsAbs(dir) {
+		base := "."
+		if docFile != "" {
+			base = filepath.Dir(docFile)
+		}
+		dir = filepath.Join(base, dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs, files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(ctx.prefix, ".") {
+			continue
+		}
+		switch {
+		case entry.IsDir():
+			dirs = append(dirs, name+"/")
+		case ctx.kind != completeImage || slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(name))):
+			files = append(files, name)
+		}
+	}
+	return append(dirs, files...)
+}
+
+// documentAnchors returns the anchors of content's headings, as GitHub
+// makes them, and of its <a name> and <a id> tags
+func documentAnchors(content []string) []string {
+	doc := newDocStructure()
+	doc.update(content)
+	slugs := newSlugger()
+	var anchors []string
+	for _, h := range doc.headings {
+		anchors = append(anchors, slugs.slug(h.Text))
+	}
+	for i, line := range content {
+		for _, match := range htmlAnchorRe.FindAllStringSubmatch(line, -1) {
+			if !inCodeOrFrontMatter(doc, i) {
+				anchors = append(anchors, match[1])
+			}
+		}
+	}
+	return anchors
+}
+
+// fuzzyFilter keeps the candidates that have the letters of query in
+// order, best matches first: those starting with query, then those
+// containing it, then the rest, each group in its original order
+func fuzzyFilter(candidates []string, query string) []string {
+	type scored struct {
+		text  string
+		score int
+	}
+	lowerQuery := strings.ToLower(query)
+	var matches []scored
+	for _, c := range candidates {
+		lower := strings.ToLower(c)
+		score := -1
+		switch {
+		case strings.HasPrefix(c, query):
+			score = 0
+		case strings.HasPrefix(lower, lowerQuery):
+			score = 1
+		case strings.Contains(lower, lowerQuery):
+			score = 2
+		case isSubsequence(lower, lowerQuery):
+			score = 3
+		}
+		if score >= 0 && c != query {
+			matches = append(matches, scored{c, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int { return a.score - b.score })
+
+	filtered := make([]string, 0, min(len(matches), maxCompletions))
+	for _, m := range matches[:min(len(matches), maxCompletions)] {
+		filtered = append(filtered, m.text)
+	}
+	return filtered
+}
+
+// isSubsequence reports whether the runes of sub appear in s in order
+func isSubsequence(s, sub string) bool {
+	rest := []rune(sub)
+	for _, r := range s {
+		if len(rest) > 0 && r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
+
+// applyCompletion puts text in place of what ctx was completing, up to the
+// cursor
+func applyCompletion(line string, col int, ctx completionContext, text string) (string, int) {
+	col = min(col, len(line))
+	return line[:ctx.start] + text + line[col:], ctx.start + len(text)
+}
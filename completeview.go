@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// popupRows is the most items a popup shows at once; it scrolls to keep
+// the selection in view
+const popupRows = 10
+
+var popupStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("#7D56F4")).
+	Padding(0, 1)
+
+// completion is the insert mode completion popup. Its candidates complete
+// ctx and are filtered again as typing goes on.
+type completion struct {
+	open  bool
+	ctx   completionContext
+	items []string
+	sel   int
+}
+
+// startCompletion implements Ctrl+N and Ctrl+P, which complete the word,
+// link target or anchor before the cursor. A single candidate is put in
+// place at once.
+func (m Model) startCompletion(dir int) (tea.Model, tea.Cmd) {
+	ctx := completionAt(m.content[m.cursor.row], m.cursor.col)
+	items := completionCandidates(m.content, m.cursor, m.filename, ctx)
+	switch len(items) {
+	case 0:
+		m.setStatusMsg("No completions", false)
+		return m, nil
+	case 1:
+		m.completion = completion{ctx: ctx, items: items}
+		return m.acceptCompletion()
+	}
+	m.completion = completion{open: true, ctx: ctx, items: items}
+	if dir < 0 {
+		m.completion.sel = len(items) - 1
+	}
+	return m, nil
+}
+
+// handleCompletionKey moves the popup's selection, accepts it or closes
+// it with Ctrl+E; ok is false for keys that go on to insert mode, where Esc
+// closes it too
+func (m Model) handleCompletionKey(msg tea.KeyMsg) (tea.Model, bool) {
+	c := &m.completion
+	switch msg.String() {
+	case "ctrl+n", "down":
+		c.sel = (c.sel + 1) % len(c.items)
+	case "ctrl+p", "up":
+		c.sel = (c.sel + len(c.items) - 1) % len(c.items)
+	case "enter", "tab":
+		accepted, _ := m.acceptCompletion()
+		return accepted, true
+	case "ctrl+e":
+		c.open = false
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// acceptCompletion puts the selected candidate in place. A directory keeps
+// the popup open on its contents.
+func (m Model) acceptCompletion() (tea.Model, tea.Cmd) {
+	c := m.completion
+	line := m.content[m.cursor.row]
+	m.content[m.cursor.row], m.cursor.col = applyCompletion(line, m.cursor.col, c.ctx, c.items[c.sel])
+	m.saved = false
+	m.completion = completion{}
+	if strings.HasSuffix(c.items[c.sel], "/") {
+		m.refreshCompletion(true)
+	}
+	m.adjustViewport()
+	return m, nil
+}
+
+// refreshCompletion filters the open popup by what has been typed since it
+// opened, closing it once nothing matches or the cursor leaves the text it
+// completes. open opens it instead, if there is something to offer.
+func (m *Model) refreshCompletion(open bool) {
+	if !m.completion.open && !open {
+		return
+	}
+	ctx := completionAt(m.content[m.cursor.row], m.cursor.col)
+	if !open && (ctx.kind != m.completion.ctx.kind || ctx.start != m.completion.ctx.start) {
+		m.completion = completion{}
+		return
+	}
+	items := completionCandidates(m.content, m.cursor, m.filename, ctx)
+	if len(items) == 0 {
+		m.completion = completion{}
+		return
+	}
+	m.completion = completion{open: true, ctx: ctx, items: items}
+}
+
+// typeWithCompletion handles a key in insert mode, then filters the open
+// popup by it, or opens the popup when the key starts a link target or an
+// anchor
+func (m Model) typeWithCompletion(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.handleInsertMode(msg)
+	m = updated.(Model)
+	if m.mode != ModeInsert {
+		m.completion = completion{}
+		return m, cmd
+	}
+	if key := msg.String(); key != "ctrl+n" && key != "ctrl+p" {
+		ctx := completionAt(m.content[m.cursor.row], m.cursor.col)
+		m.refreshCompletion(autoCompletes(key, ctx))
+	}
+	return m, cmd
+}
+
+// drawPopup draws items in a box over the editor's lines, below the text
+// at byte col of line row or above it when there is no room, showing up to
+// popupRows of them around the selected one
+func (m Model) drawPopup(lines []string, items []string, sel, row, col int) {
+	first := max(0, min(sel-popupRows/2, len(items)-popupRows))
+	shown := items[first:min(len(items), first+popupRows)]
+	width := 0
+	for _, item := range shown {
+		width = max(width, ansi.StringWidth(item))
+	}
+	rows := make([]string, len(shown))
+	for i, item := range shown {
+		rows[i] = item + strings.Repeat(" ", width-ansi.StringWidth(item))
+		if first+i == sel {
+			rows[i] = keyStyle.Reverse(true).Render(rows[i])
+		}
+	}
+	box := strings.Split(popupStyle.Render(strings.Join(rows, "\n")), "\n")
+	boxWidth := lipgloss.Width(box[0])
+
+	top := row - m.viewport.offsetRow + 1
+	if top+len(box) > len(lines) {
+		top = max(0, top-1-len(box))
+	}
+	line := m.content[row]
+	left := m.gutterWidth()
+	if start := min(col, len(line)); start > m.viewport.offsetCol {
+		left += ansi.StringWidth(line[m.viewport.offsetCol:start])
+	}
+	left = max(0, min(left, m.editorWidth()-boxWidth))
+
+	for k, boxLine := range box {
+		if top+k >= len(lines) {
+			break
+		}
+		base := lines[top+k]
+		before := ansi.Truncate(base, left, "")
+		before += strings.Repeat(" ", max(0, left-ansi.StringWidth(before)))
+		lines[top+k] = before + boxLine + ansi.TruncateLeft(base, left+boxWidth, "")
+	}
+}
+
+// drawCompletion draws the completion popup under the text it completes
+func (m Model) drawCompletion(lines []string) {
+	c := m.completion
+	m.drawPopup(lines, c.items, c.sel, m.cursor.row, c.ctx.start)
+}
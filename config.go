@@ -2,10 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/charmbracelet/glamour"
 )
 
+// DefaultWordWrap is the default wrap width for rendered markdown
+const DefaultWordWrap = 80
+
 // Config holds user configuration settings
 type Config struct {
 	// Editor settings
@@ -20,6 +26,41 @@ type Config struct {
 	// Behavior settings
 	AutoSave  bool `json:"auto_save"`
 	BlinkRate int  `json:"cursor_blink_rate_ms"`
+
+	// Opener runs URLs and non-Markdown files that links point to, with the
+	// target as its last argument; empty picks open or xdg-open
+	Opener string `json:"opener"`
+
+	// Task settings
+	TaskDoneDate bool `json:"task_done_date"` // stamp "✅ YYYY-MM-DD" on completed tasks
+
+	// Formatter settings (:Format and hani fmt)
+	FormatOnSave bool `json:"format_on_save"`
+	FormatWrap   bool `json:"format_wrap"` // reflow paragraphs to word_wrap
+
+	// HardWrap breaks lines at word_wrap while typing, like vim's textwidth
+	HardWrap bool `json:"hard_wrap"`
+
+	// Zen mode settings: start in zen mode, the width of its text column
+	// (0 uses word_wrap) and what the focus dim leaves bright ("paragraph",
+	// "sentence" or "" for no dimming)
+	Zen      bool   `json:"zen"`
+	ZenWidth int    `json:"zen_width"`
+	ZenFocus string `json:"zen_focus"`
+
+	// Spell checking: on or off, the Hunspell dictionary's language, and a
+	// .dic file to use instead of looking for one
+	Spell     bool   `json:"spell"`
+	SpellLang string `json:"spell_lang"`
+	SpellDict string `json:"spell_dict"`
+
+	// AutoPairs are the characters that typing pairs with their closing
+	// character in insert mode, out of ([{<`*_~"'; "" turns pairing off
+	AutoPairs string `json:"auto_pairs"`
+
+	// FrontMatterCard shows the front matter's fields as a table at the top
+	// of the preview; off, the preview leaves the front matter out
+	FrontMatterCard bool `json:"front_matter_card"`
 }
 
 // DefaultConfig returns the default configuration
@@ -32,6 +73,26 @@ func DefaultConfig() Config {
 		DarkMode:    true,
 		AutoSave:    false,
 		BlinkRate:   500,
+
+		Opener:       "",
+		TaskDoneDate: false,
+
+		FormatOnSave: false,
+		FormatWrap:   false,
+
+		HardWrap: false,
+
+		Zen:      false,
+		ZenWidth: 0,
+		ZenFocus: "",
+
+		Spell:     false,
+		SpellLang: "en_US",
+		SpellDict: "",
+
+		AutoPairs: DefaultAutoPairs,
+
+		FrontMatterCard: true,
 	}
 }
 
@@ -68,6 +129,21 @@ func LoadConfig() Config {
 	return config
 }
 
+// LoadConfigFile loads configuration from the given path. Unlike LoadConfig,
+// a missing or invalid file is an error, since the user asked for it by name.
+func LoadConfigFile(path string) (Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
 // SaveConfig saves the configuration to the user's home directory
 func SaveConfig(config Config) error {
 	homeDir, err := os.UserHomeDir()
@@ -89,3 +165,12 @@ func SaveConfig(config Config) error {
 
 	return os.WriteFile(configPath, data, 0644)
 }
+
+// glamourStyleOption maps a theme name or JSON style path onto a glamour option.
+// An empty theme or "auto" picks a dark or light style from the terminal.
+func glamourStyleOption(theme string) glamour.TermRendererOption {
+	if theme == "" || theme == "auto" {
+		return glamour.WithAutoStyle()
+	}
+	return glamour.WithStylePath(theme)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	lintSignStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFB86C"))
+
+	lintUnderlineStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FFB86C")).
+				Underline(true)
+)
+
+// lintSign marks the lines with diagnostics in the gutter
+const lintSign = "●"
+
+// loadLint reads the lint configuration for the buffer's file and lints it
+func (m *Model) loadLint() {
+	config, err := loadLintConfig(m.filename, m.config.WordWrap)
+	if err != nil {
+		m.setStatusMsg("Lint: "+err.Error(), true)
+	}
+	m.lint = config
+	m.relint()
+}
+
+// relint brings the diagnostics in line with the buffer
+func (m *Model) relint() {
+	m.diagnostics = lintStructure(m.content, m.doc, m.filename, m.lint)
+}
+
+// gutterWidth is the width of the sign column, shown while there are
+// diagnostics
+func (m Model) gutterWidth() int {
+	if len(m.diagnostics) == 0 {
+		return 0
+	}
+	return 2
+}
+
+// gutter returns the sign column for line
+func (m Model) gutter(line int) string {
+	if m.gutterWidth() == 0 {
+		return ""
+	}
+	for _, d := range m.diagnostics {
+		if d.Line == line {
+			return lintSignStyle.Render(lintSign) + " "
+		}
+	}
+	return "  "
+}
+
+// cursorDiagnostic returns the diagnostic the status bar shows: the one
+// under the cursor, or else the first on the cursor's line
+func (m Model) cursorDiagnostic() (lintDiagnostic, bool) {
+	found := lineDiagnostics(m.diagnostics, m.cursor.row)
+	if len(found) == 0 {
+		return lintDiagnostic{}, false
+	}
+	for _, d := range found {
+		if d.Col <= m.cursor.col && m.cursor.col < max(d.End, d.Col+1) {
+			return d, true
+		}
+	}
+	return found[0], true
+}
+
+// gotoDiagnostic implements ]d and [d
+func (m Model) gotoDiagnostic(dir int) (tea.Model, tea.Cmd) {
+	d, ok := nextDiagnostic(m.diagnostics, m.cursor, dir)
+	if !ok {
+		m.setStatusMsg("No lint problems", false)
+		return m, nil
+	}
+	m.cursor = Position{row: d.Line, col: d.Col}
+	m.adjustViewport()
+	return m, nil
+}
+
+// lintCommand implements ":lint", which lists the buffer's diagnostics
+func (m Model) lintCommand() (tea.Model, tea.Cmd) {
+	m.loadLint()
+	if len(m.diagnostics) == 0 {
+		m.setStatusMsg("No lint problems", false)
+		return m, nil
+	}
+
+	entries := make([]location, len(m.diagnostics))
+	for i, d := range m.diagnostics {
+		entries[i] = location{line: d.Line, text: d.Rule + " " + d.Message}
+	}
+	name := m.filename
+	if name == "" {
+		name = "this buffer"
+	}
+	m.tasks = taskView{}
+	m.stats.open = false
+	m.locations = locationList{
+		open:    true,
+		title:   fmt.Sprintf("Lint problems in %s: %d", name, len(entries)),
+		entries: entries,
+	}
+	return m, nil
+}
+
+// underlineSpans draws line with the byte ranges in spans underlined as
+// lint problems, those in misspelled as misspellings, and the cursor block
+// before byte cursor (-1 for no cursor)
+func underlineSpans(line string, spans, misspelled [][2]int, cursor int) string {
+	in := func(list [][2]int, i int) bool {
+		for _, span := range list {
+			if span[0] <= i && i < span[1] {
+				return true
+			}
+		}
+		return false
+	}
+	styles := []*lipgloss.Style{nil, &lintUnderlineStyle, &spellUnderlineStyle}
+	under := func(i int) int {
+		switch {
+		case in(spans, i):
+			return 1
+		case in(misspelled, i):
+			return 2
+		}
+		return 0
+	}
+
+	var b strings.Builder
+	var run strings.Builder
+	runUnder := 0
+	flush := func() {
+		if style := styles[runUnder]; style != nil {
+			b.WriteString(style.Render(run.String()))
+		} else {
+			b.WriteString(run.String())
+		}
+		run.Reset()
+	}
+
+	for i, r := range line {
+		if i == cursor {
+			flush()
+			b.WriteString("█")
+		}
+		if u := under(i); u != runUnder {
+			flush()
+			runUnder = u
+		}
+		run.WriteRune(r)
+	}
+	flush()
+	if cursor >= len(line) {
+		b.WriteString("█")
+	}
+	return b.String()
+}
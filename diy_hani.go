@@ -7,12 +7,16 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 	"unsafe"
 
+	"github.com/alecthomas/chroma/v2/quick"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/x/ansi"
 	"golang.org/x/term"
 )
 
@@ -22,6 +26,7 @@ type Mode int
 const (
 	ModeNormal Mode = iota
 	ModeInsert
+	ModeCommand
 )
 
 // Tab types
@@ -32,6 +37,9 @@ const (
 	TabPreview
 )
 
+// outlineHelp is empty: the heading outline (gO) is Bubbletea only
+const outlineHelp = ""
+
 // Position represents cursor/viewport position
 type Position struct {
 	row, col int
@@ -65,10 +73,74 @@ type DIYEditor struct {
 	// Status
 	statusMsg     string
 	statusExpiry  time.Time
+
+	// Command line (":" commands) and user settings
+	commandLine string
+	config      Config
+
+	// Browser preview started by :serve
+	preview *previewServer
+
+	// --pipe: writing sends the buffer to stdout on exit
+	pipe       bool
+	pipeOutput []string
+
+	// Whether the buffer may be written, and the command-line options -R
+	// (every file opens read-only) and --split
+	readOnly     bool
+	readOnlyFlag bool
+	split        bool
+
+	// Followed links: where they were followed from (Ctrl+O goes back), and
+	// the link selected in the preview, counting from 1
+	jumps       []jump
+	previewLink int
+
+	// Lint rules for the file, and what they found
+	lint        lintConfig
+	diagnostics []lintDiagnostic
+
+	// Words in the buffer, and the writing goal with the words the buffer
+	// had when it was opened and when the history last heard of it
+	counts    textCounts
+	goal      writingGoal
+	goalStart int
+	recorded  int
+
+	// doc indexes the buffer's structure; Render brings it up to date and
+	// rechecks the buffer only when something changed
+	doc *docStructure
+
+	// Zen mode, and what its focus dim leaves bright
+	zen   bool
+	focus string
+
+	// Spell checking (nil while off) and the misspellings in the buffer
+	spell        *spellChecker
+	misspellings []misspelling
+	spellPopup   spellPopup
+
+	// The list :tasks, :checklinks and :stats show over the editor
+	picker picker
+
+	// Insert mode completion: the candidates in the popup (nil while it is
+	// closed) for what completing asks, and the selected one
+	completing  completionContext
+	completions []string
+	completion  int
+
+	// Snippets for the file type, and the one whose fields Tab visits
+	snippets []snippet
+	snippet  *snippetSession
+
+	// The front matter's lines, highlighted, and what is wrong with it
+	frontMatter    []string
+	frontMatterErr error
 }
 
+
 // NewDIYEditor creates a new DIY editor
-func NewDIYEditor(filename string) (*DIYEditor, error) {
+func NewDIYEditor(filename string, config Config) (*DIYEditor, error) {
 	// Get terminal size
 	width, height, err := getTerminalSize()
 	if err != nil {
@@ -97,21 +169,62 @@ func NewDIYEditor(filename string) (*DIYEditor, error) {
 		content = []string{""}
 	}
 
-	// Create glamour renderer for preview with syntax highlighting
-	// Try different styles for best syntax highlighting
-	var renderer *glamour.TermRenderer
+	editor := &DIYEditor{
+		content:   content,
+		cursor:    Position{0, 0},
+		viewport:  Viewport{0, 0},
+		mode:      ModeNormal,
+		activeTab: TabEditor,
+		saved:     true,
+		filename:  filename,
+		width:     width,
+		height:    height,
+		oldState:  oldState,
+		config:    config,
+		doc:       newDocStructure(),
+	}
+	editor.createRenderer()
+	editor.zen = config.Zen
+	if focus, err := parseFocus(config.ZenFocus); err == nil {
+		editor.focus = focus
+	} else {
+		editor.setStatus("Config: zen_focus: " + err.Error())
+	}
+	editor.doc.update(editor.content)
+	editor.loadLint()
+	editor.loadSpell()
+	editor.loadSnippets()
+	editor.checkFrontMatter()
+	editor.recount()
+	editor.startWriting()
+
+	// Set up signal handling for cleanup
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		editor.Cleanup()
+		os.Exit(0)
+	}()
+
+	return editor, nil
+}
+
+// createRenderer builds the glamour renderer for the preview's current width
+func (e *DIYEditor) createRenderer() {
+	wrap := e.previewWidth() - 4 // Leave some margin
 
-	// Try auto style first (adapts to terminal)
-	renderer, err = glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
-		glamour.WithWordWrap(width-4), // Leave some margin
+	// Try the configured theme first (auto adapts to the terminal)
+	renderer, err := glamour.NewTermRenderer(
+		glamourStyleOption(e.config.Theme),
+		glamour.WithWordWrap(wrap),
 	)
 
 	if err != nil {
 		// Try dark style as fallback
 		renderer, err = glamour.NewTermRenderer(
 			glamour.WithStandardStyle("dark"),
-			glamour.WithWordWrap(width-4),
+			glamour.WithWordWrap(wrap),
 		)
 	}
 
@@ -119,7 +232,7 @@ func NewDIYEditor(filename string) (*DIYEditor, error) {
 		// Try dracula style (known for good syntax highlighting)
 		renderer, err = glamour.NewTermRenderer(
 			glamour.WithStandardStyle("dracula"),
-			glamour.WithWordWrap(width-4),
+			glamour.WithWordWrap(wrap),
 		)
 	}
 
@@ -127,35 +240,45 @@ func NewDIYEditor(filename string) (*DIYEditor, error) {
 		// Final fallback - basic renderer
 		renderer = nil
 	}
+	e.renderer = renderer
+}
 
-	editor := &DIYEditor{
-		content:   content,
-		cursor:    Position{0, 0},
-		viewport:  Viewport{0, 0},
-		mode:      ModeNormal,
-		activeTab: TabEditor,
-		saved:     true,
-		filename:  filename,
-		width:     width,
-		height:    height,
-		oldState:  oldState,
-		renderer:  renderer,
+// setSplit switches between the tabbed and the side-by-side layout
+func (e *DIYEditor) setSplit(split bool) {
+	e.split = split
+	e.createRenderer()
+}
+
+// editorWidth is the width of the editor pane: the whole screen, or the
+// left half in split view
+func (e *DIYEditor) editorWidth() int {
+	if e.split {
+		return e.width / 2
 	}
+	return e.width
+}
 
-	// Set up signal handling for cleanup
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		editor.Cleanup()
-		os.Exit(0)
-	}()
+// previewWidth is the width of the preview pane
+func (e *DIYEditor) previewWidth() int {
+	if e.split {
+		return e.width - e.editorWidth() - 1 // divider
+	}
+	return e.width
+}
 
-	return editor, nil
+// previewColumn is the screen column the preview pane starts at
+func (e *DIYEditor) previewColumn() int {
+	if e.split {
+		return e.editorWidth() + 2
+	}
+	return 1
 }
 
 // Cleanup restores terminal state
 func (e *DIYEditor) Cleanup() {
+	if e.preview != nil {
+		e.preview.Close()
+	}
 	if e.oldState != nil {
 		term.Restore(int(os.Stdin.Fd()), e.oldState)
 	}
@@ -174,7 +297,7 @@ func getTerminalSize() (int, int, error) {
 
 	ws := &winsize{}
 	retCode, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-		uintptr(syscall.Stdin),
+		os.Stdin.Fd(), // the terminal, even when stdin was a pipe
 		uintptr(syscall.TIOCGWINSZ),
 		uintptr(unsafe.Pointer(ws)))
 
@@ -213,15 +336,29 @@ func (e *DIYEditor) setStatus(msg string) {
 
 // Render performs a full screen redraw
 func (e *DIYEditor) Render() {
+	// Every key ends in a redraw, so this keeps the browser preview current
+	if e.preview != nil {
+		e.preview.Update(e.content, e.filename, e.cursor.row)
+	}
+	e.recheck()
+
 	e.hideCursor()
 	e.clearScreen()
 
+	if e.zen && e.activeTab == TabEditor && !e.split {
+		e.renderZen()
+		return
+	}
+
 	// Draw tab bar
 	e.renderTabBar()
 
 	// Draw content based on active tab
 	contentHeight := e.height - 3 // tab + status + footer
-	if e.activeTab == TabEditor {
+	if e.split {
+		e.renderEditor(contentHeight)
+		e.renderPreview(contentHeight)
+	} else if e.activeTab == TabEditor {
 		e.renderEditor(contentHeight)
 	} else {
 		e.renderPreview(contentHeight)
@@ -233,10 +370,28 @@ func (e *DIYEditor) Render() {
 	// Draw footer
 	e.renderFooter()
 
-	// Position cursor if on editor tab
-	if e.activeTab == TabEditor {
+	if e.spellPopup.open && e.activeTab == TabEditor {
+		e.renderSpellPopup(e.spellPopup.word.Line-e.viewport.offsetRow+2,
+			e.spellPopup.word.Col-e.viewport.offsetCol+1+e.gutterWidth(), contentHeight+1, e.editorWidth())
+		return
+	}
+	if e.picker.open && e.activeTab == TabEditor {
+		e.renderPopup(e.picker.header, e.picker.items, e.picker.sel, 1, e.gutterWidth()+1, contentHeight+1, e.editorWidth())
+		return
+	}
+
+	if e.completions != nil && e.activeTab == TabEditor {
+		e.renderPopup(nil, e.completions, e.completion, e.cursor.row-e.viewport.offsetRow+2,
+			e.completing.start-e.viewport.offsetCol+1+e.gutterWidth(), contentHeight+1, e.editorWidth())
+	}
+
+	// Position cursor on the command line, or in the editor tab
+	if e.mode == ModeCommand {
+		e.moveCursor(e.height-1, len(e.commandLine)+3)
+		e.showCursor()
+	} else if e.activeTab == TabEditor {
 		cursorRow := e.cursor.row - e.viewport.offsetRow + 2 // +2 for tab bar
-		cursorCol := e.cursor.col - e.viewport.offsetCol + 1
+		cursorCol := e.cursor.col - e.viewport.offsetCol + 1 + e.gutterWidth()
 		if cursorRow > 1 && cursorRow <= contentHeight+1 && cursorCol > 0 {
 			e.moveCursor(cursorRow, cursorCol)
 			e.showCursor()
@@ -292,25 +447,75 @@ func (e *DIYEditor) renderEditor(height int) {
 		}
 
 		// Truncate if too long
-		if len(visibleLine) > e.width {
-			visibleLine = visibleLine[:e.width]
+		width := e.editorWidth() - e.gutterWidth()
+		if len(visibleLine) > width {
+			visibleLine = visibleLine[:width]
+		}
+
+		// Lint problems get a sign in the gutter and are underlined, and so
+		// are misspellings that aren't part of one, in red
+		spans := diagnosticSpans(e.diagnostics, lineNum)
+		if e.gutterWidth() > 0 {
+			if len(spans) > 0 {
+				fmt.Print("\033[33m●\033[0m ")
+			} else {
+				fmt.Print("  ")
+			}
+		}
+		colors := make([]string, len(spans))
+		for j := range colors {
+			colors[j] = "33"
+		}
+		for _, word := range misspellingSpans(e.misspellings, lineNum) {
+			if !slices.ContainsFunc(spans, func(span [2]int) bool { return span[0] < word[1] && word[0] < span[1] }) {
+				spans = append(spans, word)
+				colors = append(colors, "31")
+			}
+		}
+		order := make([]int, len(spans))
+		for j := range order {
+			order[j] = j
+		}
+		slices.SortFunc(order, func(a, b int) int { return spans[a][0] - spans[b][0] })
+		for k := len(order) - 1; k >= 0; k-- {
+			j := order[k]
+			start := min(max(0, spans[j][0]-e.viewport.offsetCol), len(visibleLine))
+			end := min(max(0, spans[j][1]-e.viewport.offsetCol), len(visibleLine))
+			if start < end {
+				visibleLine = visibleLine[:start] + "\033[4;" + colors[j] + "m" + visibleLine[start:end] + "\033[0m" + visibleLine[end:]
+			}
+		}
+
+		// Front matter is highlighted as YAML or TOML
+		if len(spans) == 0 && lineNum < len(e.frontMatter) {
+			scrolled := ansi.StringWidth(line[:min(e.viewport.offsetCol, len(line))])
+			visibleLine = ansi.Truncate(ansi.TruncateLeft(e.frontMatter[lineNum], scrolled, ""), width, "")
 		}
 
 		fmt.Print(visibleLine)
 	}
+
+	// Divider between the panes in split view
+	if e.split {
+		for i := 0; i < height; i++ {
+			e.moveCursor(i+2, e.editorWidth()+1)
+			fmt.Print("\033[2m│\033[0m")
+		}
+	}
 }
 
 // renderPreview draws the markdown preview using Charm's glamour
 func (e *DIYEditor) renderPreview(height int) {
+	column := e.previewColumn()
 	if e.renderer == nil {
-		e.moveCursor(2, 1)
+		e.moveCursor(2, column)
 		fmt.Print("Preview not available (glamour renderer failed)")
 		return
 	}
 
-	markdown := strings.Join(e.content, "\n")
+	markdown := previewMarkdown(e.content, e.config.FrontMatterCard)
 	if strings.TrimSpace(markdown) == "" {
-		e.moveCursor(2, 1)
+		e.moveCursor(2, column)
 		fmt.Print("No content to preview")
 		return
 	}
@@ -318,7 +523,7 @@ func (e *DIYEditor) renderPreview(height int) {
 	// Render markdown using glamour
 	rendered, err := e.renderer.Render(markdown)
 	if err != nil {
-		e.moveCursor(2, 1)
+		e.moveCursor(2, column)
 		fmt.Printf("Error rendering markdown: %s", err.Error())
 		return
 	}
@@ -326,6 +531,13 @@ func (e *DIYEditor) renderPreview(height int) {
 	// Split into lines and apply scrolling
 	lines := strings.Split(rendered, "\n")
 
+	// Highlight the line of the link selected with n/N
+	if e.previewLink > 0 && e.activeTab == TabPreview {
+		if line := renderedLinkLine(lines, e.previewLinks(), e.previewLink-1); line >= 0 {
+			lines[line] = "\033[7m" + ansi.Strip(lines[line]) + "\033[0m"
+		}
+	}
+
 	// Calculate safe offset
 	offset := e.previewOffset
 	if offset < 0 {
@@ -343,16 +555,15 @@ func (e *DIYEditor) renderPreview(height int) {
 
 	for i := 0; i < height; i++ {
 		row := i + 2 // Start after tab bar
-		e.moveCursor(row, 1)
-		e.clearLine()
+		e.moveCursor(row, column)
+		if !e.split {
+			e.clearLine()
+		}
 
 		lineIdx := startLine + i
 		if lineIdx < len(lines) && lineIdx < endLine {
-			line := lines[lineIdx]
-			if len(line) > e.width {
-				line = line[:e.width]
-			}
-			fmt.Print(line)
+			// Truncate by display width; the line is full of color escapes
+			fmt.Print(ansi.Truncate(lines[lineIdx], e.previewWidth(), ""))
 		}
 	}
 }
@@ -368,7 +579,9 @@ func (e *DIYEditor) renderStatusBar() {
 		e.statusMsg = ""
 	}
 
-	if e.statusMsg != "" {
+	if e.mode == ModeCommand {
+		fmt.Printf(" :%s", e.commandLine)
+	} else if e.statusMsg != "" {
 		fmt.Printf("\033[7m %s \033[0m", e.statusMsg)
 	} else {
 		modeStr := "NORMAL"
@@ -380,11 +593,32 @@ func (e *DIYEditor) renderStatusBar() {
 		if !e.saved {
 			saveStatus = " [+]"
 		}
+		if e.readOnly {
+			saveStatus += " [RO]"
+		}
 
 		fmt.Printf("\033[7m %s   %s%s \033[0m", modeStr, e.filename, saveStatus)
 
 		if e.activeTab == TabEditor {
+			if done, total := countTasks(e.doc.tasks); total > 0 {
+				fmt.Printf("\033[7m ☑ %d/%d \033[0m", done, total)
+			}
+			if len(e.diagnostics) > 0 {
+				fmt.Printf("\033[7m ● %d \033[0m", len(e.diagnostics))
+			}
+			fmt.Printf("\033[7m %d words %d chars %s \033[0m", e.counts.words, e.counts.chars, readingTime(e.counts.words))
+			if e.goal.target > 0 {
+				fmt.Printf("\033[7m %s \033[0m", e.goal.bar(e.counts.words, e.goalStart))
+			}
 			fmt.Printf("\033[7m (%d,%d) \033[0m", e.cursor.row+1, e.cursor.col+1)
+			if e.frontMatterErr != nil {
+				fmt.Printf(" \033[31m%s\033[0m", ansi.Truncate("Front matter "+e.frontMatterErr.Error(), max(0, e.width/2), "…"))
+			} else if found := lineDiagnostics(e.diagnostics, e.cursor.row); len(found) > 0 {
+				fmt.Printf(" \033[33m%s\033[0m", ansi.Truncate(found[0].Rule+" "+found[0].Message, max(0, e.width/2), "…"))
+			}
+		}
+		if e.preview != nil {
+			fmt.Printf(" %s", e.preview.URL())
 		}
 	}
 }
@@ -395,11 +629,17 @@ func (e *DIYEditor) renderFooter() {
 	e.moveCursor(row, 1)
 	e.clearLine()
 
-	if e.activeTab == TabEditor {
+	if e.mode == ModeCommand {
+		fmt.Print(" Enter Run │ Esc Cancel │ :w :q :wq Write/Quit │ :export html Export")
+	} else if e.activeTab == TabEditor {
 		if e.mode == ModeInsert {
 			fmt.Print(" Ctrl+V Paste │ Esc Normal │ Tab Preview │ Ctrl+S Save │ Ctrl+Q Quit")
 		} else {
-			fmt.Print(" i Insert │ Tab Preview │ Ctrl+S Save │ o New Line │ d Delete Line │ Ctrl+Q Quit")
+			problems := ""
+			if len(e.diagnostics) > 0 {
+				problems = " ] [ Problems │"
+			}
+			fmt.Print(" i Insert │ Tab Preview │ Ctrl+S Save │ o New Line │ d Delete Line │ c Check Task │" + problems + " : Command │ Ctrl+Q Quit")
 		}
 	} else {
 		fmt.Print(" j/k Scroll │ Tab Editor │ g Top │ G Bottom │ Ctrl+Q Quit")
@@ -466,6 +706,12 @@ func (e *DIYEditor) Run() error {
 						}
 						e.Render()
 						continue
+					case 'Z': // Shift+Tab (\033[Z)
+						if !e.snippetTab(-1) && !e.moveTableCell(-1) && !e.indentListItem(-1) {
+							e.handleKey(9)
+						}
+						e.Render()
+						continue
 					case 'D': // Left arrow (\033[D)
 						if e.activeTab == TabEditor {
 							if e.mode == ModeInsert {
@@ -480,11 +726,21 @@ func (e *DIYEditor) Run() error {
 				} else if n == 1 {
 					// Just ESC key - switch to normal mode
 					if e.activeTab == TabEditor && e.mode == ModeInsert {
+						e.formatTable()
+						e.snippet = nil
+						e.completions = nil
 						e.mode = ModeNormal
 						if e.cursor.col > 0 {
 							e.cursor.col--
 						}
 						e.adjustViewport()
+					} else if e.mode == ModeCommand {
+						e.mode = ModeNormal
+						e.commandLine = ""
+					} else if e.spellPopup.open {
+						e.spellPopup = spellPopup{}
+					} else if e.picker.open {
+						e.picker = picker{}
 					}
 					e.Render()
 					continue
@@ -501,6 +757,20 @@ func (e *DIYEditor) Run() error {
 
 // handleKey processes a single key press
 func (e *DIYEditor) handleKey(key byte) bool {
+	// The command line, the spelling suggestions and the picker capture
+	// every key except Ctrl+Q
+	if e.mode == ModeCommand && key != 17 {
+		return e.handleCommandKey(key)
+	}
+	if e.spellPopup.open && key != 17 {
+		e.handleSpellPopup(key)
+		return false
+	}
+	if e.picker.open && key != 17 {
+		e.handlePicker(key)
+		return false
+	}
+
 	// Global keys
 	switch key {
 	case 17: // Ctrl+Q
@@ -509,6 +779,13 @@ func (e *DIYEditor) handleKey(key byte) bool {
 		e.saveFile()
 		return false
 	case 9: // Tab
+		if e.mode == ModeInsert && e.completions != nil {
+			e.acceptCompletion()
+			return false
+		}
+		if e.snippetTab(1) || e.moveTableCell(1) || e.indentListItem(1) {
+			return false
+		}
 		if e.activeTab == TabEditor {
 			e.activeTab = TabPreview
 		} else {
@@ -535,6 +812,22 @@ func (e *DIYEditor) handleEditorKey(key byte) bool {
 
 // handleNormalKey handles keys in normal mode
 func (e *DIYEditor) handleNormalKey(key byte) bool {
+	// The arrow keys come straight here
+	if e.spellPopup.open {
+		e.handleSpellPopup(key)
+		return false
+	}
+	if e.picker.open {
+		e.handlePicker(key)
+		return false
+	}
+
+	// A read-only buffer can be moved around in but not changed
+	if e.readOnly && strings.IndexByte("iaAoOxdcq=", key) >= 0 {
+		e.setStatus(readOnlyMsg)
+		return false
+	}
+
 	switch key {
 	case 'h': // Left
 		if e.cursor.col > 0 {
@@ -579,6 +872,9 @@ func (e *DIYEditor) handleNormalKey(key byte) bool {
 		e.adjustViewport()
 	case 'i': // Insert mode
 		e.mode = ModeInsert
+	case ':': // Command line
+		e.mode = ModeCommand
+		e.commandLine = ""
 	case 'a': // Append
 		e.mode = ModeInsert
 		if e.cursor.col < len(e.content[e.cursor.row]) {
@@ -619,6 +915,30 @@ func (e *DIYEditor) handleNormalKey(key byte) bool {
 		// For full vim compatibility, this would need proper command parsing
 		// For now, let's implement dd as a single 'd'
 		e.deleteLine()
+	case 'c': // Tick or clear the task checkbox, gc in the Bubbletea version
+		e.toggleTask()
+	case 'f': // Follow the link or file name under the cursor (gf)
+		e.followLinkAt(true)
+	case 13: // Enter follows the link under the cursor
+		e.followLinkAt(false)
+	case 15: // Ctrl+O goes back to where the last link was followed from
+		e.jumpBack()
+	case 'q': // Reflow the paragraph (gqap)
+		e.reflowParagraph()
+	case 'z': // Zen mode (gz)
+		e.toggleZen()
+	case 's': // Next misspelled word (]s)
+		e.gotoMisspelling(1)
+	case 'S': // Previous misspelled word ([s)
+		e.gotoMisspelling(-1)
+	case '=': // Suggest spellings for the word (z=)
+		e.suggestSpelling()
+	case 'Z': // Add the word to the word list (zg)
+		e.goodWord(nil)
+	case ']': // Next lint problem (]d)
+		e.gotoDiagnostic(1)
+	case '[': // Previous lint problem ([d)
+		e.gotoDiagnostic(-1)
 	case 'w': // Next word
 		e.cursor = e.nextWord()
 		e.adjustViewport()
@@ -634,16 +954,44 @@ func (e *DIYEditor) handleNormalKey(key byte) bool {
 
 // handleInsertKey handles keys in insert mode
 func (e *DIYEditor) handleInsertKey(key byte) bool {
+	if e.readOnly {
+		e.mode = ModeNormal
+		e.setStatus(readOnlyMsg)
+		return false
+	}
+	if e.completions != nil && e.completionKey(key) {
+		return false
+	}
+	if key != 14 && key != 16 {
+		// Typing filters the popup, or opens it in a link target or anchor
+		defer func() {
+			e.refreshCompletion(autoCompletes(string(rune(key)), completionAt(e.content[e.cursor.row], e.cursor.col)))
+		}()
+	}
+	if e.snippet != nil {
+		e.snippet.mark(e.content, e.cursor)
+		defer e.followSnippet()
+	}
+
 	switch key {
 	case 27: // Escape
+		e.formatTable()
 		e.mode = ModeNormal
 		if e.cursor.col > 0 {
 			e.cursor.col--
 		}
 	case 22: // Ctrl+V - Paste
 		e.pasteFromClipboard()
+	case 14: // Ctrl+N - Complete
+		e.complete(1)
+	case 16: // Ctrl+P - Complete backwards
+		e.complete(-1)
 	case 127, 8: // Backspace
-		if e.cursor.col > 0 {
+		if line, col, ok := pairBackspace(e.content[e.cursor.row], e.cursor.col, e.config.AutoPairs); ok {
+			// Delete an empty pair
+			e.content[e.cursor.row], e.cursor.col = line, col
+			e.saved = false
+		} else if e.cursor.col > 0 {
 			line := e.content[e.cursor.row]
 			e.content[e.cursor.row] = line[:e.cursor.col-1] + line[e.cursor.col:]
 			e.cursor.col--
@@ -660,6 +1008,15 @@ func (e *DIYEditor) handleInsertKey(key byte) bool {
 		}
 		e.adjustViewport()
 	case 13: // Enter
+		// Continue (or end) a list or quote
+		if content, cursor, ok := listEnter(e.content, e.cursor); ok {
+			e.content = content
+			e.cursor = cursor
+			e.saved = false
+			e.adjustViewport()
+			break
+		}
+
 		currentLine := e.content[e.cursor.row]
 		beforeCursor := currentLine[:e.cursor.col]
 		afterCursor := currentLine[e.cursor.col:]
@@ -677,23 +1034,245 @@ func (e *DIYEditor) handleInsertKey(key byte) bool {
 			return false
 		}
 
-		// Regular character input
-		if key >= 32 && key <= 126 { // Printable ASCII
+		// Regular character input, unless it pairs
+		if key >= 32 && key <= 126 && !e.autoPair(key) { // Printable ASCII
 			line := e.content[e.cursor.row]
 			char := string(rune(key))
 			e.content[e.cursor.row] = line[:e.cursor.col] + char + line[e.cursor.col:]
 			e.cursor.col++
 			e.saved = false
+
+			// Break the line once it passes the text width
+			if e.config.HardWrap && key != ' ' {
+				if content, cursor, ok := hardWrap(e.content, e.cursor, e.config.WordWrap); ok {
+					e.content, e.cursor = content, cursor
+					e.adjustViewport()
+				}
+			}
+		}
+	}
+	return false
+}
+
+// handleCommandKey edits the ":" command line, running it on Enter
+func (e *DIYEditor) handleCommandKey(key byte) bool {
+	switch key {
+	case 13: // Enter
+		line := e.commandLine
+		e.mode = ModeNormal
+		e.commandLine = ""
+		return e.executeCommand(line)
+	case 127, 8: // Backspace
+		if e.commandLine == "" {
+			e.mode = ModeNormal
+		} else {
+			e.commandLine = e.commandLine[:len(e.commandLine)-1]
+		}
+	default:
+		if key >= 32 && key <= 126 { // Printable ASCII
+			e.commandLine += string(rune(key))
+		}
+	}
+	return false
+}
+
+// executeCommand runs a ":" command, returning true when the editor should exit
+func (e *DIYEditor) executeCommand(line string) bool {
+	cmd := parseExCommand(line)
+
+	switch cmd.name {
+	case "":
+	case "w", "write":
+		if strings.HasPrefix(cmd.rest, "!") {
+			e.writeFilter(strings.TrimSpace(cmd.rest[1:]))
+			break
+		}
+		if len(cmd.args) > 0 {
+			e.filename = cmd.args[0]
+		}
+		e.writeBuffer(cmd.bang)
+	case "q", "quit":
+		if !e.saved && !cmd.bang {
+			e.setStatus("No write since last change (add ! to override)")
+			return false
+		}
+		return true
+	case "wq", "x":
+		e.writeBuffer(cmd.bang)
+		return e.saved
+	case "export":
+		e.exportCommand(cmd.args)
+	case "serve":
+		e.serveCommand(cmd.args)
+	case "set", "se":
+		e.setCommand(cmd.args)
+	case "toc":
+		e.tocCommand(cmd.args)
+	case "table":
+		e.tableCommand(cmd.args)
+	case "renumber":
+		e.renumberCommand()
+	case "tasks":
+		e.tasksCommand(cmd.args)
+	case "checklinks":
+		e.checkLinksCommand(cmd.bang)
+	case "lint":
+		e.lintCommand()
+	case "Format", "format":
+		e.formatCommand(cmd)
+	case "stats":
+		e.statsCommand()
+	case "goal":
+		e.goalCommand(cmd.args)
+	case "goals":
+		e.goalsCommand()
+	case "zen":
+		e.toggleZen()
+	case "focus":
+		e.focusCommand(cmd.args)
+	case "spellgood", "spellgo":
+		e.goodWord(cmd.args)
+	case "ys", "cs", "ds":
+		if motion, chars, err := surroundArgs(cmd.name, cmd.args); err != nil {
+			e.setStatus("Surround: " + err.Error())
+		} else {
+			e.surround(cmd.name, motion, chars)
 		}
+	case "bold", "italic", "code":
+		e.styleCommand(cmd.name)
+	case "link":
+		e.linkCommand(cmd.args)
+	case "meta":
+		e.metaCommand(cmd.rest)
+	case "snippets":
+		snippets, err := loadSnippets(e.filename)
+		e.snippets = snippets
+		if err != nil {
+			e.setStatus("Snippets: " + err.Error())
+		} else {
+			e.setStatus(snippetSummary(snippets))
+		}
+	default:
+		e.setStatus("Not an editor command: " + cmd.name)
 	}
 	return false
 }
 
+// writeFilter implements ":w !cmd", handing the terminal to the command
+// while it runs so sudo can prompt for a password
+func (e *DIYEditor) writeFilter(cmdline string) {
+	if cmdline == "" {
+		e.setStatus("Usage: :w !command (% is the file name)")
+		return
+	}
+	if filterWritesFile(cmdline) && e.filename == "" {
+		e.setStatus("No file name for %")
+		return
+	}
+
+	cmd, output := writeFilterCommand(cmdline, e.filename, e.content)
+
+	term.Restore(int(os.Stdin.Fd()), e.oldState)
+	fmt.Print("\033[2J\033[H\033[?25h")
+	err := cmd.Run()
+	if state, rawErr := term.MakeRaw(int(os.Stdin.Fd())); rawErr == nil {
+		e.oldState = state
+	}
+
+	e.setStatus(filterStatus(cmdline, output.String(), err))
+	if err == nil && filterWritesFile(cmdline) {
+		e.saved = true
+	}
+}
+
+// setCommand implements ":set [no]readonly" (":set ro" / ":set noro") and
+// ":set [no]hardwrap"
+func (e *DIYEditor) setCommand(args []string) {
+	if len(args) == 0 {
+		e.setStatus("Usage: :set [no]readonly | [no]hardwrap | [no]spell")
+		return
+	}
+
+	for _, option := range args {
+		switch option {
+		case "readonly", "ro":
+			e.readOnly = true
+		case "noreadonly", "noro":
+			e.readOnly = false
+		case "hardwrap", "hw":
+			e.config.HardWrap = true
+		case "nohardwrap", "nohw":
+			e.config.HardWrap = false
+		case "spell":
+			e.config.Spell = true
+			e.loadSpell()
+		case "nospell":
+			e.config.Spell = false
+			e.loadSpell()
+		default:
+			e.setStatus("Unknown option: " + option)
+			return
+		}
+	}
+}
+
+// exportCommand implements ":export FORMAT [FILE]"
+func (e *DIYEditor) exportCommand(args []string) {
+	if len(args) == 0 {
+		e.setStatus("Usage: :export FORMAT [FILE] (" + strings.Join(exportFormats(), ", ") + ")")
+		return
+	}
+
+	target := ""
+	if len(args) > 1 {
+		target = args[1]
+	}
+
+	path, err := exportBuffer(args[0], e.content, e.filename, target, ExportOptions{Theme: e.config.Theme, Width: e.config.WordWrap})
+	if err != nil {
+		e.setStatus("Export failed: " + err.Error())
+		return
+	}
+	e.setStatus("Exported " + args[0] + " to " + path)
+}
+
+// serveCommand implements ":serve [PORT]" and ":serve stop"
+func (e *DIYEditor) serveCommand(args []string) {
+	if len(args) > 0 && args[0] == "stop" {
+		if e.preview != nil {
+			e.preview.Close()
+			e.preview = nil
+		}
+		e.setStatus("Preview server stopped")
+		return
+	}
+
+	if e.preview == nil {
+		port := 0
+		if len(args) > 0 {
+			var err error
+			if port, err = strconv.Atoi(args[0]); err != nil {
+				e.setStatus("Usage: :serve [PORT] | :serve stop")
+				return
+			}
+		}
+
+		server, err := startPreviewServer(port, e.config.Theme)
+		if err != nil {
+			e.setStatus("Serve failed: " + err.Error())
+			return
+		}
+		e.preview = server
+	}
+
+	e.setStatus("Serving preview at " + e.preview.URL())
+}
+
 // handlePreviewKey handles keys in preview mode
 func (e *DIYEditor) handlePreviewKey(key byte) bool {
 	switch key {
 	case 'j': // Scroll down
-		markdown := strings.Join(e.content, "\n")
+		markdown := previewMarkdown(e.content, e.config.FrontMatterCard)
 		if strings.TrimSpace(markdown) != "" && e.renderer != nil {
 			if rendered, err := e.renderer.Render(markdown); err == nil {
 				lines := strings.Split(rendered, "\n")
@@ -710,8 +1289,17 @@ func (e *DIYEditor) handlePreviewKey(key byte) bool {
 		}
 	case 'g': // Go to top
 		e.previewOffset = 0
+	case ':': // Command line
+		e.mode = ModeCommand
+		e.commandLine = ""
+	case 'n': // Select the next link
+		e.selectPreviewLink(1)
+	case 'N': // Select the previous link
+		e.selectPreviewLink(-1)
+	case 13: // Follow the selected link
+		e.followPreviewLink()
 	case 'G': // Go to bottom
-		markdown := strings.Join(e.content, "\n")
+		markdown := previewMarkdown(e.content, e.config.FrontMatterCard)
 		if strings.TrimSpace(markdown) != "" && e.renderer != nil {
 			if rendered, err := e.renderer.Render(markdown); err == nil {
 				lines := strings.Split(rendered, "\n")
@@ -767,7 +1355,7 @@ func (e *DIYEditor) adjustViewport() {
 	}
 
 	// Horizontal scrolling
-	contentWidth := e.width - 3
+	contentWidth := e.editorWidth() - e.gutterWidth() - 3
 	if contentWidth < 1 {
 		contentWidth = 1
 	}
@@ -783,6 +1371,30 @@ func (e *DIYEditor) adjustViewport() {
 
 // saveFile saves the current content
 func (e *DIYEditor) saveFile() {
+	e.writeBuffer(false)
+}
+
+// writeBuffer saves the buffer to its file. force (":w!") overrides the
+// read-only state and the file's missing write permission, where possible.
+func (e *DIYEditor) writeBuffer(force bool) {
+	if e.readOnly && !force {
+		e.setStatus("File is read-only (add ! to override, or :w !sudo tee %)")
+		return
+	}
+
+	if e.config.FormatOnSave {
+		e.formatBuffer(0, len(e.content)-1)
+	}
+	tocErr := e.refreshTOC()
+
+	// In pipe mode the written buffer is printed to stdout when hani exits
+	if e.pipe {
+		e.pipeOutput = append([]string(nil), e.content...)
+		e.saved = true
+		e.setStatus("Buffer will be written to stdout on exit")
+		return
+	}
+
 	filename := e.filename
 	if filename == "" {
 		filename = "untitled.md"
@@ -799,35 +1411,509 @@ func (e *DIYEditor) saveFile() {
 		}
 	}
 
-	err := os.WriteFile(filename, []byte(content), 0644)
+	var err error
+	if force {
+		err = forceWriteFile(filename, []byte(content))
+	} else {
+		err = os.WriteFile(filename, []byte(content), 0644)
+	}
 	if err != nil {
 		e.setStatus("Error saving file: " + err.Error())
-	} else {
-		e.saved = true
+		return
+	}
+
+	e.saved = true
+	historyErr := e.recordWriting()
+	switch {
+	case tocErr != nil:
+		e.setStatus("File saved: " + filename + " (TOC not updated: " + tocErr.Error() + ")")
+	case historyErr != nil:
+		e.setStatus("File saved: " + filename + " (writing history not updated: " + historyErr.Error() + ")")
+	default:
 		e.setStatus("File saved: " + filename)
 	}
 }
 
-// pasteFromClipboard handles clipboard paste operations efficiently
-func (e *DIYEditor) pasteFromClipboard() {
-	clipboard := e.getClipboard()
-	if clipboard == "" {
-		e.setStatus("Clipboard empty")
-		return
+// refreshTOC regenerates the table of contents, if the buffer has one,
+// before it is written
+func (e *DIYEditor) refreshTOC() error {
+	content, row, changed, err := updateTOC(e.content, e.cursor.row)
+	if changed {
+		e.content = content
+		e.cursor.row = row
+		e.adjustViewport()
 	}
+	return err
+}
 
-	// This is the key difference - no framework overhead!
-	// Direct content modification with immediate rendering
-	lines := strings.Split(clipboard, "\n")
+// moveTableCell handles Tab and Shift+Tab in insert mode inside a table:
+// the table is realigned and the cursor moves to the next or previous cell
+func (e *DIYEditor) moveTableCell(dir int) bool {
+	if e.activeTab != TabEditor || e.mode != ModeInsert || e.readOnly {
+		return false
+	}
+	content, cursor, ok := tableNextCell(e.content, e.cursor, dir)
+	if ok {
+		e.setTableContent(content, cursor)
+	}
+	return ok
+}
 
-	if len(lines) == 1 {
-		// Single line paste
-		line := e.content[e.cursor.row]
-		e.content[e.cursor.row] = line[:e.cursor.col] + clipboard + line[e.cursor.col:]
-		e.cursor.col += len(clipboard)
-	} else {
-		// Multi-line paste - handle efficiently
-		currentLine := e.content[e.cursor.row]
+// indentListItem handles Tab and Shift+Tab in insert mode on a list item,
+// nesting it under the item above or moving it out a level
+func (e *DIYEditor) indentListItem(dir int) bool {
+	if e.activeTab != TabEditor || e.mode != ModeInsert || e.readOnly {
+		return false
+	}
+	content, cursor, ok := listIndent(e.content, e.cursor, dir)
+	if ok {
+		e.content = content
+		e.cursor = cursor
+		e.saved = false
+		e.adjustViewport()
+	}
+	return ok
+}
+
+// renumberCommand implements ":renumber", which fixes ordered list numbering
+func (e *DIYEditor) renumberCommand() {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+
+	content, changed := renumberLists(e.content)
+	if changed == 0 {
+		e.setStatus("Lists are already numbered in order")
+		return
+	}
+	e.content = content
+	e.saved = false
+	e.setStatus(fmt.Sprintf("Renumbered %d list items", changed))
+}
+
+// toggleTask ticks or clears the checkbox of the task on the cursor line
+func (e *DIYEditor) toggleTask() {
+	content, cursor, ok := toggleTaskAt(e.content, e.cursor, taskDate(e.config))
+	if !ok {
+		e.setStatus("Not a list item")
+		return
+	}
+	e.content, e.cursor = content, cursor
+	e.saved = false
+}
+
+// tasksCommand implements ":tasks [DIR]", listing the tasks in a picker
+// that jumps to the chosen one
+func (e *DIYEditor) tasksCommand(args []string) {
+	if len(args) > 1 {
+		e.setStatus("Usage: :tasks [DIR]")
+		return
+	}
+
+	source := "buffer"
+	tasks := e.doc.tasks
+	if len(args) == 1 {
+		found, err := collectDirTasks(args[0])
+		if err != nil {
+			e.setStatus("Tasks: " + err.Error())
+			return
+		}
+		source, tasks = args[0], found
+	}
+	if len(tasks) == 0 {
+		e.setStatus("No tasks found")
+		return
+	}
+
+	// Start on the buffer's first task at or below the cursor
+	items := make([]string, len(tasks))
+	sel := -1
+	for i, t := range tasks {
+		box := "[ ]"
+		if t.Done {
+			box = "[x]"
+		}
+		location := strconv.Itoa(t.Line + 1)
+		if t.File != "" {
+			location = t.File + ":" + location
+		}
+		items[i] = fmt.Sprintf("%s %s  %s", box, t.Text, location)
+		if sel < 0 && t.File == "" && t.Line >= e.cursor.row {
+			sel = i
+		}
+	}
+
+	done, total := countTasks(tasks)
+	e.openPicker(fmt.Sprintf("Tasks in %s: %d open, %d done", source, total-done, done), items, max(sel, 0), func(i int) {
+		if e.jumpToLine(tasks[i].File, tasks[i].Line) {
+			if item, ok := parseListItem(e.content[e.cursor.row]); ok {
+				e.cursor.col = item.length
+			}
+		}
+	})
+}
+
+// checkLinksCommand implements ":checklinks", listing the problems in a
+// picker. URLs are not requested here (":checklinks!"): the requests would
+// hold up the input loop until they all came back.
+func (e *DIYEditor) checkLinksCommand(external bool) {
+	if external {
+		e.setStatus("The DIY version does not check URLs (use :checklinks, or hani check --external)")
+		return
+	}
+	problems := newLinkChecker(false, 0).check(e.content, e.filename)
+	if len(problems) == 0 {
+		e.setStatus("No broken links")
+		return
+	}
+
+	// Start on the first problem after the cursor
+	items := make([]string, len(problems))
+	sel := -1
+	for i, p := range problems {
+		items[i] = fmt.Sprintf("%d:%d  %s", p.Line+1, p.Col+1, p.Message)
+		if sel < 0 && p.Line > e.cursor.row {
+			sel = i
+		}
+	}
+	e.openPicker(fmt.Sprintf("Link problems: %d", len(problems)), items, max(sel, 0), func(i int) {
+		if e.jumpToLine("", problems[i].Line) {
+			e.cursor.col = problems[i].Col
+		}
+	})
+}
+
+// openFile replaces the buffer with filename, refusing while the buffer has
+// unsaved changes
+func (e *DIYEditor) openFile(filename string) bool {
+	if !e.saved {
+		e.setStatus("No write since last change (:w first)")
+		return false
+	}
+	if e.pipe {
+		e.setStatus("Cannot open another file in pipe mode")
+		return false
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		e.setStatus("Error reading file: " + err.Error())
+		return false
+	}
+
+	e.filename = filename
+	e.content = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	e.saved = true
+	e.cursor = Position{0, 0}
+	e.viewport = Viewport{0, 0}
+	e.previewOffset = 0
+	e.previewLink = 0
+	e.snippet = nil
+	e.doc = newDocStructure()
+	e.doc.update(e.content)
+	e.loadLint()
+	e.loadSpell()
+	e.loadSnippets()
+	e.checkFrontMatter()
+	e.recount()
+	e.startWriting()
+	e.readOnly = e.readOnlyFlag || !isWritable(filename)
+	return true
+}
+
+// pushJump remembers the cursor position so Ctrl+O can return to it
+func (e *DIYEditor) pushJump() {
+	e.jumps = append(e.jumps, jump{file: e.filename, pos: e.cursor})
+	if len(e.jumps) > maxJumps {
+		e.jumps = e.jumps[len(e.jumps)-maxJumps:]
+	}
+}
+
+// jumpBack returns to the place the last followed link was followed from
+func (e *DIYEditor) jumpBack() {
+	if len(e.jumps) == 0 {
+		e.setStatus("Jump list is empty")
+		return
+	}
+	last := e.jumps[len(e.jumps)-1]
+	if last.file != e.filename && !sameFile(last.file, e.filename) && !e.openFile(last.file) {
+		return
+	}
+	e.jumps = e.jumps[:len(e.jumps)-1]
+	e.cursor = last.pos
+	e.cursor.row = min(e.cursor.row, len(e.content)-1)
+	e.cursor.col = min(e.cursor.col, len(e.content[e.cursor.row]))
+	e.adjustViewport()
+}
+
+// jumpToLine puts the cursor at the start of line in file, or in the buffer
+// when file is "", opening the file first; false means it could not be
+// opened
+func (e *DIYEditor) jumpToLine(file string, line int) bool {
+	from := jump{file: e.filename, pos: e.cursor}
+	if file != "" && file != e.filename && !sameFile(file, e.filename) && !e.openFile(file) {
+		return false
+	}
+	e.jumps = append(e.jumps, from)
+	e.activeTab = TabEditor
+	e.cursor = Position{max(0, min(line, len(e.content)-1)), 0}
+	e.adjustViewport()
+	return true
+}
+
+// followLinkAt follows the link under the cursor, or with fileNames set a
+// file name written as plain text
+func (e *DIYEditor) followLinkAt(fileNames bool) {
+	if link, ok := linkAt(e.content, e.cursor); ok {
+		e.followLink(link)
+		return
+	}
+	if fileNames {
+		if name := fileNameAt(e.content[e.cursor.row], e.cursor.col); name != "" {
+			if _, err := os.Stat(resolveLink(name, e.filename).file); err == nil {
+				e.followTarget(name)
+				return
+			}
+		}
+		e.setStatus("No link or file name under the cursor")
+	}
+}
+
+// followLink follows a link; a reference link jumps to its definition
+func (e *DIYEditor) followLink(link mdLink) {
+	if link.ref != "" && !link.def {
+		if link.target == "" {
+			e.setStatus("No definition for [" + link.ref + "]")
+			return
+		}
+		e.pushJump()
+		e.cursor = Position{link.defLine, 0}
+		e.adjustViewport()
+		return
+	}
+	e.followTarget(link.target)
+}
+
+// followTarget goes to a heading in this document or another Markdown file,
+// and hands URLs and other files to the opener
+func (e *DIYEditor) followTarget(target string) {
+	dest := resolveLink(target, e.filename)
+	if dest.url != "" {
+		e.openExternal(dest.url)
+		return
+	}
+	if dest.file == "" || sameFile(dest.file, e.filename) {
+		e.jumpToAnchor(dest.anchor)
+		return
+	}
+
+	if _, err := os.Stat(dest.file); err != nil {
+		e.setStatus("Cannot open " + dest.file + ": no such file")
+		return
+	}
+	if !isMarkdownFile(dest.file) {
+		e.openExternal(dest.file)
+		return
+	}
+
+	from := jump{file: e.filename, pos: e.cursor}
+	if !e.openFile(dest.file) {
+		return
+	}
+	e.jumps = append(e.jumps, from)
+	if dest.anchor != "" {
+		line, found := anchorLine(e.content, dest.anchor)
+		if !found {
+			e.setStatus(fmt.Sprintf("%s has no heading #%s", dest.file, dest.anchor))
+			return
+		}
+		e.cursor = Position{line, 0}
+	}
+	e.scrollPreviewTo(e.cursor.row)
+	e.adjustViewport()
+}
+
+// jumpToAnchor moves to the heading anchor names in this document
+func (e *DIYEditor) jumpToAnchor(anchor string) {
+	if anchor == "" {
+		return
+	}
+	line, ok := anchorLine(e.content, anchor)
+	if !ok {
+		e.setStatus("No heading #" + anchor)
+		return
+	}
+	e.pushJump()
+	e.cursor = Position{line, 0}
+	e.scrollPreviewTo(line)
+	e.adjustViewport()
+}
+
+// scrollPreviewTo scrolls the preview to the heading on line, when following
+// a link from the preview
+func (e *DIYEditor) scrollPreviewTo(line int) {
+	if e.activeTab != TabPreview || e.renderer == nil {
+		return
+	}
+	e.previewOffset = 0
+	match := atxHeadingRe.FindStringSubmatch(e.content[line])
+	if match == nil {
+		return
+	}
+	if rendered, err := e.renderer.Render(previewMarkdown(e.content, e.config.FrontMatterCard)); err == nil {
+		e.previewOffset = max(0, renderedTextLine(strings.Split(rendered, "\n"), stripInlineMarkdown(match[2])))
+	}
+}
+
+// openExternal hands a URL or file to the opener command
+func (e *DIYEditor) openExternal(target string) {
+	if err := openExternal(e.config.Opener, target); err != nil {
+		e.setStatus("Cannot open " + target + ": " + err.Error())
+		return
+	}
+	e.setStatus("Opened " + target)
+}
+
+// previewLinks returns the links the preview shows, without definitions
+func (e *DIYEditor) previewLinks() []mdLink {
+	var links []mdLink
+	for _, link := range documentLinks(e.content) {
+		if !link.def {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// selectPreviewLink moves the preview's link selection by dir
+func (e *DIYEditor) selectPreviewLink(dir int) {
+	links := e.previewLinks()
+	if len(links) == 0 {
+		e.previewLink = 0
+		e.setStatus("No links in the document")
+		return
+	}
+
+	e.previewLink = min(e.previewLink, len(links))
+	switch {
+	case dir > 0 && e.previewLink < len(links):
+		e.previewLink++
+	case dir > 0:
+		e.previewLink = 1
+	case e.previewLink > 1:
+		e.previewLink--
+	default:
+		e.previewLink = len(links)
+	}
+	link := links[e.previewLink-1]
+
+	if e.renderer != nil {
+		if rendered, err := e.renderer.Render(previewMarkdown(e.content, e.config.FrontMatterCard)); err == nil {
+			height := e.height - 3
+			line := renderedLinkLine(strings.Split(rendered, "\n"), links, e.previewLink-1)
+			if line >= 0 && (line < e.previewOffset || line >= e.previewOffset+height) {
+				e.previewOffset = max(0, line-height/2)
+			}
+		}
+	}
+
+	target := link.target
+	if target == "" {
+		target = "[" + link.ref + "] is not defined"
+	}
+	e.setStatus(fmt.Sprintf("Link %d/%d: %s", e.previewLink, len(links), target))
+}
+
+// followPreviewLink follows the link selected in the preview
+func (e *DIYEditor) followPreviewLink() {
+	links := e.previewLinks()
+	if e.previewLink == 0 || e.previewLink > len(links) {
+		e.setStatus("No link selected (n/N select links)")
+		return
+	}
+	link := links[e.previewLink-1]
+	if link.target == "" {
+		e.setStatus("No definition for [" + link.ref + "]")
+		return
+	}
+	e.previewLink = 0
+	e.followTarget(link.target)
+}
+
+// formatTable realigns the table under the cursor when leaving insert mode
+func (e *DIYEditor) formatTable() {
+	if content, cursor, ok := formatTableAt(e.content, e.cursor); ok {
+		e.setTableContent(content, cursor)
+	}
+}
+
+// setTableContent replaces the buffer after a table edit, which may leave
+// the text unchanged
+func (e *DIYEditor) setTableContent(content []string, cursor Position) {
+	if !slices.Equal(content, e.content) {
+		e.content = content
+		e.saved = false
+	}
+	e.cursor = cursor
+	e.adjustViewport()
+}
+
+// tableCommand implements ":table", which edits the table under the cursor
+func (e *DIYEditor) tableCommand(args []string) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+
+	content, cursor, status, err := tableCommand(args, e.content, e.cursor)
+	if err != nil {
+		e.setStatus("Table: " + err.Error())
+		return
+	}
+	e.setTableContent(content, cursor)
+	e.setStatus(status)
+}
+
+// tocCommand implements ":toc [MIN [MAX]]"
+func (e *DIYEditor) tocCommand(args []string) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+
+	content, row, status, err := tocCommand(args, e.content, e.cursor.row)
+	if err != nil {
+		e.setStatus("TOC: " + err.Error())
+		return
+	}
+	e.content = content
+	e.cursor = Position{row: row, col: 0}
+	e.saved = false
+	e.adjustViewport()
+	e.setStatus(status)
+}
+
+// pasteFromClipboard handles clipboard paste operations efficiently
+func (e *DIYEditor) pasteFromClipboard() {
+	clipboard := e.getClipboard()
+	if clipboard == "" {
+		e.setStatus("Clipboard empty")
+		return
+	}
+
+	// This is the key difference - no framework overhead!
+	// Direct content modification with immediate rendering
+	lines := strings.Split(clipboard, "\n")
+
+	if len(lines) == 1 {
+		// Single line paste
+		line := e.content[e.cursor.row]
+		e.content[e.cursor.row] = line[:e.cursor.col] + clipboard + line[e.cursor.col:]
+		e.cursor.col += len(clipboard)
+	} else {
+		// Multi-line paste - handle efficiently
+		currentLine := e.content[e.cursor.row]
 		beforeCursor := currentLine[:e.cursor.col]
 		afterCursor := currentLine[e.cursor.col:]
 
@@ -1073,6 +2159,18 @@ func (e *DIYEditor) handleDeleteKey() {
 
 // handleArrowKey handles arrow keys in insert mode
 func (e *DIYEditor) handleArrowKey(direction byte) {
+	// Up and down move through the completion popup
+	if e.completions != nil {
+		switch direction {
+		case 'j':
+			e.completionKey(14)
+			return
+		case 'k':
+			e.completionKey(16)
+			return
+		}
+		e.completions = nil
+	}
 	switch direction {
 	case 'h': // Left
 		if e.cursor.col > 0 {
@@ -1121,19 +2219,815 @@ func min(a, b int) int {
 }
 
 func main() {
-	var filename string
-	if len(os.Args) > 1 {
-		filename = os.Args[1]
+	// Non-interactive subcommands run without touching the terminal
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	opts, code, exit := parseCommandLine(os.Args[1:])
+	if exit {
+		os.Exit(code)
+	}
+
+	config, err := opts.Config()
+	if err != nil {
+		os.Exit(fatalf("%v", err))
+	}
+
+	// "-" edits standard input; the keyboard is then read from /dev/tty
+	filename := opts.Filename
+	var input []string
+	if readsStdin(filename, opts.Pipe) {
+		lines, err := readStdinBuffer()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		input, filename = lines, ""
 	}
 
-	editor, err := NewDIYEditor(filename)
+	stdout, err := attachTerminal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	editor, err := NewDIYEditor(filename, config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating editor: %v\n", err)
 		os.Exit(1)
 	}
+	editor.pipe = opts.Pipe
+	editor.readOnly, editor.readOnlyFlag = opts.ReadOnly, opts.ReadOnly
+	if filename != "" && !isWritable(filename) {
+		editor.readOnly = true
+		editor.setStatus(filename + " is not writable; opened read-only")
+	}
+	if opts.Split {
+		editor.setSplit(true)
+	}
+	if opts.Preview {
+		editor.activeTab = TabPreview
+	}
+	if input != nil {
+		editor.content = input
+		editor.saved = false
+		editor.recheck()
+		editor.startWriting()
+		editor.setStatus(fmt.Sprintf("Read %d lines from stdin", len(input)))
+	}
+	if row, err := opts.StartLine(editor.content); err != nil {
+		editor.setStatus(err.Error())
+	} else {
+		editor.cursor.row = row
+		editor.adjustViewport()
+	}
 
 	if err := editor.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Editor error: %v\n", err)
 		os.Exit(1)
 	}
+
+	// In pipe mode, quitting without writing aborts the pipeline
+	if opts.Pipe {
+		if editor.pipeOutput == nil {
+			os.Exit(1)
+		}
+		if err := writePipeOutput(stdout, editor.pipeOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// loadLint reads the lint configuration for the file
+func (e *DIYEditor) loadLint() {
+	config, err := loadLintConfig(e.filename, e.config.WordWrap)
+	if err != nil {
+		e.setStatus("Lint: " + err.Error())
+	}
+	e.lint = config
+	e.relint()
+}
+
+// relint brings the diagnostics in line with the buffer
+func (e *DIYEditor) relint() {
+	e.diagnostics = lintStructure(e.content, e.doc, e.filename, e.lint)
+}
+
+// checkFrontMatter highlights the front matter and validates it
+func (e *DIYEditor) checkFrontMatter() {
+	e.frontMatter = highlightFrontMatter(e.content, e.doc, highlightCode)
+	_, _, e.frontMatterErr = readFrontMatter(e.content, e.doc)
+}
+
+// gutterWidth is the width of the sign column, shown while there are lint
+// problems
+func (e *DIYEditor) gutterWidth() int {
+	if len(e.diagnostics) == 0 {
+		return 0
+	}
+	return 2
+}
+
+// gotoDiagnostic moves to the next or previous lint problem (]d and [d in
+// the Bubbletea version)
+func (e *DIYEditor) gotoDiagnostic(dir int) {
+	d, ok := nextDiagnostic(e.diagnostics, e.cursor, dir)
+	if !ok {
+		e.setStatus("No lint problems")
+		return
+	}
+	e.cursor = Position{d.Line, d.Col}
+	e.adjustViewport()
+}
+
+// lintCommand implements ":lint": it rereads the rules and, as there is no
+// result list here, reports the count and moves to the next problem
+func (e *DIYEditor) lintCommand() {
+	e.loadLint()
+	d, ok := nextDiagnostic(e.diagnostics, e.cursor, 1)
+	if !ok {
+		e.setStatus("No lint problems")
+		return
+	}
+	e.cursor = Position{d.Line, d.Col}
+	e.adjustViewport()
+	e.setStatus(fmt.Sprintf("%d lint problems; %s %s", len(e.diagnostics), d.Rule, d.Message))
+}
+
+// autoPair types key with its closing partner, or steps over the closing
+// character already there; false means key is typed as usual
+func (e *DIYEditor) autoPair(key byte) bool {
+	if strings.IndexByte(e.config.AutoPairs+")]}>", key) < 0 {
+		return false
+	}
+	_, inCode := e.doc.codeBlockAt(e.cursor.row)
+	line, col, ok := autoPair(e.content[e.cursor.row], e.cursor.col, key, e.config.AutoPairs, inCode)
+	if ok {
+		e.content[e.cursor.row], e.cursor.col = line, col
+		e.saved = false
+	}
+	return ok
+}
+
+// surround carries out ":ys", ":cs" or ":ds" on the cursor's line; the
+// DIY version has them as commands only
+func (e *DIYEditor) surround(op, motion string, chars []string) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+	line, col, insert, err := surroundLine(e.content[e.cursor.row], e.cursor.col, op, motion, chars)
+	if err != nil {
+		e.setStatus("Surround: " + err.Error())
+		return
+	}
+	e.content[e.cursor.row], e.cursor.col = line, col
+	e.saved = false
+	if insert {
+		e.mode = ModeInsert
+	}
+	e.adjustViewport()
+}
+
+// styleCommand implements ":bold", ":italic" and ":code" for the word
+// under the cursor
+func (e *DIYEditor) styleCommand(style string) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+	line, col, ok := toggleStyle(e.content[e.cursor.row], e.cursor.col, style)
+	if !ok {
+		e.setStatus("No word under the cursor")
+		return
+	}
+	e.content[e.cursor.row], e.cursor.col = line, col
+	e.saved = false
+}
+
+// linkCommand implements ":link [URL]", which links the word under the
+// cursor or unlinks the link under it
+func (e *DIYEditor) linkCommand(args []string) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+	line, col, insert, ok := linkWord(e.content[e.cursor.row], e.cursor.col, strings.Join(args, " "))
+	if !ok {
+		e.setStatus("No word under the cursor")
+		return
+	}
+	e.content[e.cursor.row], e.cursor.col = line, col
+	e.saved = false
+	if insert {
+		e.mode = ModeInsert
+	}
+}
+
+// metaCommand implements ":meta [KEY [VALUE]]", which lists the front
+// matter's fields, shows one or sets one
+func (e *DIYEditor) metaCommand(args string) {
+	content, line, msg, err := applyMeta(e.content, args)
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	if line >= 0 {
+		if e.readOnly {
+			e.setStatus(readOnlyMsg)
+			return
+		}
+		if e.cursor.row >= line {
+			e.cursor.row = max(line, e.cursor.row+len(content)-len(e.content))
+		}
+		e.content = content
+		e.saved = false
+		e.cursor.row = min(e.cursor.row, len(e.content)-1)
+		e.cursor.col = min(e.cursor.col, len(e.content[e.cursor.row]))
+		e.adjustViewport()
+	}
+	e.setStatus(msg)
+}
+
+// highlightCode colors code in lang for the terminal with Chroma
+func highlightCode(code, lang string) string {
+	var b strings.Builder
+	if err := quick.Highlight(&b, code, lang, "terminal256", "monokai"); err != nil {
+		return code
+	}
+	return b.String()
+}
+
+// formatCommand implements ":[range]Format"
+func (e *DIYEditor) formatCommand(cmd exCommand) {
+	if e.readOnly {
+		e.setStatus(readOnlyMsg)
+		return
+	}
+	from, to, err := cmd.lines(e.cursor.row, len(e.content))
+	if err != nil {
+		e.setStatus("Format: " + err.Error())
+		return
+	}
+	if e.formatBuffer(from, to) {
+		e.setStatus(fmt.Sprintf("Formatted lines %d-%d", from+1, to+1))
+	} else {
+		e.setStatus("Already formatted")
+	}
+}
+
+// formatBuffer formats lines from to to and reports whether that changed
+// anything
+func (e *DIYEditor) formatBuffer(from, to int) bool {
+	content, cursor := formatMarkdown(e.content, e.cursor, from, to, formatOptionsFor(e.config, e.lint))
+	if slices.Equal(content, e.content) {
+		return false
+	}
+	e.content = content
+	e.cursor = cursor
+	e.saved = false
+	e.adjustViewport()
+	return true
+}
+
+// reflowParagraph rewraps the paragraph under the cursor to word_wrap, as
+// gqap does in the Bubbletea version
+func (e *DIYEditor) reflowParagraph() {
+	if e.config.WordWrap <= 0 {
+		e.setStatus("Set word_wrap in the config file to reflow")
+		return
+	}
+	from, to, _ := reflowMotion(e.content, e.cursor, "ap")
+	content, cursor := reflowLines(e.content, e.cursor, from, to, e.config.WordWrap)
+	if !slices.Equal(content, e.content) {
+		e.content = content
+		e.saved = false
+	}
+	e.cursor = cursor
+	e.adjustViewport()
+}
+
+// statsCommand implements ":stats", showing the totals above the sections
+// in a picker that jumps to the chosen section's heading
+func (e *DIYEditor) statsCommand() {
+	s := documentStats(e.content)
+	items := make([]string, len(s.Sections))
+	sel := 0
+	for i, section := range s.Sections {
+		items[i] = fmt.Sprintf("%6d words  %s", section.Words, section.title())
+		if section.Line-1 <= e.cursor.row {
+			sel = i // the section the cursor is in
+		}
+	}
+	e.openPicker(fmt.Sprintf("Sections: %d", len(items)), items, sel, func(i int) {
+		e.jumpToLine("", s.Sections[i].Line-1)
+	})
+	e.picker.header = []string{
+		fmt.Sprintf("%d words, %d characters, %s read", s.Words, s.Characters, readingTime(s.Words)),
+		fmt.Sprintf("%d headings, %d links, %d images, %d code blocks", s.Headings, s.Links, s.Images, s.CodeBlocks),
+		fmt.Sprintf("%d sentences, %.1f words on average, the longest %d", s.Sentences, s.AverageWords, s.LongestWords),
+	}
+}
+
+// recheck brings the structure index up to date and, when the buffer has
+// changed, the diagnostics, front matter, misspellings and counts with it
+func (e *DIYEditor) recheck() {
+	if e.doc.update(e.content) {
+		e.relint()
+		e.checkFrontMatter()
+		e.respell()
+		e.recount()
+	}
+}
+
+// recount brings the word counts in line with the buffer and says when the
+// goal is reached
+func (e *DIYEditor) recount() {
+	e.counts = countText(e.content, e.doc, 0, len(e.content)-1)
+	if e.goal.checkGoal(e.counts.words, e.goalStart) {
+		e.setStatus("🎉 Goal reached: " + e.goal.describe())
+	}
+}
+
+// startWriting starts counting the words written in the buffer, which has
+// just been opened
+func (e *DIYEditor) startWriting() {
+	e.goalStart, e.recorded = e.counts.words, e.counts.words
+}
+
+// recordWriting adds the words written since the last save to the history
+func (e *DIYEditor) recordWriting() error {
+	words := countDocument(e.content).words
+	err := recordWriting(e.filename, e.recorded, words, e.goal.reached, time.Now())
+	e.recorded = words
+	return err
+}
+
+// goalCommand implements ":goal [session] WORDS", ":goal off" and ":goal"
+func (e *DIYEditor) goalCommand(args []string) {
+	if len(args) == 0 {
+		if e.goal.target == 0 {
+			e.setStatus("No goal (:goal WORDS or :goal session WORDS sets one)")
+		} else {
+			e.setStatus("Goal: " + e.goal.describe() + ", " + e.goal.bar(e.counts.words, e.goalStart))
+		}
+		return
+	}
+
+	goal, err := parseGoal(args)
+	if err != nil {
+		e.setStatus("Goal: " + err.Error())
+		return
+	}
+	e.goal = goal
+	switch {
+	case goal.target == 0:
+		e.setStatus("Goal cleared")
+	case !e.goal.checkGoal(e.counts.words, e.goalStart):
+		e.setStatus("Goal: " + goal.describe())
+	default:
+		e.setStatus("🎉 Goal reached: " + goal.describe())
+	}
+}
+
+// goalsCommand implements ":goals", showing the writing streak in the
+// status line
+func (e *DIYEditor) goalsCommand() {
+	history, err := loadHistory()
+	if err != nil {
+		e.setStatus("Goals: " + err.Error())
+		return
+	}
+	totals := history.totals()
+	if len(totals) == 0 {
+		e.setStatus("No writing recorded yet (words are counted when you save)")
+		return
+	}
+	current, best := streaks(totals, time.Now())
+	today := 0
+	if totals[0].date == time.Now().Format(time.DateOnly) {
+		today = totals[0].words
+	}
+	e.setStatus(fmt.Sprintf("Writing streak: %d days (best %d), %d words today", current, best, today))
+}
+
+// toggleZen turns zen mode on or off
+func (e *DIYEditor) toggleZen() {
+	e.zen = !e.zen
+	if e.zen {
+		e.setStatus("Zen mode (z or :zen to leave)")
+	} else {
+		e.setStatus("Zen mode off")
+		e.adjustViewport()
+	}
+}
+
+// focusCommand implements ":focus [paragraph|sentence|off]"; with no
+// argument it moves on to the next mode
+func (e *DIYEditor) focusCommand(args []string) {
+	mode := nextFocus(e.focus)
+	if len(args) > 0 {
+		var err error
+		if mode, err = parseFocus(args[0]); err != nil {
+			e.setStatus("Focus: " + err.Error())
+			return
+		}
+	}
+	e.focus = mode
+	if mode == focusOff {
+		e.setStatus("Focus off")
+	} else {
+		e.setStatus("Focus: " + mode)
+	}
+}
+
+// renderZen draws zen mode: the text alone in a column in the middle of the
+// terminal, with the cursor's line kept in the middle of the screen. The
+// last line shows the command line and status messages.
+func (e *DIYEditor) renderZen() {
+	width := zenWidth(e.config, e.width)
+	height := e.height - 1
+	margin := max(0, (e.width-width)/2)
+
+	rows, cursorRow, cursorCol := zenLayout(e.content, e.cursor, width, height)
+	from, to, focused := focusSpan(e.content, e.cursor, e.focus)
+	dim := func(text string) string { return "\033[2m" + text + "\033[0m" }
+	for i, row := range rows {
+		e.moveCursor(i+1, margin+1)
+		fmt.Print(zenRowText(e.content, row, from, to, focused, -1, "", dim))
+	}
+	if e.completions != nil {
+		e.renderPopup(nil, e.completions, e.completion, cursorRow+1, margin+cursorCol+1, height, e.width)
+	}
+
+	if time.Now().After(e.statusExpiry) {
+		e.statusMsg = ""
+	}
+	e.moveCursor(e.height, 1)
+	if e.mode == ModeCommand {
+		fmt.Printf(" :%s", e.commandLine)
+		e.moveCursor(e.height, len(e.commandLine)+3)
+	} else {
+		if e.statusMsg != "" {
+			fmt.Printf("\033[7m %s \033[0m", e.statusMsg)
+		}
+		e.moveCursor(cursorRow+1, margin+cursorCol+1)
+	}
+	e.showCursor()
+	if e.spellPopup.open {
+		e.renderSpellPopup(cursorRow+1, margin+cursorCol+1, height, e.width)
+	}
+	if e.picker.open {
+		e.renderPopup(e.picker.header, e.picker.items, e.picker.sel, 0, margin+1, height, e.width)
+	}
+}
+
+// loadSpell loads the dictionary when spell checking is on and reads the
+// word lists for the buffer's file, then checks the buffer
+func (e *DIYEditor) loadSpell() {
+	if !e.config.Spell {
+		e.spell, e.misspellings = nil, nil
+		return
+	}
+	if e.spell == nil {
+		spell, err := newSpellChecker(e.config, e.filename)
+		if spell == nil {
+			e.setStatus("Spell: " + err.Error())
+			e.config.Spell = false
+			return
+		}
+		e.spell = spell
+		if err != nil {
+			e.setStatus("Spell: " + err.Error())
+		}
+	} else if err := e.spell.loadWords(e.filename); err != nil {
+		e.setStatus("Spell: " + err.Error())
+	}
+	e.respell()
+}
+
+// respell brings the misspellings in line with the buffer
+func (e *DIYEditor) respell() {
+	if e.spell != nil {
+		e.misspellings = e.spell.check(e.content, e.doc)
+	}
+}
+
+// gotoMisspelling moves to the next or previous misspelled word, like ]s
+// and [s in the Bubbletea version
+func (e *DIYEditor) gotoMisspelling(dir int) {
+	if e.spell == nil {
+		e.setStatus("Spell checking is off (:set spell)")
+		return
+	}
+	found, ok := nextMisspelling(e.misspellings, e.cursor, dir)
+	if !ok {
+		e.setStatus("No misspelled words")
+		return
+	}
+	e.cursor = Position{found.Line, found.Col}
+	e.adjustViewport()
+}
+
+// suggestSpelling implements z= (= here), which opens the suggestions for
+// the word under the cursor
+func (e *DIYEditor) suggestSpelling() {
+	if e.spell == nil {
+		e.setStatus("Spell checking is off (:set spell)")
+		return
+	}
+	word, start, end, ok := wordAt(e.content[e.cursor.row], e.cursor.col)
+	if !ok {
+		e.setStatus("No word under the cursor")
+		return
+	}
+	suggestions := e.spell.suggest(word)
+	if len(suggestions) == 0 {
+		e.setStatus(fmt.Sprintf("No suggestions for %q", word))
+		return
+	}
+	e.spellPopup = spellPopup{
+		open:        true,
+		word:        misspelling{Line: e.cursor.row, Col: start, End: end, Word: word},
+		suggestions: suggestions,
+	}
+}
+
+// handleSpellPopup chooses a suggestion with j/k and Enter, or 1-9; Esc
+// (handled in Run) or q keeps the word
+func (e *DIYEditor) handleSpellPopup(key byte) {
+	p := &e.spellPopup
+	switch {
+	case key == 'j' || key == 14: // Ctrl+N
+		p.sel = min(p.sel+1, len(p.suggestions)-1)
+	case key == 'k' || key == 16: // Ctrl+P
+		p.sel = max(p.sel-1, 0)
+	case key == 13: // Enter
+		e.replaceMisspelling(p.suggestions[p.sel])
+	case key == 'q':
+		p.open = false
+	case key >= '1' && int(key-'0') <= len(p.suggestions):
+		e.replaceMisspelling(p.suggestions[key-'1'])
+	}
+}
+
+// replaceMisspelling puts the chosen suggestion in place of the word and
+// closes the suggestions
+func (e *DIYEditor) replaceMisspelling(suggestion string) {
+	w := e.spellPopup.word
+	e.spellPopup = spellPopup{}
+	line := e.content[w.Line]
+	if w.End > len(line) || line[w.Col:w.End] != w.Word {
+		e.setStatus("The word has changed")
+		return
+	}
+	e.content[w.Line] = line[:w.Col] + suggestion + line[w.End:]
+	e.cursor = Position{w.Line, w.Col}
+	e.saved = false
+	e.adjustViewport()
+}
+
+// renderSpellPopup draws the suggestions, numbered, under the word at screen
+// row and col
+func (e *DIYEditor) renderSpellPopup(row, col, bottom, right int) {
+	p := e.spellPopup
+	items := make([]string, len(p.suggestions))
+	for i, s := range p.suggestions {
+		items[i] = fmt.Sprintf("%d %s", i+1, s)
+	}
+	e.renderPopup(nil, items, p.sel, row, col, bottom, right)
+}
+
+// popupRows is the most items a popup shows at once; it scrolls to keep
+// the selection in view
+const popupRows = 10
+
+// renderPopup draws items below header under screen row from column col,
+// or above row when there is no room down to row bottom, with sel
+// highlighted. They end at column right at most.
+func (e *DIYEditor) renderPopup(header, items []string, sel, row, col, bottom, right int) {
+	first := max(0, min(sel-popupRows/2, len(items)-popupRows))
+	shown := append(slices.Clip(header), items[first:min(len(items), first+popupRows)]...)
+	width := 0
+	for _, item := range shown {
+		width = max(width, ansi.StringWidth(item)+2)
+	}
+	width = min(width, right)
+
+	if row+len(shown) <= bottom {
+		row++
+	} else {
+		row = max(1, row-len(shown))
+	}
+	col = max(1, min(col, right-width+1))
+	for i, item := range shown {
+		e.moveCursor(row+i, col)
+		item = ansi.Truncate(" "+item, width-1, "…") + " "
+		item += strings.Repeat(" ", width-ansi.StringWidth(item))
+		switch {
+		case i < len(header):
+			fmt.Printf("\033[1;48;5;236m%s\033[0m", item)
+		case first+i-len(header) == sel:
+			fmt.Printf("\033[7m%s\033[0m", item)
+		default:
+			fmt.Printf("\033[48;5;236m%s\033[0m", item)
+		}
+	}
+	e.hideCursor()
+}
+
+// picker is a list over the editor that goes to the line of the chosen
+// item: j/k or Ctrl+N/Ctrl+P move, Enter chooses, q or Esc (handled in Run)
+// closes it
+type picker struct {
+	open   bool
+	header []string // lines above the items, which can't be chosen
+	items  []string
+	sel    int
+	pick   func(sel int)
+}
+
+// openPicker shows items, with title in the status line
+func (e *DIYEditor) openPicker(title string, items []string, sel int, pick func(sel int)) {
+	e.picker = picker{open: true, items: items, sel: sel, pick: pick}
+	e.activeTab = TabEditor
+	e.setStatus(title)
+}
+
+// handlePicker moves the picker's selection or chooses it
+func (e *DIYEditor) handlePicker(key byte) {
+	p := &e.picker
+	switch key {
+	case 'j', 14: // Ctrl+N
+		p.sel = min(p.sel+1, len(p.items)-1)
+	case 'k', 16: // Ctrl+P
+		p.sel = max(p.sel-1, 0)
+	case 'g':
+		p.sel = 0
+	case 'G':
+		p.sel = len(p.items) - 1
+	case 13: // Enter
+		pick, sel, n := p.pick, p.sel, len(p.items)
+		e.picker = picker{}
+		if sel < n {
+			pick(sel)
+		}
+	case 'q':
+		e.picker = picker{}
+	}
+}
+
+// complete implements Ctrl+N and Ctrl+P in insert mode, which complete the
+// word, link target or anchor before the cursor. A single candidate is put
+// in place at once; several open the popup.
+func (e *DIYEditor) complete(dir int) {
+	ctx := completionAt(e.content[e.cursor.row], e.cursor.col)
+	items := completionCandidates(e.content, e.cursor, e.filename, ctx)
+	switch len(items) {
+	case 0:
+		e.setStatus("No completions")
+		return
+	case 1:
+		e.completing, e.completions, e.completion = ctx, items, 0
+		e.acceptCompletion()
+		return
+	}
+	e.completing, e.completions, e.completion = ctx, items, 0
+	if dir < 0 {
+		e.completion = len(items) - 1
+	}
+}
+
+// completionKey moves the popup's selection, accepts it or closes it with
+// Ctrl+E; false means key goes on to insert mode
+func (e *DIYEditor) completionKey(key byte) bool {
+	n := len(e.completions)
+	switch key {
+	case 14: // Ctrl+N
+		e.completion = (e.completion + 1) % n
+	case 16: // Ctrl+P
+		e.completion = (e.completion + n - 1) % n
+	case 13, 9: // Enter, Tab
+		e.acceptCompletion()
+	case 5: // Ctrl+E
+		e.completions = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// acceptCompletion puts the selected candidate in place. A directory keeps
+// the popup open on its contents.
+func (e *DIYEditor) acceptCompletion() {
+	text := e.completions[e.completion]
+	e.content[e.cursor.row], e.cursor.col = applyCompletion(e.content[e.cursor.row], e.cursor.col, e.completing, text)
+	e.saved = false
+	e.completions = nil
+	if strings.HasSuffix(text, "/") {
+		e.refreshCompletion(true)
+	}
+	e.adjustViewport()
+}
+
+// refreshCompletion filters the open popup by what has been typed since it
+// opened, closing it once nothing matches or the cursor leaves the text it
+// completes. open opens it instead, if there is something to offer.
+func (e *DIYEditor) refreshCompletion(open bool) {
+	if e.mode != ModeInsert || (e.completions == nil && !open) {
+		e.completions = nil
+		return
+	}
+	ctx := completionAt(e.content[e.cursor.row], e.cursor.col)
+	if !open && (ctx.kind != e.completing.kind || ctx.start != e.completing.start) {
+		e.completions = nil
+		return
+	}
+	items := completionCandidates(e.content, e.cursor, e.filename, ctx)
+	if len(items) == 0 {
+		e.completions = nil
+		return
+	}
+	e.completing, e.completions, e.completion = ctx, items, 0
+}
+
+// loadSnippets loads the snippets for the buffer's file type
+func (e *DIYEditor) loadSnippets() {
+	snippets, err := loadSnippets(e.filename)
+	e.snippets = snippets
+	if err != nil {
+		e.setStatus("Snippets: " + err.Error())
+	}
+}
+
+// snippetTab handles Tab and Shift+Tab in insert mode: in an expanded
+// snippet they move between its fields, and otherwise Tab expands the
+// snippet whose prefix is before the cursor
+func (e *DIYEditor) snippetTab(dir int) bool {
+	if e.activeTab != TabEditor || e.mode != ModeInsert || e.readOnly {
+		return false
+	}
+	if e.snippet != nil {
+		pos, ok := e.snippet.jump(e.content, dir)
+		if !ok {
+			e.snippet = nil
+		}
+		e.cursor = pos
+		e.adjustViewport()
+		return true
+	}
+	if dir < 0 {
+		return false
+	}
+	s, start, ok := findSnippet(e.snippets, e.content[e.cursor.row], e.cursor.col)
+	if !ok {
+		return false
+	}
+	e.content, e.cursor, e.snippet = expandSnippet(e.content, e.cursor, start, s, func(name string) (string, bool) {
+		return snippetVariable(name, e.filename, time.Now(), e.getClipboard)
+	})
+	e.saved = false
+	e.adjustViewport()
+	return true
+}
+
+// followSnippet copies what was typed in a snippet's field to its mirrors,
+// leaving the snippet behind once a key leaves insert mode, edits outside
+// the field or moves off the snippet's lines
+func (e *DIYEditor) followSnippet() {
+	if e.snippet == nil {
+		return
+	}
+	cursor, ok := e.snippet.follow(e.content, e.cursor)
+	if !ok || e.mode != ModeInsert {
+		e.snippet = nil
+		return
+	}
+	e.cursor = cursor
+}
+
+// goodWord adds the word under the cursor, or the one given to
+// ":spellgood", to the project's or the user's word list
+func (e *DIYEditor) goodWord(args []string) {
+	if e.spell == nil {
+		e.setStatus("Spell checking is off (:set spell)")
+		return
+	}
+	word := strings.Join(args, " ")
+	if word == "" {
+		var ok bool
+		if word, _, _, ok = wordAt(e.content[e.cursor.row], e.cursor.col); !ok {
+			e.setStatus("No word under the cursor")
+			return
+		}
+	}
+	path, err := e.spell.addWord(word)
+	if err != nil {
+		e.setStatus("Spell: " + err.Error())
+		return
+	}
+	e.respell()
+	e.setStatus(fmt.Sprintf("Added %q to %s", word, path))
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Heading is an ATX ("## Title") or setext (underlined) heading
+type Heading struct {
+	Line  int    // 0-based line of the heading text
+	Level int    // 1-6
+	Text  string // heading source without markers, inline markdown intact
+}
+
+// Title returns the heading as a reader sees it, without inline markdown
+func (h Heading) Title() string {
+	return stripInlineMarkdown(h.Text)
+}
+
+// CodeBlock represents a fenced code block's location and language
+type CodeBlock struct {
+	start int    // line of the opening fence
+	end   int    // line of the closing fence, or the last line if unclosed
+	lang  string // first word of the info string
+}
+
+var (
+	atxHeadingRe      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderlineRe = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceOpenRe       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+	blockStartRe      = regexp.MustCompile(`^ {0,3}([-*+>]|\d+[.)])([ \t]|$)`)
+)
+
+// fenceState is the fenced-code state at the start of a line
+type fenceState struct {
+	open        bool
+	marker      string // opening fence, e.g. "```" or "~~~~"
+	start       int
+	lang        string
+	frontMatter bool // inside a "---" or "+++" block at the top of the file
+}
+
+// docStructure indexes a document's headings, tasks, fenced code blocks and
+// front matter. It keeps the lines it was built from and the fence state at
+// each line, so update only rescans from the first line that changed.
+type docStructure struct {
+	lines      []string
+	fences     []fenceState
+	headings   []Heading
+	tasks      []Task
+	codeBlocks []CodeBlock
+
+	frontMatterEnd int // line closing the front matter, 0 if there is none
+}
+
+func newDocStructure() *docStructure {
+	return &docStructure{}
+}
+
+// update brings the index in line with content and reports whether it changed
+func (d *docStructure) update(content []string) bool {
+	first := 0
+	for first < len(d.lines) && first < len(content) && d.lines[first] == content[first] {
+		first++
+	}
+	if first == len(d.lines) && first == len(content) {
+		return false
+	}
+
+	// Editing a line can turn the line above it into a setext heading or back
+	first = max(0, first-1)
+
+	// Closing an unterminated front matter block anywhere changes everything
+	if len(d.lines) > 0 && (d.lines[0] == "---" || d.lines[0] == "+++") && d.frontMatterEnd == 0 {
+		first = 0
+	}
+
+	keep := 0
+	for keep < len(d.headings) && d.headings[keep].Line < first {
+		keep++
+	}
+	d.headings = d.headings[:keep]
+
+	keep = 0
+	for keep < len(d.tasks) && d.tasks[keep].Line < first {
+		keep++
+	}
+	d.tasks = d.tasks[:keep]
+
+	keep = 0
+	for keep < len(d.codeBlocks) && d.codeBlocks[keep].end < first {
+		keep++
+	}
+	d.codeBlocks = d.codeBlocks[:keep]
+
+	if d.frontMatterEnd >= first {
+		d.frontMatterEnd = 0
+	}
+
+	var state fenceState
+	if first < len(d.fences) {
+		state = d.fences[first]
+	}
+	d.fences = d.fences[:min(first, len(d.fences))]
+	d.lines = append(d.lines[:first], content[first:]...)
+
+	d.scan(first, state)
+	return true
+}
+
+// scan indexes d.lines from line from onwards, starting in the given fence state
+func (d *docStructure) scan(from int, state fenceState) {
+	for i := from; i < len(d.lines); i++ {
+		d.fences = append(d.fences, state)
+		line := d.lines[i]
+
+		if state.open {
+			if state.frontMatter && strings.TrimRight(line, " \t") == state.marker {
+				d.frontMatterEnd = i
+				state = fenceState{}
+			} else if !state.frontMatter && closesFence(line, state.marker) {
+				d.codeBlocks = append(d.codeBlocks, CodeBlock{start: state.start, end: i, lang: state.lang})
+				state = fenceState{}
+			}
+			continue
+		}
+
+		// YAML ("---") or TOML ("+++") front matter must start on the first line
+		if i == 0 && (line == "---" || line == "+++") {
+			state = fenceState{open: true, marker: line, frontMatter: true}
+			continue
+		}
+
+		if match := fenceOpenRe.FindStringSubmatch(line); match != nil {
+			// Backtick fences can't have backticks in their info string
+			if match[1][0] != '`' || !strings.Contains(match[2], "`") {
+				lang, _, _ := strings.Cut(strings.TrimSpace(match[2]), " ")
+				state = fenceState{open: true, marker: match[1], start: i, lang: lang}
+				continue
+			}
+		}
+
+		if item, ok := parseListItem(line); ok && item.task {
+			d.tasks = append(d.tasks, Task{Line: i, Done: item.done, Text: strings.TrimSpace(line[item.length:])})
+			continue
+		}
+
+		if match := atxHeadingRe.FindStringSubmatch(line); match != nil {
+			d.headings = append(d.headings, Heading{Line: i, Level: len(match[1]), Text: strings.TrimSpace(match[2])})
+			continue
+		}
+
+		if match := setextUnderlineRe.FindStringSubmatch(line); match != nil && i > 0 && d.isParagraphLine(i-1) {
+			level := 1
+			if match[1][0] == '-' {
+				level = 2
+			}
+			d.headings = append(d.headings, Heading{Line: i - 1, Level: level, Text: strings.TrimSpace(d.lines[i-1])})
+		}
+	}
+
+	switch {
+	case state.open && state.frontMatter:
+		// Without a closing line, a "---" first line is a thematic break
+		d.fences = d.fences[:1]
+		d.scan(1, fenceState{})
+	case state.open:
+		d.codeBlocks = append(d.codeBlocks, CodeBlock{start: state.start, end: len(d.lines) - 1, lang: state.lang})
+	}
+}
+
+// isParagraphLine reports whether line i is plain paragraph text, which a
+// following "===" or "---" line turns into a setext heading
+func (d *docStructure) isParagraphLine(i int) bool {
+	line := d.lines[i]
+	if strings.TrimSpace(line) == "" || d.fences[i].open || strings.HasPrefix(line, "    ") {
+		return false
+	}
+	if fenceOpenRe.MatchString(line) || atxHeadingRe.MatchString(line) || setextUnderlineRe.MatchString(line) {
+		return false
+	}
+	if blockStartRe.MatchString(line) {
+		return false
+	}
+	// A heading already ending here (e.g. "Title\n===\n---") isn't paragraph text
+	if n := len(d.headings); n > 0 && d.headings[n-1].Line == i {
+		return false
+	}
+	return true
+}
+
+// closesFence reports whether line closes a fence opened with marker
+func closesFence(line, marker string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	run := len(trimmed) - len(strings.TrimLeft(trimmed, marker[:1]))
+	return run >= len(marker) && strings.TrimSpace(trimmed[run:]) == ""
+}
+
+// frontMatter returns the lines of the front matter block, delimiters
+// included, if the document starts with one
+func (d *docStructure) frontMatter() (start, end int, ok bool) {
+	return 0, d.frontMatterEnd, d.frontMatterEnd > 0
+}
+
+// sectionAt returns the index of the heading whose section contains line,
+// or -1 when line comes before the first heading
+func (d *docStructure) sectionAt(line int) int {
+	section := -1
+	for i, h := range d.headings {
+		if h.Line > line {
+			break
+		}
+		section = i
+	}
+	return section
+}
+
+// codeBlockAt returns the fenced code block containing line, fences included
+func (d *docStructure) codeBlockAt(line int) (CodeBlock, bool) {
+	for _, block := range d.codeBlocks {
+		if block.start > line {
+			break
+		}
+		if line <= block.end {
+			return block, true
+		}
+	}
+	return CodeBlock{}, false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exCommand is a parsed ":" command line such as "w! notes.md"
+type exCommand struct {
+	rng  string   // line range before the name, e.g. "%" or "3,10"
+	name string   // command name, e.g. "w"
+	bang bool     // whether the name was followed by "!"
+	rest string   // raw argument text after the name
+	args []string // rest split on whitespace
+}
+
+// parseExCommand splits a command line into name, bang and arguments.
+// The name is the leading run of letters, so ":w!", ":w !cmd" and ":wq"
+// all parse the way they do in vim.
+func parseExCommand(line string) exCommand {
+	line = strings.TrimSpace(line)
+
+	// A range such as "%", "." or "3,$" may come first
+	start := strings.IndexFunc(line, func(r rune) bool { return !strings.ContainsRune("0123456789.,$% ", r) })
+	if start < 0 {
+		start = len(line)
+	}
+	rng := strings.ReplaceAll(line[:start], " ", "")
+	line = line[start:]
+
+	end := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(line)
+	}
+
+	cmd := exCommand{rng: rng, name: line[:end]}
+	rest := line[end:]
+	if strings.HasPrefix(rest, "!") {
+		cmd.bang = true
+		rest = rest[1:]
+	}
+	cmd.rest = strings.TrimSpace(rest)
+	cmd.args = strings.Fields(cmd.rest)
+	return cmd
+}
+
+// lines resolves the command's range to 0-based lines in a buffer of total
+// lines with the cursor on row. "%" is every line, "." the cursor's and "$"
+// the last; line numbers count from 1. Without a range it is all lines.
+func (c exCommand) lines(row, total int) (from, to int, err error) {
+	if c.rng == "" || c.rng == "%" {
+		return 0, total - 1, nil
+	}
+
+	address := func(s string) (int, error) {
+		switch s {
+		case ".":
+			return row, nil
+		case "$":
+			return total - 1, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > total {
+			return 0, fmt.Errorf("invalid range %s", c.rng)
+		}
+		return n - 1, nil
+	}
+
+	first, last, found := strings.Cut(c.rng, ",")
+	if from, err = address(first); err != nil {
+		return 0, 0, err
+	}
+	to = from
+	if found {
+		if to, err = address(last); err != nil {
+			return 0, 0, err
+		}
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return from, to, nil
+}
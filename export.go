@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExportOptions carries the settings every exporter may draw on
+type ExportOptions struct {
+	// Title names the document; exporters fall back to the first heading
+	Title string
+	// Theme is the glamour theme name or JSON style path (Config.Theme)
+	Theme string
+	// Width is the wrap width for text-based formats (Config.WordWrap)
+	Width int
+}
+
+// Exporter converts a markdown document into another format
+type Exporter interface {
+	// Extension is the default file extension, including the leading dot
+	Extension() string
+	// Export writes the converted document to w
+	Export(w io.Writer, source []byte, opts ExportOptions) error
+}
+
+// exporters lists the formats understood by `hani export` and `:export`
+var exporters = map[string]Exporter{
+	"html": htmlExporter{},
+	"ansi": ansiExporter{},
+	"text": textExporter{},
+	"man":  roffExporter{},
+	"roff": roffExporter{},
+}
+
+// exportFormats returns the registered format names in sorted order
+func exportFormats() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupExporter finds an exporter by format name
+func lookupExporter(format string) (Exporter, error) {
+	exporter, ok := exporters[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (available: %s)",
+			format, strings.Join(exportFormats(), ", "))
+	}
+	return exporter, nil
+}
+
+// exportTarget picks the output path for an export of sourceName when none
+// was given: the source with its extension swapped, or "untitled" + ext.
+func exportTarget(sourceName string, exporter Exporter) string {
+	if sourceName == "" || sourceName == "-" {
+		return "untitled" + exporter.Extension()
+	}
+	return strings.TrimSuffix(sourceName, filepath.Ext(sourceName)) + exporter.Extension()
+}
+
+// exportBuffer converts editor content and writes it to target, choosing a
+// default target from sourceName when target is empty. It returns the path written.
+func exportBuffer(format string, content []string, sourceName, target string, opts ExportOptions) (string, error) {
+	exporter, err := lookupExporter(format)
+	if err != nil {
+		return "", err
+	}
+
+	if target == "" {
+		target = exportTarget(sourceName, exporter)
+	}
+	if target == sourceName {
+		return "", fmt.Errorf("refusing to overwrite the source file %s", target)
+	}
+
+	if opts.Title == "" {
+		opts.Title = documentTitle(content, sourceName)
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, []byte(strings.Join(content, "\n")), opts); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// runExport implements `hani export`, writing the converted document to
+// stdout or to the file named by -o
+func runExport(args []string) int {
+	config := LoadConfig()
+
+	fs := newSubcommandFlags("export", "hani export [--format FORMAT] [-o FILE] FILE|-")
+	format := fs.String("format", "html", "output `format`: "+strings.Join(exportFormats(), ", "))
+	output := fs.String("o", "", "write to `FILE` instead of stdout")
+	title := fs.String("title", "", "document `title` (default: first heading)")
+	theme := fs.String("theme", config.Theme, "glamour `theme` the styling is derived from")
+	width := fs.Int("width", config.WordWrap, "wrap ansi and text output at `N` columns")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	exporter, err := lookupExporter(*format)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	source, err := readDocument(files[0])
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	opts := ExportOptions{Title: *title, Theme: *theme, Width: *width}
+	if opts.Title == "" {
+		opts.Title = documentTitle(strings.Split(string(source), "\n"), files[0])
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Export(&buf, source, opts); err != nil {
+		return fatalf("%v", err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*output, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	return 0
+}
+
+// documentTitle returns the front matter's title, or else the text of the
+// first level-one heading, or the file's base name without extension when
+// there is neither
+func documentTitle(lines []string, filename string) string {
+	if title, ok := frontMatterTitle(lines); ok {
+		return title
+	}
+	doc := newDocStructure()
+	doc.update(lines)
+	for _, h := range doc.headings {
+		if h.Level == 1 {
+			return stripInlineMarkdown(h.Text)
+		}
+	}
+	if filename == "" || filename == "-" {
+		return ""
+	}
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/glamour/ansi"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// htmlExporter writes a self-contained HTML page styled after the active theme
+type htmlExporter struct{}
+
+func (htmlExporter) Extension() string { return ".html" }
+
+func (htmlExporter) Export(w io.Writer, source []byte, opts ExportOptions) error {
+	css, err := themeCSS(opts.Theme)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := convertHTML(&body, source, false); err != nil {
+		return err
+	}
+
+	title := opts.Title
+	if title == "" {
+		title = documentTitle(strings.Split(string(source), "\n"), "")
+	}
+
+	_, err = fmt.Fprintf(w, htmlPageTemplate, html.EscapeString(title), css, body.String())
+	return err
+}
+
+const htmlPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+<main class="markdown-body">
+%s</main>
+</body>
+</html>
+`
+
+// newHTMLConverter builds the goldmark pipeline used for HTML output:
+// GFM tables, task lists, strikethrough and autolinks, footnotes, GitHub-style
+// heading anchors, and Chroma highlighting for code blocks. With sourceLines,
+// headings carry a data-line attribute holding their 0-based source line.
+func newHTMLConverter(sourceLines bool) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(
+			goldmarkhtml.WithUnsafe(),
+			renderer.WithNodeRenderers(util.Prioritized(newHTMLBlockRenderer(sourceLines), 100)),
+		),
+	)
+}
+
+// convertHTML renders the markdown body (without the page wrapper) to w,
+// leaving out the front matter
+func convertHTML(w io.Writer, source []byte, sourceLines bool) error {
+	ctx := parser.NewContext(parser.WithIDs(headingIDs{newSlugger()}))
+	source = []byte(strings.Join(blankFrontMatter(strings.Split(string(source), "\n")), "\n"))
+	return newHTMLConverter(sourceLines).Convert(source, w, parser.WithContext(ctx))
+}
+
+// headingIDs adapts slugger to goldmark so anchors match GitHub's
+type headingIDs struct {
+	slugs *slugger
+}
+
+func (ids headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	return []byte(ids.slugs.slug(string(value)))
+}
+
+func (ids headingIDs) Put(value []byte) {
+	ids.slugs.occurrences[string(value)] = 0
+}
+
+// htmlBlockRenderer overrides goldmark's rendering of headings (to add anchor
+// links) and fenced code (to highlight it with Chroma's HTML formatter)
+type htmlBlockRenderer struct {
+	formatter   *chromahtml.Formatter
+	sourceLines bool
+}
+
+func newHTMLBlockRenderer(sourceLines bool) *htmlBlockRenderer {
+	return &htmlBlockRenderer{
+		formatter:   chromahtml.New(chromahtml.WithClasses(true), chromahtml.TabWidth(4)),
+		sourceLines: sourceLines,
+	}
+}
+
+func (r *htmlBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCode)
+}
+
+func (r *htmlBlockRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	if !entering {
+		fmt.Fprintf(w, "</h%d>\n", n.Level)
+		return ast.WalkContinue, nil
+	}
+
+	fmt.Fprintf(w, "<h%d", n.Level)
+	if n.Attributes() != nil {
+		goldmarkhtml.RenderAttributes(w, node, goldmarkhtml.HeadingAttributeFilter)
+	}
+	if r.sourceLines && n.Lines().Len() > 0 {
+		fmt.Fprintf(w, ` data-line="%d"`, bytes.Count(source[:n.Lines().At(0).Start], []byte("\n")))
+	}
+	w.WriteByte('>')
+	if id, ok := n.AttributeString("id"); ok {
+		if id, ok := id.([]byte); ok {
+			fmt.Fprintf(w, `<a class="anchor" href="#%s" aria-hidden="true">#</a>`, html.EscapeString(string(id)))
+		}
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *htmlBlockRenderer) renderFencedCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	var code strings.Builder
+	lines := n.Lines()
+	for i := range lines.Len() {
+		segment := lines.At(i)
+		code.Write(segment.Value(source))
+	}
+
+	lang := string(n.Language(source))
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", html.EscapeString(code.String()))
+		return ast.WalkSkipChildren, nil
+	}
+	if err := r.formatter.Format(w, chromastyles.Fallback, iterator); err != nil {
+		return ast.WalkStop, err
+	}
+	w.WriteByte('\n')
+	return ast.WalkSkipChildren, nil
+}
+
+// themeCSS derives the page stylesheet from a glamour theme. The "auto"
+// theme follows the browser instead of the terminal: light by default, dark
+// under prefers-color-scheme.
+func themeCSS(theme string) (string, error) {
+	var b strings.Builder
+	b.WriteString(baseHTMLCSS)
+
+	if theme == "" || theme == "auto" {
+		if err := writeStyleCSS(&b, glamourstyles.LightStyleConfig); err != nil {
+			return "", err
+		}
+		var dark strings.Builder
+		if err := writeStyleCSS(&dark, glamourstyles.DarkStyleConfig); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "@media (prefers-color-scheme: dark) {\n%s}\n", dark.String())
+		return b.String(), nil
+	}
+
+	config, err := loadGlamourStyle(theme)
+	if err != nil {
+		return "", err
+	}
+	if err := writeStyleCSS(&b, config); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// loadGlamourStyle resolves a built-in glamour style name or a JSON style file
+func loadGlamourStyle(theme string) (ansi.StyleConfig, error) {
+	if config, ok := glamourstyles.DefaultStyles[theme]; ok {
+		return *config, nil
+	}
+
+	data, err := os.ReadFile(theme)
+	if err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("unknown theme %q: %w", theme, err)
+	}
+	var config ansi.StyleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("invalid theme %s: %w", theme, err)
+	}
+	return config, nil
+}
+
+// baseHTMLCSS holds the layout rules that don't depend on the theme
+const baseHTMLCSS = `.markdown-body { max-width: 52rem; margin: 0 auto; padding: 2rem 1rem; font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+.markdown-body pre { padding: 0.8rem 1rem; overflow-x: auto; border-radius: 6px; }
+.markdown-body code, .markdown-body pre { font-family: ui-monospace, "SF Mono", Menlo, Consolas, monospace; font-size: 0.9em; }
+.markdown-body :not(pre) > code { padding: 0.1em 0.35em; border-radius: 4px; }
+.markdown-body table { border-collapse: collapse; }
+.markdown-body th, .markdown-body td { border: 1px solid rgba(128, 128, 128, 0.4); padding: 0.3rem 0.7rem; }
+.markdown-body blockquote { margin-left: 0; padding-left: 1rem; border-left: 4px solid rgba(128, 128, 128, 0.4); }
+.markdown-body img { max-width: 100%; }
+.markdown-body li:has(> input[type="checkbox"]) { list-style: none; }
+.markdown-body .anchor { float: left; margin-left: -1.2em; padding-right: 0.2em; text-decoration: none; visibility: hidden; }
+.markdown-body :is(h1, h2, h3, h4, h5, h6):hover .anchor { visibility: visible; }
+.markdown-body .footnotes { font-size: 0.9em; }
+`
+
+// writeStyleCSS translates a glamour style config into CSS rules, including
+// the Chroma classes used by highlighted code blocks
+func writeStyleCSS(b *strings.Builder, config ansi.StyleConfig) error {
+	doc := config.Document.StylePrimitive
+	background := cssColor(doc.BackgroundColor)
+	if background == "" {
+		background = "#ffffff"
+		if isLightColor(cssColor(doc.Color)) {
+			background = "#1e1e1e"
+		}
+	}
+	writeCSSRule(b, "body", doc, "background-color: "+background)
+	writeCSSRule(b, ".markdown-body :is(h1, h2, h3, h4, h5, h6)", config.Heading.StylePrimitive)
+	for level, heading := range []ansi.StyleBlock{config.H1, config.H2, config.H3, config.H4, config.H5, config.H6} {
+		extra := ""
+		if heading.BackgroundColor != nil {
+			extra = "display: inline-block; padding: 0 0.3em"
+		}
+		writeCSSRule(b, fmt.Sprintf(".markdown-body h%d", level+1), heading.StylePrimitive, extra)
+	}
+	writeCSSRule(b, ".markdown-body a", config.Link)
+	writeCSSRule(b, ".markdown-body strong", config.Strong)
+	writeCSSRule(b, ".markdown-body em", config.Emph)
+	writeCSSRule(b, ".markdown-body del", config.Strikethrough)
+	writeCSSRule(b, ".markdown-body blockquote", config.BlockQuote.StylePrimitive)
+	writeCSSRule(b, ".markdown-body hr", config.HorizontalRule)
+	writeCSSRule(b, ".markdown-body :not(pre) > code", config.Code.StylePrimitive)
+
+	style, err := codeStyle(config.CodeBlock)
+	if err != nil {
+		return err
+	}
+	return chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(b, style)
+}
+
+// writeCSSRule emits one CSS rule for a glamour style primitive, skipping it
+// when the primitive sets nothing
+func writeCSSRule(b *strings.Builder, selector string, p ansi.StylePrimitive, extra ...string) {
+	var decls []string
+	if color := cssColor(p.Color); color != "" {
+		decls = append(decls, "color: "+color)
+	}
+	if color := cssColor(p.BackgroundColor); color != "" {
+		decls = append(decls, "background-color: "+color)
+	}
+	if p.Bold != nil {
+		decls = append(decls, "font-weight: "+map[bool]string{true: "bold", false: "normal"}[*p.Bold])
+	}
+	if p.Italic != nil && *p.Italic {
+		decls = append(decls, "font-style: italic")
+	}
+	if p.Underline != nil {
+		decls = append(decls, "text-decoration: "+map[bool]string{true: "underline", false: "none"}[*p.Underline])
+	}
+	if p.CrossedOut != nil && *p.CrossedOut {
+		decls = append(decls, "text-decoration: line-through")
+	}
+	for _, decl := range extra {
+		if decl != "" {
+			decls = append(decls, decl)
+		}
+	}
+	if len(decls) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s { %s; }\n", selector, strings.Join(decls, "; "))
+}
+
+// codeStyle returns the Chroma style for code blocks: the block's named
+// theme, its inline Chroma palette, or a plain fallback
+func codeStyle(block ansi.StyleCodeBlock) (*chroma.Style, error) {
+	if block.Theme != "" {
+		return chromastyles.Get(block.Theme), nil
+	}
+	if block.Chroma == nil {
+		return chromastyles.Get("github"), nil
+	}
+
+	c := block.Chroma
+	palette := []struct {
+		token chroma.TokenType
+		style ansi.StylePrimitive
+	}{
+		{chroma.Text, c.Text},
+		{chroma.Error, c.Error},
+		{chroma.Comment, c.Comment},
+		{chroma.CommentPreproc, c.CommentPreproc},
+		{chroma.Keyword, c.Keyword},
+		{chroma.KeywordReserved, c.KeywordReserved},
+		{chroma.KeywordNamespace, c.KeywordNamespace},
+		{chroma.KeywordType, c.KeywordType},
+		{chroma.Operator, c.Operator},
+		{chroma.Punctuation, c.Punctuation},
+		{chroma.Name, c.Name},
+		{chroma.NameBuiltin, c.NameBuiltin},
+		{chroma.NameTag, c.NameTag},
+		{chroma.NameAttribute, c.NameAttribute},
+		{chroma.NameClass, c.NameClass},
+		{chroma.NameConstant, c.NameConstant},
+		{chroma.NameDecorator, c.NameDecorator},
+		{chroma.NameException, c.NameException},
+		{chroma.NameFunction, c.NameFunction},
+		{chroma.NameOther, c.NameOther},
+		{chroma.Literal, c.Literal},
+		{chroma.LiteralNumber, c.LiteralNumber},
+		{chroma.LiteralDate, c.LiteralDate},
+		{chroma.LiteralString, c.LiteralString},
+		{chroma.LiteralStringEscape, c.LiteralStringEscape},
+		{chroma.GenericDeleted, c.GenericDeleted},
+		{chroma.GenericEmph, c.GenericEmph},
+		{chroma.GenericInserted, c.GenericInserted},
+		{chroma.GenericStrong, c.GenericStrong},
+		{chroma.GenericSubheading, c.GenericSubheading},
+		{chroma.Background, c.Background},
+	}
+
+	entries := chroma.StyleEntries{}
+	for _, p := range palette {
+		var parts []string
+		if color := cssColor(p.style.Color); color != "" {
+			parts = append(parts, color)
+		}
+		if color := cssColor(p.style.BackgroundColor); color != "" {
+			parts = append(parts, "bg:"+color)
+		}
+		if p.style.Bold != nil && *p.style.Bold {
+			parts = append(parts, "bold")
+		}
+		if p.style.Italic != nil && *p.style.Italic {
+			parts = append(parts, "italic")
+		}
+		if p.style.Underline != nil && *p.style.Underline {
+			parts = append(parts, "underline")
+		}
+		if len(parts) > 0 {
+			entries[p.token] = strings.Join(parts, " ")
+		}
+	}
+	return chroma.NewStyle("hani-export", entries)
+}
+
+// cssColor converts a glamour color (hex or ANSI 256 index) into a CSS hex color
+func cssColor(color *string) string {
+	if color == nil || *color == "" {
+		return ""
+	}
+	if strings.HasPrefix(*color, "#") {
+		return *color
+	}
+	if n, err := strconv.Atoi(*color); err == nil && n >= 0 && n < 256 {
+		return termenv.ANSI256Color(n).String()
+	}
+	return ""
+}
+
+// isLightColor reports whether a CSS hex color is closer to white than black
+func isLightColor(hex string) bool {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return false
+	}
+	r, g, b := float64(rgb>>16&0xff), float64(rgb>>8&0xff), float64(rgb&0xff)
+	return 0.299*r+0.587*g+0.114*b > 128
+}
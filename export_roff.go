@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// roffExporter writes a man(7) page. The first level-one heading becomes the
+// .TH title ("hani(1)" style names set the section), level-two headings become
+// .SH sections and deeper headings .SS subsections. Tables are emitted for tbl.
+type roffExporter struct{}
+
+func (roffExporter) Extension() string { return ".1" }
+
+// manTitleRe matches a man page name with a section, e.g. "hani(1)"
+var manTitleRe = regexp.MustCompile(`^\s*([^\s(]+)\s*\((\w+)\)`)
+
+func (roffExporter) Export(w io.Writer, source []byte, opts ExportOptions) error {
+	source = []byte(strings.Join(blankFrontMatter(strings.Split(string(source), "\n")), "\n"))
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	r := &roffWriter{source: source}
+
+	// The .TH line stands in for a leading H1, which names the page when
+	// no title was given
+	name, section := opts.Title, "1"
+	if first, ok := doc.FirstChild().(*ast.Heading); ok && first.Level == 1 {
+		if name == "" {
+			name = r.plain(first)
+		}
+		doc.RemoveChild(doc, first)
+	}
+	if match := manTitleRe.FindStringSubmatch(name); match != nil {
+		name, section = match[1], match[2]
+	}
+	if name == "" {
+		name = "untitled"
+	}
+
+	fmt.Fprintf(&r.out, ".TH \"%s\" \"%s\" \"%s\"\n", roffEscape(strings.ToUpper(name)), section, time.Now().Format("2006-01-02"))
+	r.blocks(doc, 0)
+
+	_, err := io.WriteString(w, r.out.String())
+	return err
+}
+
+// roffWriter accumulates man page output while walking the goldmark AST
+type roffWriter struct {
+	source []byte
+	out    strings.Builder
+}
+
+// blocks renders the block children of node
+func (r *roffWriter) blocks(node ast.Node, depth int) {
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		r.block(child, depth)
+	}
+}
+
+func (r *roffWriter) block(node ast.Node, depth int) {
+	switch n := node.(type) {
+	case *ast.Heading:
+		macro := ".SS"
+		title := r.plain(n)
+		if n.Level <= 2 {
+			macro = ".SH"
+			title = strings.ToUpper(title)
+		}
+		fmt.Fprintf(&r.out, "%s \"%s\"\n", macro, roffEscape(title))
+
+	case *ast.Paragraph:
+		r.line(".PP")
+		r.text(r.inlines(n))
+
+	case *ast.TextBlock:
+		r.text(r.inlines(n))
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		r.line(".PP")
+		r.line(".RS 4")
+		r.line(".nf")
+		lines := n.Lines()
+		for i := range lines.Len() {
+			segment := lines.At(i)
+			r.line(roffLine(strings.TrimRight(string(segment.Value(r.source)), "\n")))
+		}
+		r.line(".fi")
+		r.line(".RE")
+
+	case *ast.Blockquote:
+		r.line(".RS 4")
+		r.blocks(n, depth)
+		r.line(".RE")
+
+	case *ast.List:
+		r.list(n, depth)
+
+	case *ast.ThematicBreak:
+		r.line(".PP")
+		r.line("\\l'\\n(.lu'")
+
+	case *extast.Table:
+		r.table(n)
+
+	case *extast.FootnoteList:
+		r.line(".SH NOTES")
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			if footnote, ok := item.(*extast.Footnote); ok {
+				r.line(fmt.Sprintf(".IP [%d] 5", footnote.Index))
+				r.item(footnote, depth)
+			}
+		}
+
+	case *ast.HTMLBlock:
+		// Raw HTML has no man page equivalent
+
+	default:
+		r.blocks(node, depth)
+	}
+}
+
+// list renders a bullet or ordered list; nested lists are indented with .RS
+func (r *roffWriter) list(list *ast.List, depth int) {
+	if depth > 0 {
+		r.line(".RS 4")
+	}
+
+	number := list.Start
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "\\(bu"
+		if list.IsOrdered() {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		r.line(fmt.Sprintf(".IP %s 4", marker))
+		r.item(item, depth)
+	}
+
+	if depth > 0 {
+		r.line(".RE")
+	}
+}
+
+// item renders the body of a list item or footnote after its .IP tag.
+// Follow-on paragraphs keep the hanging indent; nested lists go one level deeper.
+func (r *roffWriter) item(item ast.Node, depth int) {
+	for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+		if nested, ok := child.(*ast.List); ok {
+			r.list(nested, depth+1)
+			continue
+		}
+		if child != item.FirstChild() {
+			r.line(".IP \"\" 4")
+		}
+		if _, ok := child.(*ast.Paragraph); ok {
+			r.text(r.inlines(child))
+		} else {
+			r.block(child, depth+1)
+		}
+	}
+}
+
+// table renders a GFM table for tbl(1), bolding the header row
+func (r *roffWriter) table(table *extast.Table) {
+	align := make([]string, len(table.Alignments))
+	for i, a := range table.Alignments {
+		switch a {
+		case extast.AlignCenter:
+			align[i] = "c"
+		case extast.AlignRight:
+			align[i] = "r"
+		default:
+			align[i] = "l"
+		}
+	}
+
+	r.line(".TS")
+	r.line("allbox;")
+	r.line(strings.Join(align, "b ") + "b")
+	r.line(strings.Join(align, " ") + " .")
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, "T{\n"+roffGuard(r.inlines(cell))+"\nT}")
+		}
+		r.line(strings.Join(cells, "\t"))
+	}
+	r.line(".TE")
+}
+
+// inlines renders the inline children of node with roff font escapes
+func (r *roffWriter) inlines(node ast.Node) string {
+	var b strings.Builder
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			b.WriteString(roffEscape(string(n.Segment.Value(r.source))))
+			if n.HardLineBreak() {
+				b.WriteString("\n.br\n")
+			} else if n.SoftLineBreak() {
+				b.WriteString("\n")
+			}
+		case *ast.String:
+			b.WriteString(roffEscape(string(n.Value)))
+		case *ast.CodeSpan:
+			b.WriteString("\\fB" + roffEscape(r.plain(n)) + "\\fR")
+		case *ast.Emphasis:
+			font := "\\fI"
+			if n.Level == 2 {
+				font = "\\fB"
+			}
+			b.WriteString(font + r.inlines(n) + "\\fR")
+		case *ast.Link:
+			label := r.inlines(n)
+			b.WriteString(label)
+			if url := string(n.Destination); url != r.plain(n) {
+				b.WriteString(" \\(la" + roffEscape(url) + "\\(ra")
+			}
+		case *ast.AutoLink:
+			b.WriteString("\\(la" + roffEscape(string(n.URL(r.source))) + "\\(ra")
+		case *ast.Image:
+			b.WriteString(r.inlines(n))
+		case *extast.TaskCheckBox:
+			if n.IsChecked {
+				b.WriteString("[x] ")
+			} else {
+				b.WriteString("[ ] ")
+			}
+		case *extast.FootnoteLink:
+			fmt.Fprintf(&b, "[%d]", n.Index)
+		case *ast.RawHTML:
+			// Inline HTML is dropped
+		default:
+			b.WriteString(r.inlines(child))
+		}
+	}
+	return b.String()
+}
+
+// plain returns the text content of node without any markup
+func (r *roffWriter) plain(node ast.Node) string {
+	var b strings.Builder
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(r.source))
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// line writes one line of roff source
+func (r *roffWriter) line(s string) {
+	r.out.WriteString(s)
+	r.out.WriteByte('\n')
+}
+
+// text writes escaped running text, guarding lines that would parse as requests
+func (r *roffWriter) text(s string) {
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		if line != ".br" {
+			line = roffGuard(line)
+		}
+		r.line(line)
+	}
+}
+
+// roffEscape escapes backslashes and hyphens in running text
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\e")
+	return strings.ReplaceAll(s, "-", "\\-")
+}
+
+// roffLine escapes one literal line, e.g. from a code block
+func roffLine(s string) string {
+	return roffGuard(roffEscape(s))
+}
+
+// roffGuard keeps an already escaped line from being read as a roff request
+func roffGuard(line string) string {
+	if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+		return "\\&" + line
+	}
+	return line
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+)
+
+// ansiExporter captures the terminal rendering, escape codes included,
+// so `cat doc.ans` reproduces what the preview tab shows
+type ansiExporter struct{}
+
+func (ansiExporter) Extension() string { return ".ans" }
+
+func (ansiExporter) Export(w io.Writer, source []byte, opts ExportOptions) error {
+	// glamour's auto style and color profile follow stdout, which is not a
+	// terminal when exporting to a file, so resolve both explicitly here.
+	theme := opts.Theme
+	if theme == "" || theme == "auto" {
+		theme = glamourstyles.LightStyle
+		if termenv.HasDarkBackground() {
+			theme = glamourstyles.DarkStyle
+		}
+	}
+
+	renderer, err := glamour.NewTermRenderer(
+		glamourStyleOption(theme),
+		glamour.WithWordWrap(exportWidth(opts)),
+		glamour.WithColorProfile(termenv.ANSI256),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize markdown renderer: %w", err)
+	}
+
+	rendered, err := renderer.Render(previewMarkdown(strings.Split(string(source), "\n"), false))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, rendered)
+	return err
+}
+
+// textExporter writes an uncolored, reflowed plain-text rendering suitable
+// for email: ASCII bullets and table rules, no heading or emphasis markers,
+// no margin
+type textExporter struct{}
+
+func (textExporter) Extension() string { return ".txt" }
+
+func (textExporter) Export(w io.Writer, source []byte, opts ExportOptions) error {
+	style := glamourstyles.ASCIIStyleConfig
+	noMargin := uint(0)
+	style.Document.Margin = &noMargin
+	style.Document.BlockPrefix = ""
+	for _, heading := range []*ansi.StyleBlock{&style.H1, &style.H2, &style.H3, &style.H4, &style.H5, &style.H6} {
+		heading.Prefix = ""
+	}
+	for _, span := range []*ansi.StylePrimitive{&style.Emph, &style.Strong, &style.Strikethrough} {
+		span.BlockPrefix, span.BlockSuffix = "", ""
+	}
+
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStyles(style),
+		glamour.WithWordWrap(exportWidth(opts)),
+		glamour.WithColorProfile(termenv.Ascii),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize markdown renderer: %w", err)
+	}
+
+	markdown, relative := plainTextMarkdown(strings.Split(previewMarkdown(strings.Split(string(source), "\n"), false), "\n"))
+	rendered, err := renderer.Render(markdown)
+	if err != nil {
+		return err
+	}
+	for _, dest := range relative {
+		rendered = strings.ReplaceAll(rendered, relativeScheme+dest, dest)
+	}
+
+	// glamour pads every line to the wrap width; mail clients don't need that
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	_, err = io.WriteString(w, strings.TrimSpace(strings.Join(lines, "\n"))+"\n")
+	return err
+}
+
+// exportWidth returns the wrap width for text-based exports
+func exportWidth(opts ExportOptions) int {
+	if opts.Width > 0 {
+		return opts.Width
+	}
+	return DefaultWordWrap
+}
+
+// relativeScheme marks relative link destinations in the text export.
+// glamour resolves relative URLs into root paths ("docs/a.md" becomes
+// "/docs/a.md") but leaves anything with a scheme alone.
+const relativeScheme = "rel:"
+
+// plainTextMarkdown prepares lines for the text export. Tables are laid out
+// compactly and kept verbatim in a code block, since glamour stretches them
+// to the wrap width, and relative link destinations are marked with
+// relativeScheme; the marked destinations are returned too.
+func plainTextMarkdown(lines []string) (string, []string) {
+	doc := newDocStructure()
+	doc.update(lines)
+
+	var out, relative []string
+	for i := 0; i < len(lines); i++ {
+		if inCodeOrFrontMatter(doc, i) {
+			out = append(out, lines[i])
+			continue
+		}
+		if t, ok := findTable(lines, i); ok && t.start == i {
+			out = append(out, "", t.indent+"~~~")
+			for _, line := range t.renderPlain() {
+				out = append(out, t.indent+line)
+			}
+			out = append(out, t.indent+"~~~", "")
+			i = t.end
+			continue
+		}
+		line, marked := markRelativeLinks(lines[i])
+		out = append(out, line)
+		relative = append(relative, marked...)
+	}
+	return strings.Join(out, "\n"), relative
+}
+
+// markRelativeLinks prefixes the relative destinations of the inline links
+// and reference definition on line with relativeScheme. Anchors, root paths
+// and URLs are left as they are.
+func markRelativeLinks(line string) (string, []string) {
+	var dests [][]int
+	if m := linkDefRe.FindStringSubmatchIndex(line); m != nil {
+		dests = append(dests, m[4:6])
+	} else {
+		for _, m := range linkInlineRe.FindAllStringSubmatchIndex(line, -1) {
+			dests = append(dests, m[4:6])
+		}
+	}
+
+	var marked []string
+	for i := len(dests) - 1; i >= 0; i-- {
+		start, end := dests[i][0], dests[i][1]
+		if strings.HasPrefix(line[start:end], "<") {
+			start, end = start+1, end-1
+		}
+		dest := line[start:end]
+		if dest == "" || linkSchemeRe.MatchString(dest) || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "/") {
+			continue
+		}
+		line = line[:start] + relativeScheme + line[start:]
+		marked = append(marked, dest)
+	}
+	return line, marked
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pushJump remembers the cursor position so Ctrl+O can return to it
+func (m *Model) pushJump() {
+	m.jumps = append(m.jumps, jump{file: m.filename, pos: m.cursor})
+	if len(m.jumps) > maxJumps {
+		m.jumps = m.jumps[len(m.jumps)-maxJumps:]
+	}
+}
+
+// jumpBack returns to the place the last followed link was followed from,
+// reopening its file if the link led elsewhere
+func (m Model) jumpBack() (tea.Model, tea.Cmd) {
+	if len(m.jumps) == 0 {
+		m.setStatusMsg("Jump list is empty", false)
+		return m, nil
+	}
+	last := m.jumps[len(m.jumps)-1]
+
+	if last.file != m.filename && !sameFile(last.file, m.filename) {
+		opened, ok := m.openFile(last.file)
+		if !ok {
+			return opened, nil
+		}
+		m = opened
+	}
+	m.jumps = m.jumps[:len(m.jumps)-1]
+	m.cursor = last.pos
+	m.ensureCursorBounds()
+	m.adjustViewport()
+	return m, nil
+}
+
+// followLinkAt follows the link under the cursor. With fileNames set (gf),
+// a file name written as plain text is followed too.
+func (m Model) followLinkAt(fileNames bool) (tea.Model, tea.Cmd) {
+	if link, ok := linkAt(m.content, m.cursor); ok {
+		return m.followLink(link)
+	}
+	if fileNames {
+		if name := fileNameAt(m.content[m.cursor.row], m.cursor.col); name != "" {
+			if _, err := os.Stat(resolveLink(name, m.filename).file); err == nil {
+				return m.followTarget(name)
+			}
+		}
+		m.setStatusMsg("No link or file name under the cursor", true)
+	}
+	return m, nil
+}
+
+// followLink follows a link in the editor. A reference link jumps to its
+// definition, where following it again leads to the destination.
+func (m Model) followLink(link mdLink) (tea.Model, tea.Cmd) {
+	if link.ref != "" && !link.def {
+		if link.target == "" {
+			m.setStatusMsg("No definition for ["+link.ref+"]", true)
+			return m, nil
+		}
+		m.pushJump()
+		m.cursor = Position{row: link.defLine, col: 0}
+		m.adjustViewport()
+		return m, nil
+	}
+	return m.followTarget(link.target)
+}
+
+// followTarget goes to a link destination: a heading in this document or
+// another Markdown file, or anything else through the opener
+func (m Model) followTarget(target string) (tea.Model, tea.Cmd) {
+	dest := resolveLink(target, m.filename)
+	if dest.url != "" {
+		return m.openExternal(dest.url)
+	}
+	if dest.file == "" || sameFile(dest.file, m.filename) {
+		return m.jumpToAnchor(dest.anchor)
+	}
+
+	if _, err := os.Stat(dest.file); err != nil {
+		m.setStatusMsg("Cannot open "+dest.file+": no such file", true)
+		return m, nil
+	}
+	if !isMarkdownFile(dest.file) {
+		return m.openExternal(dest.file)
+	}
+
+	from := jump{file: m.filename, pos: m.cursor}
+	opened, ok := m.openFile(dest.file)
+	if !ok {
+		return opened, nil
+	}
+	m = opened
+	m.jumps = append(m.jumps, from)
+	if dest.anchor != "" {
+		line, found := anchorLine(m.content, dest.anchor)
+		if !found {
+			m.setStatusMsg(fmt.Sprintf("%s has no heading #%s", dest.file, dest.anchor), true)
+			return m, nil
+		}
+		m.cursor = Position{row: line, col: 0}
+	}
+	m.scrollPreviewTo(m.cursor.row)
+	m.adjustViewport()
+	return m, nil
+}
+
+// jumpToAnchor moves to the heading anchor names in this document
+func (m Model) jumpToAnchor(anchor string) (tea.Model, tea.Cmd) {
+	if anchor == "" {
+		return m, nil
+	}
+	line, ok := anchorLine(m.content, anchor)
+	if !ok {
+		m.setStatusMsg("No heading #"+anchor, true)
+		return m, nil
+	}
+	m.pushJump()
+	m.cursor = Position{row: line, col: 0}
+	m.scrollPreviewTo(line)
+	m.adjustViewport()
+	return m, nil
+}
+
+// openExternal hands a URL or file to the opener command
+func (m Model) openExternal(target string) (tea.Model, tea.Cmd) {
+	if err := openExternal(m.config.Opener, target); err != nil {
+		m.setStatusMsg("Cannot open "+target+": "+err.Error(), true)
+		return m, nil
+	}
+	m.setStatusMsg("Opened "+target, false)
+	return m, nil
+}
+
+// renderedPreview renders the buffer for the preview, split into lines
+func (m Model) renderedPreview() ([]string, bool) {
+	markdown := previewMarkdown(m.content, m.config.FrontMatterCard)
+	if strings.TrimSpace(markdown) == "" || m.renderer == nil {
+		return nil, false
+	}
+	rendered, err := m.renderer.Render(markdown)
+	if err != nil {
+		return nil, false
+	}
+	return strings.Split(rendered, "\n"), true
+}
+
+// scrollPreviewTo scrolls the preview to the heading on line, when following
+// a link from the preview
+func (m *Model) scrollPreviewTo(line int) {
+	if m.activeTab != TabPreview {
+		return
+	}
+	m.previewOffset = 0
+	section := m.doc.sectionAt(line)
+	if section < 0 || m.doc.headings[section].Line != line {
+		return
+	}
+	if rendered, ok := m.renderedPreview(); ok {
+		if at := renderedTextLine(rendered, m.doc.headings[section].Title()); at >= 0 {
+			m.previewOffset = at
+		}
+	}
+}
+
+// previewLinks returns the links the preview shows; reference definitions
+// are not displayed
+func (m Model) previewLinks() []mdLink {
+	var links []mdLink
+	for _, link := range documentLinks(m.content) {
+		if !link.def {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// selectPreviewLink moves the preview's link selection by dir and scrolls
+// the selected link into view
+func (m Model) selectPreviewLink(dir int) (tea.Model, tea.Cmd) {
+	links := m.previewLinks()
+	if len(links) == 0 {
+		m.previewLink = 0
+		m.setStatusMsg("No links in the document", false)
+		return m, nil
+	}
+
+	// previewLink counts from 1; 0 (nothing selected) moves to either end
+	m.previewLink = min(m.previewLink, len(links))
+	switch {
+	case dir > 0 && m.previewLink < len(links):
+		m.previewLink++
+	case dir > 0:
+		m.previewLink = 1
+	case m.previewLink > 1:
+		m.previewLink--
+	default:
+		m.previewLink = len(links)
+	}
+	link := links[m.previewLink-1]
+
+	if rendered, ok := m.renderedPreview(); ok {
+		height := m.height - 3 // tab + status + footer
+		if line := renderedLinkLine(rendered, links, m.previewLink-1); line >= 0 && (line < m.previewOffset || line >= m.previewOffset+height) {
+			m.previewOffset = max(0, line-height/2)
+		}
+	}
+
+	target := link.target
+	if target == "" {
+		target = "[" + link.ref + "] is not defined"
+	}
+	m.setStatusMsg(fmt.Sprintf("Link %d/%d: %s", m.previewLink, len(links), target), false)
+	return m, nil
+}
+
+// followPreviewLink follows the link selected in the preview. Reference
+// links go straight to their destination, as their definitions aren't shown.
+func (m Model) followPreviewLink() (tea.Model, tea.Cmd) {
+	links := m.previewLinks()
+	if m.previewLink == 0 || m.previewLink > len(links) {
+		m.setStatusMsg("No link selected (n/N select links)", true)
+		return m, nil
+	}
+	link := links[m.previewLink-1]
+	if link.target == "" {
+		m.setStatusMsg("No definition for ["+link.ref+"]", true)
+		return m, nil
+	}
+	m.previewLink = 0
+	return m.followTarget(link.target)
+}
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	// Emphasis and strong emphasis by each delimiter, with the characters
+	// around them, which must not be letters or digits: "snake_case_name"
+	// and "2*3*4" have no emphasis in them
+	emphasisStarRe  = regexp.MustCompile(`(^|[^\pL\pN*\\])\*([^\s*](?:[^*]*[^\s*\\])?)\*([^\pL\pN*]|$)`)
+	emphasisUnderRe = regexp.MustCompile(`(^|[^\pL\pN_\\])_([^\s_](?:[^_]*[^\s_\\])?)_([^\pL\pN_]|$)`)
+	strongStarRe    = regexp.MustCompile(`(^|[^\pL\pN*\\])\*\*([^\s*](?:[^*]*[^\s*\\])?)\*\*([^\pL\pN*]|$)`)
+	strongUnderRe   = regexp.MustCompile(`(^|[^\pL\pN_\\])__([^\s_](?:[^_]*[^\s_\\])?)__([^\pL\pN_]|$)`)
+
+	// inlineVerbatimRe matches what emphasis markers are left alone in:
+	// escapes, link destinations, autolinks and URLs are matched apart
+	inlineVerbatimRe = regexp.MustCompile(`\\.|\]\([^)]*\)`)
+
+	// htmlBlockRe matches a line that starts an HTML block, which runs to
+	// the next blank line
+	htmlBlockRe = regexp.MustCompile(`^ {0,3}<(?:[A-Za-z/!?]|$)`)
+
+	// wrapUnsafeRe matches words that would start a block if wrapping put
+	// them at the start of a line
+	wrapUnsafeRe = regexp.MustCompile("^(?:#{1,6}|[-+*>]|=+|-+|\\d{1,9}[.)]|`{3,}.*|~{3,}.*)$")
+)
+
+// formatOptions is the style the formatter writes. Empty markers keep the
+// ones the document uses.
+type formatOptions struct {
+	bullet   string // unordered list marker: "-", "*" or "+"
+	emphasis string // "_" or "*"
+	strong   string // "**" or "__"
+	wrap     int    // reflow paragraphs to this width; 0 keeps line breaks
+}
+
+func defaultFormatOptions() formatOptions {
+	return formatOptions{bullet: "-", emphasis: "_", strong: "**"}
+}
+
+// formatOptionsFor returns the style for a document: bullets as the
+// project's ul-style lint rule asks, and paragraphs reflowed to word_wrap
+// when format_wrap is set
+func formatOptionsFor(config Config, lint lintConfig) formatOptions {
+	opts := defaultFormatOptions()
+	if bullet := lint.bulletStyle(); bullet != "" {
+		opts.bullet = bullet
+	}
+	if config.FormatWrap {
+		opts.wrap = config.WordWrap
+	}
+	return opts
+}
+
+// formatMarkdown rewrites lines from to to (inclusive) of content in the
+// house style and returns the result with the cursor moved along with the
+// text. A range that starts or ends in a code block or the front matter is
+// widened to take all of it.
+func formatMarkdown(content []string, pos Position, from, to int, opts formatOptions) ([]string, Position) {
+	from, to = max(0, from), min(len(content)-1, to)
+	if from > to {
+		return content, pos
+	}
+	doc := newDocStructure()
+	doc.update(content)
+	if block, ok := doc.codeBlockAt(from); ok {
+		from = block.start
+	}
+	if block, ok := doc.codeBlockAt(to); ok {
+		to = block.end
+	}
+	if _, end, ok := doc.frontMatter(); ok && from <= end {
+		from, to = 0, max(to, end)
+	}
+
+	f := &formatter{lines: content[from : to+1], opts: opts, cursor: Position{row: -1}}
+	f.doc = newDocStructure()
+	f.doc.update(f.lines)
+	inRange := pos.row >= from && pos.row <= to
+	if inRange {
+		f.cursor = Position{row: pos.row - from, col: pos.col}
+	}
+	f.run()
+
+	// The document starts with its first block
+	if from == 0 {
+		lead := 0
+		for lead < len(f.out)-1 && f.out[lead] == "" {
+			lead++
+		}
+		f.out = f.out[lead:]
+		f.moved.row -= lead
+	}
+
+	formatted := slices.Concat(content[:from], f.out, content[to+1:])
+	if len(formatted) == 0 {
+		formatted = []string{""} // the buffer was blank lines only
+	}
+	switch {
+	case inRange:
+		pos = Position{row: from + f.moved.row, col: f.moved.col}
+	case pos.row > to:
+		pos.row += len(f.out) - (to + 1 - from)
+	}
+	pos.row = max(0, min(pos.row, len(formatted)-1))
+	pos.col = max(0, min(pos.col, len(formatted[pos.row])))
+	return formatted, pos
+}
+
+// formatter rewrites a run of lines block by block, following the cursor
+type formatter struct {
+	lines  []string
+	doc    *docStructure
+	opts   formatOptions
+	cursor Position // in lines; row -1 when the cursor is elsewhere
+	out    []string
+	moved  Position // the cursor in out
+	blank  bool     // the block just written wants a blank line after it
+	inList bool     // indented lines continue a list item, not code
+
+	// The open bullet lists, outermost first, and the quote they are in
+	lists     []bulletList
+	listQuote string
+}
+
+// bulletList is an open bullet list: the column of its markers, the marker
+// it was written with and the one the formatter writes for it
+type bulletList struct {
+	indent      int
+	marker, out string
+}
+
+// emit writes out, the formatted version of lines from to to (exclusive)
+func (f *formatter) emit(from, to int, out ...string) {
+	if f.cursor.row >= from && f.cursor.row < to {
+		f.moved = mapCursor(f.lines[from:to], Position{row: f.cursor.row - from, col: f.cursor.col}, out)
+		f.moved.row += len(f.out)
+	}
+	f.out = append(f.out, out...)
+}
+
+// separate starts a block on a new paragraph: one blank line after
+// whatever came before
+func (f *formatter) separate() {
+	if len(f.out) > 0 && f.out[len(f.out)-1] != "" {
+		f.out = append(f.out, "")
+	}
+}
+
+func (f *formatter) run() {
+	_, frontMatterEnd, hasFrontMatter := f.doc.frontMatter()
+
+	for i := 0; i < len(f.lines); {
+		line := f.lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			// Runs of blank lines become one
+			if len(f.out) > 0 && f.out[len(f.out)-1] != "" {
+				f.emit(i, i+1, "")
+			} else {
+				f.emit(i, i+1)
+			}
+			f.blank = false
+			i++
+			continue
+		}
+		if f.blank {
+			f.separate()
+			f.blank = false
+		}
+		if item, ok := parseListItem(line); !ok || item.marker == "" {
+			f.endLists(line)
+		}
+
+		switch {
+		case hasFrontMatter && i <= frontMatterEnd:
+			f.emit(i, frontMatterEnd+1, f.lines[i:frontMatterEnd+1]...)
+			f.blank = true
+			i = frontMatterEnd + 1
+
+		case f.codeBlockStart(i):
+			block, _ := f.doc.codeBlockAt(i)
+			f.separate()
+			f.emit(i, block.end+1, f.fence(block)...)
+			f.blank = true
+			i = block.end + 1
+
+		case f.tableStart(i):
+			i = f.table(i)
+
+		case atxHeadingRe.MatchString(line):
+			match := atxHeadingRe.FindStringSubmatch(line)
+			f.separate()
+			f.emit(i, i+1, f.heading(len(match[1]), match[2]))
+			f.blank, f.inList = true, false
+			i++
+
+		case f.setextHeading(i):
+			level := 1
+			if strings.TrimSpace(f.lines[i+1])[0] == '-' {
+				level = 2
+			}
+			f.separate()
+			f.emit(i, i+2, f.heading(level, line))
+			f.blank, f.inList = true, false
+			i += 2
+
+		case htmlBlockRe.MatchString(line) && !linkAutoRe.MatchString(line):
+			end := i
+			for end < len(f.lines) && strings.TrimSpace(f.lines[end]) != "" {
+				end++
+			}
+			f.emit(i, end, f.lines[i:end]...)
+			i = end
+
+		case f.indentedCode(i):
+			end := i
+			for end < len(f.lines) && (strings.TrimSpace(f.lines[end]) == "" || indentWidth(f.lines[end]) >= 4) {
+				end++
+			}
+			for end > i && strings.TrimSpace(f.lines[end-1]) == "" {
+				end--
+			}
+			f.emit(i, end, f.lines[i:end]...)
+			i = end
+
+		case thematicBreakRe.MatchString(line), linkDefRe.MatchString(line):
+			f.emit(i, i+1, strings.TrimRight(line, " \t"))
+			f.inList = false
+			i++
+
+		default:
+			i = f.paragraph(i)
+		}
+	}
+}
+
+// endLists closes the bullet lists that line, which isn't a list item,
+// ends: text indented less than an item's content ends that item's list
+func (f *formatter) endLists(line string) {
+	item, _ := parseListItem(line)
+	if item.quote() != f.listQuote {
+		f.lists, f.listQuote = nil, item.quote()
+	}
+	if strings.TrimSpace(line[item.length:]) == "" {
+		return // a blank line in a quote
+	}
+	width := bodyIndent(line, f.listQuote)
+	for len(f.lists) > 0 && width <= f.lists[len(f.lists)-1].indent {
+		f.lists = f.lists[:len(f.lists)-1]
+	}
+}
+
+// bullet returns the marker to write for item: the house bullet, unless
+// the item starts a list right after a sibling list with another marker.
+// In CommonMark the change of marker is what keeps the two lists apart, so
+// the new list gets a marker other than the one written for the last.
+func (f *formatter) bullet(item listItem) string {
+	if item.quote() != f.listQuote {
+		f.lists, f.listQuote = nil, item.quote()
+	}
+	indent := item.indent()
+	for len(f.lists) > 0 && f.lists[len(f.lists)-1].indent > indent {
+		f.lists = f.lists[:len(f.lists)-1]
+	}
+	top := len(f.lists) - 1
+	sibling := top >= 0 && f.lists[top].indent == indent
+	if item.ordered() || f.opts.bullet == "" {
+		if sibling {
+			f.lists = f.lists[:top] // an ordered list ends the bullet list
+		}
+		return item.marker
+	}
+
+	out := f.opts.bullet
+	if sibling {
+		last := f.lists[top]
+		if last.marker == item.marker {
+			return last.out
+		}
+		f.lists = f.lists[:top]
+		for _, marker := range []string{f.opts.bullet, item.marker, "-", "*"} {
+			if marker != last.out {
+				out = marker
+				break
+			}
+		}
+	}
+	f.lists = append(f.lists, bulletList{indent: indent, marker: item.marker, out: out})
+	return out
+}
+
+// codeBlockStart reports whether a fenced code block opens on line i
+func (f *formatter) codeBlockStart(i int) bool {
+	block, ok := f.doc.codeBlockAt(i)
+	return ok && block.start == i
+}
+
+// tableStart reports whether a table's header row is line i
+func (f *formatter) tableStart(i int) bool {
+	return i+1 < len(f.lines) && isTableRow(f.lines[i]) && tableDelimiterRe.MatchString(f.lines[i+1]) &&
+		len(splitTableRow(f.lines[i])) == len(splitTableRow(f.lines[i+1]))
+}
+
+// setextHeading reports whether line i is the text of an underlined heading
+func (f *formatter) setextHeading(i int) bool {
+	if i+1 >= len(f.lines) || !setextUnderlineRe.MatchString(f.lines[i+1]) {
+		return false
+	}
+	for _, h := range f.doc.headings {
+		if h.Line == i {
+			return true
+		}
+	}
+	return false
+}
+
+// indentedCode reports whether line i starts an indented code block: it is
+// indented four columns after a blank line, outside a list
+func (f *formatter) indentedCode(i int) bool {
+	if f.inList || indentWidth(f.lines[i]) < 4 {
+		return false
+	}
+	return i == 0 || strings.TrimSpace(f.lines[i-1]) == ""
+}
+
+// heading writes an ATX heading: markers, one space and the text, without
+// closing hashes
+func (f *formatter) heading(level int, text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return strings.Repeat("#", level)
+	}
+	return strings.Repeat("#", level) + " " + f.inline(text)
+}
+
+// fence rewrites a fenced code block with backtick fences, longer than any
+// backtick run inside it, and the info string tidied. The code is copied
+// as it is.
+func (f *formatter) fence(block CodeBlock) []string {
+	lines := f.lines[block.start : block.end+1]
+	open := fenceOpenRe.FindStringSubmatch(lines[0])
+	indent := lines[0][:len(lines[0])-len(strings.TrimLeft(lines[0], " "))]
+	info := strings.TrimSpace(open[2])
+
+	body := lines[1:]
+	if len(lines) > 1 && closesFence(lines[len(lines)-1], open[1]) {
+		body = lines[1 : len(lines)-1]
+	}
+
+	// Tilde fences stay when the info string has backticks, which a
+	// backtick fence can't
+	char := "`"
+	if strings.Contains(info, "`") {
+		char = "~"
+	}
+	length := 3
+	for _, line := range body {
+		trimmed := strings.TrimLeft(line, " ")
+		if run := len(trimmed) - len(strings.TrimLeft(trimmed, char)); run >= length {
+			length = run + 1
+		}
+	}
+
+	fence := indent + strings.Repeat(char, length)
+	out := []string{fence + info}
+	out = append(out, body...)
+	return append(out, fence)
+}
+
+// table writes the table whose header is line i, aligned, and returns the
+// line after it
+func (f *formatter) table(i int) int {
+	end := i + 2
+	for end < len(f.lines) && isTableRow(f.lines[end]) {
+		end++
+	}
+
+	pos := Position{row: i}
+	if f.cursor.row >= i && f.cursor.row < end {
+		pos = f.cursor
+	}
+	aligned, moved, _ := formatTableAt(f.lines, pos)
+
+	f.separate()
+	if f.cursor.row >= i && f.cursor.row < end {
+		f.moved = Position{row: len(f.out) + moved.row - i, col: moved.col}
+	}
+	f.out = append(f.out, aligned[i:end]...)
+	f.blank, f.inList = true, false
+	return end
+}
+
+// paragraph writes the paragraph, list item or quoted paragraph starting on
+// line i, reflowed if the options ask for it, and returns the line after it
+func (f *formatter) paragraph(i int) int {
+	first := f.lines[i]
+	item, isItem := parseListItem(first)
+	quote := ""
+	prefix := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	if isItem {
+		quote = item.quote()
+		prefix = first[:item.length]
+		if item.marker != "" {
+			at := len(item.lead)
+			prefix = prefix[:at] + f.bullet(item) + prefix[at+len(item.marker):]
+		}
+		f.inList = item.marker != ""
+	} else if indentWidth(first) < 4 {
+		f.inList = false
+	}
+
+	// The paragraph's lines, without their prefixes
+	prefixes := []string{prefix}
+	texts := []string{first[len(prefix):]}
+	end := i + 1
+	for f.continues(end, quote) && !f.blockText(texts[0]) {
+		line, lead := f.lines[end], ""
+		if quote != "" {
+			next, _ := parseListItem(line)
+			lead, line = line[:next.length], line[next.length:]
+		}
+		prefixes = append(prefixes, lead)
+		texts = append(texts, line)
+		end++
+	}
+	for j, text := range texts {
+		texts[j] = f.inline(text)
+	}
+
+	if f.opts.wrap <= 0 || f.blockText(texts[0]) {
+		out := make([]string, len(texts))
+		for j, text := range texts {
+			out[j] = trimTrailing(prefixes[j] + text)
+		}
+		f.emit(i, end, out...)
+		return end
+	}
+
+	f.emit(i, end, reflow(texts, prefix, continuationPrefix(prefix), f.opts.wrap)...)
+	return end
+}
+
+// continues reports whether line i carries on the paragraph above it: it
+// isn't blank and doesn't start a block of its own. In a quote, lines must
+// keep the quote's markers.
+func (f *formatter) continues(i int, quote string) bool {
+	if i >= len(f.lines) || strings.TrimSpace(f.lines[i]) == "" || f.setextHeading(i) {
+		return false
+	}
+	line := f.lines[i]
+	if item, ok := parseListItem(line); ok {
+		return quote != "" && item.marker == "" && item.quote() == quote && strings.TrimSpace(line[item.length:]) != "" && !f.blockText(line[item.length:])
+	}
+	if quote != "" {
+		return false
+	}
+	if _, inCode := f.doc.codeBlockAt(i); inCode || f.tableStart(i) || setextUnderlineRe.MatchString(line) {
+		return false
+	}
+	return !f.blockText(line)
+}
+
+// blockText reports whether text, a line without its list or quote prefix,
+// starts a block rather than continuing a paragraph
+func (f *formatter) blockText(text string) bool {
+	return atxHeadingRe.MatchString(text) || fenceOpenRe.MatchString(text) || thematicBreakRe.MatchString(text) ||
+		(htmlBlockRe.MatchString(text) && !linkAutoRe.MatchString(text)) || linkDefRe.MatchString(text)
+}
+
+// inline rewrites emphasis markers in text to the house style, leaving code
+// spans, link destinations, URLs and HTML alone
+func (f *formatter) inline(text string) string {
+	if !strings.ContainsAny(text, "*_") || f.opts.emphasis == "" {
+		return text
+	}
+	line := []byte(text)
+	masked := []byte(text)
+	for _, re := range []*regexp.Regexp{inlineCodeSpanRe, inlineVerbatimRe, linkAutoRe, linkBareRe, inlineHTMLRe} {
+		for _, span := range re.FindAllIndex(masked, -1) {
+			for j := span[0]; j < span[1]; j++ {
+				masked[j] = 0
+			}
+		}
+	}
+
+	// Strong first, so "**" isn't taken for two emphasis markers. The
+	// conversions keep the length, so positions in masked hold in line.
+
+	for _, step := range []struct {
+		re    *regexp.Regexp
+		from  string
+		style string
+	}{
+		{strongUnderRe, "__", "**"},
+		{strongStarRe, "**", "__"},
+		{emphasisStarRe, "*", "_"},
+		{emphasisUnderRe, "_", "*"},
+	} {
+		if (len(step.from) == 2 && f.opts.strong == step.from) || (len(step.from) == 1 && f.opts.emphasis == step.from) {
+			continue
+		}
+		replaceDelimiters(line, slices.Clone(masked), step.re, step.from, step.style)
+	}
+	return string(line)
+}
+
+// replaceDelimiters swaps the from delimiters around each match of re for
+// to, which is as long. Replaced and matched text is masked so it isn't
+// converted again; matches share boundary characters, so it repeats until
+// nothing is left.
+func replaceDelimiters(line, masked []byte, re *regexp.Regexp, from, to string) {
+	for {
+		matches := re.FindAllSubmatchIndex(masked, -1)
+		if matches == nil {
+			return
+		}
+		for _, m := range matches {
+			open, close := m[3], m[5] // the delimiters sit around group 2
+			copy(line[open:], to)
+			copy(line[close:], to)
+			for j := open; j < close+len(from); j++ {
+				masked[j] = 0
+			}
+		}
+	}
+}
+
+// reflow fills the words of texts into lines of at most width columns, the
+// first starting with prefix and the rest with cont. A line ending in a
+// hard break (two spaces or a backslash) still ends a line.
+func reflow(texts []string, prefix, cont string, width int) []string {
+	var out []string
+	line := prefix
+	empty := true
+	for j, text := range texts {
+		hardBreak := strings.HasSuffix(text, "  ") || strings.HasSuffix(text, "\\")
+		for _, word := range strings.Fields(text) {
+			switch {
+			case empty:
+				line += word
+				empty = false
+			case ansi.StringWidth(line)+1+ansi.StringWidth(word) <= width || wrapUnsafeRe.MatchString(word):
+				line += " " + word
+			default:
+				out = append(out, line)
+				line = cont + word
+			}
+		}
+		if hardBreak && j < len(texts)-1 && !empty {
+			if !strings.HasSuffix(line, "\\") {
+				line += "  "
+			}
+			out = append(out, line)
+			line, empty = cont, true
+		}
+	}
+	if empty && len(out) > 0 {
+		return out
+	}
+	return append(out, strings.TrimRight(line, " "))
+}
+
+// continuationPrefix is what lines after the first of a list item or quote
+// start with: the quote markers, and spaces for the rest of the prefix
+func continuationPrefix(prefix string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '>' || r == '\t' {
+			return r
+		}
+		return ' '
+	}, prefix)
+}
+
+// trimTrailing removes trailing whitespace, keeping exactly two spaces
+// where a line ends in a hard break
+func trimTrailing(line string) string {
+	trimmed := strings.TrimRight(line, " \t")
+	if strings.HasSuffix(line, "  ") && strings.TrimSpace(trimmed) != "" {
+		return trimmed + "  "
+	}
+	return trimmed
+}
+
+// indentWidth is the column the line's text starts at, tabs counting to
+// the next multiple of four
+func indentWidth(line string) int {
+	return len(expandTabs(line[:indentWidthBytes(line)]))
+}
+
+// indentWidthBytes is the length of the line's leading whitespace
+func indentWidthBytes(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// mapCursor finds where pos in old ends up in new, its formatted version,
+// by counting the letters and digits before it. A line that kept its
+// length keeps the cursor column as it is.
+func mapCursor(old []string, pos Position, new []string) Position {
+	if len(new) == 0 {
+		return Position{}
+	}
+	if len(old) == len(new) && len(old[pos.row]) == len(new[pos.row]) {
+		return pos
+	}
+
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	count := func(s string) int {
+		n := 0
+		for _, r := range s {
+			if isWord(r) {
+				n++
+			}
+		}
+		return n
+	}
+
+	before := 0
+	for _, line := range old[:pos.row] {
+		before += count(line)
+	}
+	line := old[pos.row]
+	col := min(pos.col, len(line))
+	before += count(line[:col])
+	r, _ := utf8.DecodeRuneInString(line[col:])
+	onWord := col < len(line) && isWord(r)
+
+	seen := 0
+	for row, text := range new {
+		for c, r := range text {
+			if !isWord(r) {
+				continue
+			}
+			if seen == before && (onWord || before == 0) {
+				return Position{row: row, col: c}
+			}
+			seen++
+			if seen == before && !onWord {
+				return Position{row: row, col: c + utf8.RuneLen(r)}
+			}
+		}
+	}
+	last := len(new) - 1
+	return Position{row: last, col: len(new[last])}
+}
+
+// bulletStyle is the list marker the ul-style rule asks for, or "" if it
+// only wants consistency
+func (c lintConfig) bulletStyle() string {
+	style, _ := c.options["MD004"]["style"].(string)
+	return map[string]string{"dash": "-", "asterisk": "*", "plus": "+"}[style]
+}
+
+// runFmt implements `hani fmt`, which prints formatted documents or, with
+// -w, rewrites them in place. With -l it lists the files that would change
+// and exits with status 1 if there are any.
+func runFmt(args []string) int {
+	config := LoadConfig()
+
+	fs := newSubcommandFlags("fmt", "hani fmt [-w | -l] [--wrap N] FILE...|-")
+	write := fs.Bool("w", false, "write the result to the files instead of printing it")
+	list := fs.Bool("l", false, "list files whose formatting differs and exit with status 1 if any do")
+	wrap := fs.Int("wrap", -1, "reflow paragraphs to `N` columns, 0 to keep line breaks (default: word_wrap if format_wrap is set)")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) == 0 || (*write && *list) || ((*write || *list) && slices.Contains(files, "-")) {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, file := range files {
+		source, err := readDocument(file)
+		if err != nil {
+			status = fatalf("%v", err)
+			continue
+		}
+		lint, err := loadLintConfig(file, config.WordWrap)
+		if err != nil {
+			status = fatalf("%v", err)
+			continue
+		}
+		opts := formatOptionsFor(config, lint)
+		if *wrap >= 0 {
+			opts.wrap = *wrap
+		}
+
+		content := strings.Split(strings.TrimSuffix(string(source), "\n"), "\n")
+		formatted, _ := formatMarkdown(content, Position{}, 0, len(content)-1, opts)
+		for len(formatted) > 1 && formatted[len(formatted)-1] == "" {
+			formatted = formatted[:len(formatted)-1]
+		}
+		output := strings.Join(formatted, "\n") + "\n"
+
+		switch {
+		case *list:
+			if output != string(source) {
+				fmt.Println(file)
+				status = max(status, 1)
+			}
+		case *write:
+			if output == string(source) {
+				continue
+			}
+			if err := os.WriteFile(file, []byte(output), 0644); err != nil {
+				status = fatalf("%v", err)
+			}
+		default:
+			fmt.Print(output)
+		}
+	}
+	return status
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// format runs the formatter over all of doc the way `hani fmt` does
+func format(t *testing.T, doc string, opts formatOptions) string {
+	t.Helper()
+	content := strings.Split(strings.TrimSuffix(doc, "\n"), "\n")
+	formatted, _ := formatMarkdown(content, Position{}, 0, len(content)-1, opts)
+	return strings.Join(formatted, "\n") + "\n"
+}
+
+// renderHTML renders doc as the HTML exporter does
+func renderHTML(t *testing.T, doc string) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := convertHTML(&b, []byte(doc), false); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestFormatKeepsDocumentStructure(t *testing.T) {
+	tests := []struct {
+		name, doc, want string
+	}{
+		{"sibling lists", "- a\n- b\n\n* c\n* d\n", "- a\n- b\n\n* c\n* d\n"},
+		{"sibling lists without a blank line", "* a\n- b\n", "- a\n* b\n"},
+		{"three sibling lists", "* a\n\n- b\n\n+ c\n", "- a\n\n* b\n\n- c\n"},
+		{"one list", "* a\n* b\n\n* c\n", "- a\n- b\n\n- c\n"},
+		{"lists apart", "* a\n\ntext\n\n+ b\n", "- a\n\ntext\n\n- b\n"},
+		{"nested sibling lists", "- a\n  * x\n  + y\n- b\n", "- a\n  - x\n  + y\n- b\n"},
+		{"sibling lists in a quote", "> * a\n>\n> - b\n", "> - a\n>\n> * b\n"},
+		{"after an ordered list", "1. a\n\n* b\n\n+ c\n", "1. a\n\n- b\n\n+ c\n"},
+		{"emphasis", "*a* and __b__\n", "_a_ and **b**\n"},
+		{"blank", "\n\n", "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := format(t, tt.doc, defaultFormatOptions())
+			if got != tt.want {
+				t.Errorf("format(%q) = %q, want %q", tt.doc, got, tt.want)
+			}
+			if before, after := renderHTML(t, tt.doc), renderHTML(t, got); before != after {
+				t.Errorf("format(%q) changed the HTML:\n%s\nto:\n%s", tt.doc, before, after)
+			}
+			if again := format(t, got, defaultFormatOptions()); again != got {
+				t.Errorf("format is not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Front matter formats, named after their Chroma lexers
+const (
+	frontMatterYAML = "yaml" // between "---" lines
+	frontMatterTOML = "toml" // between "+++" lines
+)
+
+var (
+	// yamlErrorRe picks the line out of yaml.v3's syntax errors
+	yamlErrorRe = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+	// tomlTableRe matches a TOML table header, after which keys are no
+	// longer top-level fields
+	tomlTableRe = regexp.MustCompile(`^\s*\[`)
+
+	// tomlBareKeyRe matches the keys TOML doesn't need quoted
+	tomlBareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// frontMatter is the metadata block at the top of a document: its format,
+// the line closing it and its top-level fields in order
+type frontMatter struct {
+	format string
+	end    int
+	fields []metaField
+}
+
+// metaField is a top-level front matter field, with its value written out
+// the way the card shows it
+type metaField struct {
+	key   string
+	value string
+}
+
+// frontMatterError is a front matter syntax error on a document line
+type frontMatterError struct {
+	line int // 0-based
+	msg  string
+}
+
+func (e *frontMatterError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line+1, e.msg)
+}
+
+// frontMatterBlock returns the format of the front matter doc has found in
+// content and the line closing it
+func frontMatterBlock(content []string, doc *docStructure) (format string, end int, ok bool) {
+	_, end, ok = doc.frontMatter()
+	if !ok {
+		return "", 0, false
+	}
+	if content[0] == "+++" {
+		return frontMatterTOML, end, true
+	}
+	return frontMatterYAML, end, true
+}
+
+// parseFrontMatter reads the front matter at the top of content. ok is
+// false when there is none; a block that doesn't parse is reported with
+// the document line of the problem.
+func parseFrontMatter(content []string) (fm frontMatter, ok bool, err error) {
+	doc := newDocStructure()
+	doc.update(content)
+	return readFrontMatter(content, doc)
+}
+
+// readFrontMatter is parseFrontMatter with doc already built from content
+func readFrontMatter(content []string, doc *docStructure) (fm frontMatter, ok bool, err error) {
+	format, end, ok := frontMatterBlock(content, doc)
+	if !ok {
+		return frontMatter{}, false, nil
+	}
+	fm = frontMatter{format: format, end: end}
+	text := strings.Join(content[1:end], "\n")
+	if format == frontMatterTOML {
+		fm.fields, err = tomlFields(text)
+	} else {
+		fm.fields, err = yamlFields(text)
+	}
+	return fm, true, err
+}
+
+// yamlFields parses YAML front matter, which has to be a mapping
+func yamlFields(text string) ([]metaField, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &root); err != nil {
+		if match := yamlErrorRe.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return nil, &frontMatterError{line: line, msg: match[2]}
+		}
+		return nil, &frontMatterError{line: 1, msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	top := root.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return nil, &frontMatterError{line: top.Line, msg: "want key: value fields"}
+	}
+	var fields []metaField
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		fields = append(fields, metaField{key: top.Content[i].Value, value: yamlValue(top.Content[i+1])})
+	}
+	return fields, nil
+}
+
+// yamlValue writes out a YAML value on one line: lists separated by
+// commas, and mappings in braces
+func yamlValue(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			items[i] = yamlValue(item)
+		}
+		return strings.Join(items, ", ")
+	case yaml.MappingNode:
+		pairs := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			pairs = append(pairs, node.Content[i].Value+": "+yamlValue(node.Content[i+1]))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case yaml.AliasNode:
+		return "*" + node.Value
+	}
+	return node.Value
+}
+
+// tomlFields parses TOML front matter
+func tomlFields(text string) ([]metaField, error) {
+	var values map[string]any
+	md, err := toml.Decode(text, &values)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			return nil, &frontMatterError{line: perr.Position.Line, msg: perr.Message}
+		}
+		return nil, &frontMatterError{line: 1, msg: strings.TrimPrefix(err.Error(), "toml: ")}
+	}
+	var fields []metaField
+	for _, key := range md.Keys() {
+		if len(key) == 1 {
+			fields = append(fields, metaField{key: key[0], value: tomlValue(values[key[0]])})
+		}
+	}
+	return fields, nil
+}
+
+// tomlValue writes out a decoded TOML value on one line, like yamlValue
+func tomlValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(item)
+		}
+		return strings.Join(items, ", ")
+	case []map[string]any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = tomlValue(item)
+		}
+		return strings.Join(items, ", ")
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			pairs[i] = key + ": " + tomlValue(v[key])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return fmt.Sprint(value)
+}
+
+// previewMarkdown returns content as the preview renders it: without its
+// front matter, which glamour would show as a rule and a paragraph, and
+// with card set, with the fields in a table at the top instead
+func previewMarkdown(content []string, card bool) string {
+	fm, ok, err := parseFrontMatter(content)
+	if !ok {
+		return strings.Join(content, "\n")
+	}
+	body := strings.Join(content[fm.end+1:], "\n")
+	if !card || err != nil || len(fm.fields) == 0 {
+		return body
+	}
+	return frontMatterCard(fm.fields) + "\n" + body
+}
+
+// frontMatterCard writes fields as a Markdown table
+func frontMatterCard(fields []metaField) string {
+	escape := strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "<", `\<`)
+	var b strings.Builder
+	b.WriteString("| Front matter | |\n|---|---|\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "| **%s** | %s |\n", escape.Replace(field.key), escape.Replace(field.value))
+	}
+	return b.String()
+}
+
+// blankFrontMatter returns content with the front matter's lines emptied,
+// for renderers that need the rest to keep its line numbers
+func blankFrontMatter(content []string) []string {
+	fm, ok, _ := parseFrontMatter(content)
+	if !ok {
+		return content
+	}
+	blanked := slices.Clone(content)
+	for i := range fm.end + 1 {
+		blanked[i] = ""
+	}
+	return blanked
+}
+
+// frontMatterTitle returns the front matter's title field, if it has one
+func frontMatterTitle(content []string) (string, bool) {
+	fm, ok, err := parseFrontMatter(content)
+	if !ok || err != nil {
+		return "", false
+	}
+	for _, field := range fm.fields {
+		if field.key == "title" && field.value != "" {
+			return field.value, true
+		}
+	}
+	return "", false
+}
+
+// highlightFrontMatter returns the lines of the front matter at the top of
+// content, delimiters included, highlighted as its format by highlight;
+// nil when there is none
+func highlightFrontMatter(content []string, doc *docStructure, highlight func(code, lang string) string) []string {
+	format, end, ok := frontMatterBlock(content, doc)
+	if !ok {
+		return nil
+	}
+	lines := make([]string, end+1)
+	lines[0] = "\033[90m" + content[0] + "\033[0m"
+	lines[end] = "\033[90m" + content[end] + "\033[0m"
+	if end > 1 {
+		highlighted := strings.Split(strings.TrimSuffix(highlight(strings.Join(content[1:end], "\n")+"\n", format), "\n"), "\n")
+		for i := 1; i < end; i++ {
+			lines[i] = content[i]
+			if i-1 < len(highlighted) {
+				lines[i] = highlighted[i-1]
+			}
+		}
+	}
+	return lines
+}
+
+// applyMeta carries out ":meta": without arguments it lists the fields,
+// with a key it shows that field, and with a key and a value it sets the
+// field, adding front matter to a document that has none. It returns the
+// new content, the first line that changed (-1 when nothing did) and a
+// message.
+func applyMeta(content []string, args string) ([]string, int, string, error) {
+	key, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	value = strings.TrimSpace(value)
+
+	fm, ok, err := parseFrontMatter(content)
+	if err != nil {
+		return content, -1, "", fmt.Errorf("front matter %w", err)
+	}
+	if key == "" {
+		if !ok || len(fm.fields) == 0 {
+			return content, -1, "No front matter", nil
+		}
+		keys := make([]string, len(fm.fields))
+		for i, field := range fm.fields {
+			keys[i] = field.key
+		}
+		return content, -1, "Front matter: " + strings.Join(keys, ", "), nil
+	}
+	if value == "" {
+		for _, field := range fm.fields {
+			if field.key == key {
+				return content, -1, key + ": " + field.value, nil
+			}
+		}
+		return content, -1, "", fmt.Errorf("no %s in the front matter", key)
+	}
+
+	if !ok {
+		updated := append([]string{"---", yamlKey(key) + ": " + yamlScalar(value), "---"}, content...)
+		return updated, 0, "Added front matter with " + key, nil
+	}
+	var updated []string
+	var line int
+	if fm.format == frontMatterTOML {
+		updated, line = setTOMLField(content, fm.end, key, value)
+	} else {
+		updated, line = setYAMLField(content, fm.end, key, value)
+	}
+	return updated, line, "Set " + key, nil
+}
+
+// setYAMLField sets key in the YAML front matter closed on line end,
+// replacing its value, however many lines that takes, or adding the field
+// at the end
+func setYAMLField(content []string, end int, key, value string) ([]string, int) {
+	field := yamlKey(key) + ": " + yamlScalar(value)
+
+	var root yaml.Node
+	_ = yaml.Unmarshal([]byte(strings.Join(content[1:end], "\n")), &root)
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return slices.Insert(slices.Clone(content), end, field), end
+	}
+	top := root.Content[0]
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		if top.Content[i].Value != key {
+			continue
+		}
+		// Node lines count from the line after the opening delimiter, which
+		// makes them document lines
+		start, next := top.Content[i].Line, end
+		if i+2 < len(top.Content) {
+			next = top.Content[i+2].Line
+		}
+		last := next - 1
+		for last > start && (strings.TrimSpace(content[last]) == "" || strings.HasPrefix(strings.TrimSpace(content[last]), "#")) {
+			last--
+		}
+		return slices.Replace(slices.Clone(content), start, last+1, field), start
+	}
+	return slices.Insert(slices.Clone(content), end, field), end
+}
+
+// setTOMLField sets key in the TOML front matter closed on line end, among
+// the top-level keys before any table
+func setTOMLField(content []string, end int, key, value string) ([]string, int) {
+	field := tomlKey(key) + " = " + tomlScalar(value)
+	keyRe := regexp.MustCompile(`^\s*(?:` + regexp.QuoteMeta(key) + `|"` + regexp.QuoteMeta(key) + `")\s*=`)
+
+	insert := end
+	for i := 1; i < end; i++ {
+		if tomlTableRe.MatchString(content[i]) {
+			insert = i
+			for insert > 1 && strings.TrimSpace(content[insert-1]) == "" {
+				insert--
+			}
+			break
+		}
+		if !keyRe.MatchString(content[i]) {
+			continue
+		}
+		// A value can go on over several lines: take lines until they parse
+		last := i
+		for last < end-1 {
+			var v map[string]any
+			if _, err := toml.Decode(strings.Join(content[i:last+1], "\n"), &v); err == nil {
+				break
+			}
+			last++
+		}
+		return slices.Replace(slices.Clone(content), i, last+1, field), i
+	}
+	return slices.Insert(slices.Clone(content), insert, field), insert
+}
+
+// yamlScalar writes value for YAML: as it is when it reads as a number,
+// date, boolean, quoted string, [list] or {mapping}, and otherwise quoted as needed
+// to stay a string
+func yamlScalar(value string) string {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err == nil && len(node.Content) == 1 {
+		v := node.Content[0]
+		switch {
+		case (v.Kind == yaml.SequenceNode || v.Kind == yaml.MappingNode) && v.Style == yaml.FlowStyle:
+			return value
+		case v.Kind == yaml.ScalarNode && (v.Tag != "!!str" || v.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0):
+			return value
+		}
+	}
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return strconv.Quote(value)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// yamlKey quotes key if YAML needs it to
+func yamlKey(key string) string {
+	out, err := yaml.Marshal(key)
+	if err != nil {
+		return strconv.Quote(key)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// tomlScalar writes value for TOML: as it is when it is a TOML value
+// already, and otherwise as a string
+func tomlScalar(value string) string {
+	var v map[string]any
+	if _, err := toml.Decode("v = "+value, &v); err == nil {
+		return value
+	}
+	return tomlString(value)
+}
+
+// tomlKey quotes key unless it is a bare key
+func tomlKey(key string) string {
+	if tomlBareKeyRe.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString writes s as a TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// checkFrontMatter validates the buffer's front matter; the status bar
+// shows the problem until it is fixed
+func (m *Model) checkFrontMatter() {
+	_, _, m.frontMatterErr = readFrontMatter(m.content, m.doc)
+}
+
+// metaCommand implements ":meta [KEY [VALUE]]", which lists the front
+// matter's fields, shows one or sets one
+func (m Model) metaCommand(args string) (tea.Model, tea.Cmd) {
+	content, line, msg, err := applyMeta(m.content, args)
+	if err != nil {
+		m.setStatusMsg(err.Error(), true)
+		return m, nil
+	}
+	if line >= 0 {
+		if m.readOnly {
+			m.setStatusMsg(readOnlyMsg, true)
+			return m, nil
+		}
+		// Keep the cursor on its text as lines come and go above it
+		if m.cursor.row >= line {
+			m.cursor.row = max(line, m.cursor.row+len(content)-len(m.content))
+		}
+		m.content = content
+		m.saved = false
+		m.ensureCursorBounds()
+		m.adjustViewport()
+	}
+	m.setStatusMsg(msg, false)
+	return m, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// goalBarWidth is the number of cells in the status bar's progress bar
+const goalBarWidth = 5
+
+// writingGoal is a word target set with ":goal": for the length of the
+// buffer, or for the words written since it was opened
+type writingGoal struct {
+	target  int // 0 when there is no goal
+	session bool
+	reached bool // the target was met and announced
+}
+
+// parseGoal parses the arguments of ":goal N" and ":goal session N"
+func parseGoal(args []string) (writingGoal, error) {
+	var goal writingGoal
+	if len(args) == 2 && args[0] == "session" {
+		goal.session = true
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return goal, errors.New("usage: :goal [session] WORDS, or :goal off")
+	}
+	if args[0] == "off" && !goal.session {
+		return goal, nil
+	}
+	target, err := strconv.Atoi(args[0])
+	if err != nil || target <= 0 {
+		return goal, fmt.Errorf("not a word count: %s", args[0])
+	}
+	goal.target = target
+	return goal, nil
+}
+
+// progress is how far the goal is: the buffer's words, or the words
+// written since the session started with start words
+func (g writingGoal) progress(words, start int) int {
+	if g.session {
+		return max(0, words-start)
+	}
+	return words
+}
+
+// bar shows the progress towards the goal, e.g. "812/1500 ▓▓▓░░"
+func (g writingGoal) bar(words, start int) string {
+	done := g.progress(words, start)
+	filled := min(goalBarWidth, done*goalBarWidth/g.target)
+	return fmt.Sprintf("%d/%d %s%s", done, g.target, strings.Repeat("▓", filled), strings.Repeat("░", goalBarWidth-filled))
+}
+
+// describe says what the goal counts, for status messages
+func (g writingGoal) describe() string {
+	if g.session {
+		return fmt.Sprintf("%d words this session", g.target)
+	}
+	return fmt.Sprintf("%d words", g.target)
+}
+
+// checkGoal marks the goal reached once the words meet it and reports
+// whether that just happened
+func (g *writingGoal) checkGoal(words, start int) bool {
+	if g.target == 0 || g.reached || g.progress(words, start) < g.target {
+		return false
+	}
+	g.reached = true
+	return true
+}
+
+// writingDay is what one file's words did on one day: the count the day
+// started from and the count at the last save
+type writingDay struct {
+	Start   int  `json:"start"`
+	Words   int  `json:"words"`
+	Reached bool `json:"reached,omitempty"` // a goal was met that day
+}
+
+// written is the words the day added, or 0 if it took words away
+func (d writingDay) written() int {
+	return max(0, d.Words-d.Start)
+}
+
+// writingHistory is the state file behind ":goals": for each file, by
+// absolute path, its days by date
+type writingHistory struct {
+	Files map[string]map[string]writingDay `json:"files"`
+}
+
+// historyPath is the state file, under $XDG_STATE_HOME or ~/.local/state
+func historyPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "hani", "history.json"), nil
+}
+
+// loadHistory reads the state file. A missing file is an empty history.
+func loadHistory() (writingHistory, error) {
+	history := writingHistory{Files: make(map[string]map[string]writingDay)}
+	path, err := historyPath()
+	if err != nil {
+		return history, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return history, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return history, fmt.Errorf("invalid history file %s: %w", path, err)
+	}
+	if history.Files == nil {
+		history.Files = make(map[string]map[string]writingDay)
+	}
+	return history, nil
+}
+
+func (h writingHistory) save() error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordWriting notes in the state file that file went from before words,
+// at the last save or when it was opened, to after words today. The file
+// is read again first, so several editors can share it.
+func recordWriting(file string, before, after int, reached bool, now time.Time) error {
+	if file == "" {
+		return nil
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	days := history.Files[file]
+	if days == nil {
+		days = make(map[string]writingDay)
+		history.Files[file] = days
+	}
+
+	date := now.Format(time.DateOnly)
+	day, ok := days[date]
+	if !ok {
+		day.Start = before
+	}
+	day.Words = after
+	day.Reached = day.Reached || reached
+	days[date] = day
+	return history.save()
+}
+
+// dayTotal is the words written on one day across files
+type dayTotal struct {
+	date    string
+	words   int
+	reached bool
+	files   []string
+}
+
+// totals adds up the days of every file, latest first
+func (h writingHistory) totals() []dayTotal {
+	byDate := make(map[string]*dayTotal)
+	for file, days := range h.Files {
+		for date, day := range days {
+			total := byDate[date]
+			if total == nil {
+				total = &dayTotal{date: date}
+				byDate[date] = total
+			}
+			total.words += day.written()
+			total.reached = total.reached || day.Reached
+			if day.written() > 0 {
+				total.files = append(total.files, file)
+			}
+		}
+	}
+
+	totals := make([]dayTotal, 0, len(byDate))
+	for _, total := range byDate {
+		slices.Sort(total.files)
+		totals = append(totals, *total)
+	}
+	slices.SortFunc(totals, func(a, b dayTotal) int { return strings.Compare(b.date, a.date) })
+	return totals
+}
+
+// streaks counts the days in a row with words written: the current streak,
+// which today may still extend, and the longest. totals are latest first.
+func streaks(totals []dayTotal, today time.Time) (current, best int) {
+	run := 0
+	var prev time.Time
+	for _, total := range slices.Backward(totals) {
+		if total.words == 0 {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, total.date)
+		if err != nil {
+			continue
+		}
+		if run > 0 && day.Sub(prev) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		prev = day
+		best = max(best, run)
+	}
+
+	// The streak is current if it reaches today or yesterday
+	date, _ := time.Parse(time.DateOnly, today.Format(time.DateOnly))
+	if run > 0 && date.Sub(prev) <= 24*time.Hour {
+		current = run
+	}
+	return current, best
+}
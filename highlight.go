@@ -92,7 +92,18 @@ func (sh *SyntaxHighlighter) HighlightCodeBlock(code, lang string) string {
 	return result.String()
 }
 
-// HighlightMarkdownLine highlights a single markdown line with minimal styling
+// HighlightFrontMatter highlights the front matter at the top of content as
+// YAML or TOML, delimiters included; HighlightMarkdownLine would take its
+// "---" lines for rules. It returns nil when there is no front matter.
+func (sh *SyntaxHighlighter) HighlightFrontMatter(content []string, doc *docStructure) []string {
+	if sh == nil {
+		return nil
+	}
+	return highlightFrontMatter(content, doc, sh.HighlightCodeBlock)
+}
+
+// HighlightMarkdownLine highlights a single markdown line with minimal
+// styling. Front matter lines go through HighlightFrontMatter instead.
 func (sh *SyntaxHighlighter) HighlightMarkdownLine(line string) string {
 	if sh == nil {
 		return line
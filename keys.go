@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,6 +13,30 @@ import (
 )
 
 func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// The command line captures every key except quit
+	if m.mode == ModeCommand && msg.String() != "ctrl+c" && msg.String() != "ctrl+q" {
+		return m.handleCommandMode(msg)
+	}
+
+	// So do the task view, location lists and statistics
+	if m.tasks.open && msg.String() != "ctrl+c" && msg.String() != "ctrl+q" {
+		return m.handleTaskViewMode(msg)
+	}
+	if m.locations.open && msg.String() != "ctrl+c" && msg.String() != "ctrl+q" {
+		return m.handleLocationListMode(msg)
+	}
+	if m.stats.open && msg.String() != "ctrl+c" && msg.String() != "ctrl+q" {
+		return m.handleStatsViewMode(msg)
+	}
+	if m.spellPopup.open && msg.String() != "ctrl+c" && msg.String() != "ctrl+q" {
+		return m.handleSpellPopup(msg)
+	}
+	if m.completion.open && m.mode == ModeInsert && m.activeTab == TabEditor {
+		if updated, ok := m.handleCompletionKey(msg); ok {
+			return updated, nil
+		}
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "ctrl+q":
 		return m, tea.Quit
@@ -20,6 +45,15 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.saveFile()
 
 	case "tab":
+		if updated, ok := m.snippetTab(1); ok {
+			return updated, nil
+		}
+		if updated, ok := m.moveTableCell(1); ok {
+			return updated, nil
+		}
+		if updated, ok := m.indentListItem(1); ok {
+			return updated, nil
+		}
 		if m.activeTab == TabEditor {
 			m.activeTab = TabPreview
 		} else {
@@ -28,6 +62,15 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "shift+tab":
+		if updated, ok := m.snippetTab(-1); ok {
+			return updated, nil
+		}
+		if updated, ok := m.moveTableCell(-1); ok {
+			return updated, nil
+		}
+		if updated, ok := m.indentListItem(-1); ok {
+			return updated, nil
+		}
 		if m.activeTab == TabEditor {
 			m.activeTab = TabPreview
 		} else {
@@ -40,9 +83,12 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.activeTab == TabEditor {
 		switch m.mode {
 		case ModeNormal:
+			if m.outlineFocus {
+				return m.handleOutlineMode(msg)
+			}
 			return m.handleNormalMode(msg)
 		case ModeInsert:
-			return m.handleInsertMode(msg)
+			return m.typeWithCompletion(msg)
 		}
 	} else if m.activeTab == TabPreview {
 		// Handle scrolling in preview mode
@@ -52,11 +98,49 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// readOnlyNormalKeys are the normal-mode keys that change the buffer or
+// enter insert mode, refused while the buffer is read-only
+var readOnlyNormalKeys = map[string]bool{
+	"i": true, "a": true, "A": true, "o": true, "O": true, "x": true, "dd": true, "gc": true, "z=": true,
+}
+
+// pendingKeys are the starts of normal mode commands longer than one key
+var pendingKeys = map[string]bool{
+	"g": true, "d": true, "]": true, "[": true, "z": true, "y": true, "c": true,
+	"gq": true, "gqa": true, "gqi": true, "gqg": true,
+}
+
 func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Ensure cursor is within bounds before any operation
 	m.ensureCursorBounds()
 
-	switch msg.String() {
+	// "g", "d", "]", "[" and "z" wait for the next key to make "gg", "gO",
+	// "gc", "gf", "gz", "dd", "]d", "[d", "]s", "[s", "z=" or "zg", "gq"
+	// for the motion it reflows over, and "ys", "cs" and "ds" for their
+	// motion and characters
+	key := msg.String()
+	if m.pendingKey != "" {
+		key, m.pendingKey = m.pendingKey+key, ""
+	}
+	if pendingKeys[key] {
+		m.pendingKey = key
+		return m, nil
+	}
+	if motion, ok := strings.CutPrefix(key, "gq"); ok {
+		return m.reflowCommand(motion)
+	}
+	for _, op := range []string{"ys", "cs", "ds"} {
+		if rest, ok := strings.CutPrefix(key, op); ok {
+			return m.surroundKeys(op, rest)
+		}
+	}
+
+	if m.readOnly && readOnlyNormalKeys[key] {
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+
+	switch key {
 	case "h", "left":
 		if m.cursor.col > 0 {
 			m.cursor.col--
@@ -109,6 +193,48 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.adjustViewport()
 		return m, nil
 
+	case "gO":
+		return m.toggleOutline()
+
+	case "gz":
+		return m.toggleZen()
+
+	case "]d":
+		return m.gotoDiagnostic(1)
+
+	case "[d":
+		return m.gotoDiagnostic(-1)
+
+	case "]s":
+		return m.gotoMisspelling(1)
+
+	case "[s":
+		return m.gotoMisspelling(-1)
+
+	case "z=":
+		return m.suggestSpelling()
+
+	case "zg":
+		return m.goodWord(nil)
+
+	case "gf", "enter":
+		// Follow the link under the cursor; gf also opens a plain file name
+		return m.followLinkAt(key == "gf")
+
+	case "ctrl+o":
+		return m.jumpBack()
+
+	case "gc":
+		// Tick or clear the task checkbox on the current line
+		updated, cursor, ok := toggleTaskAt(m.content, m.cursor, taskDate(m.config))
+		if !ok {
+			m.setStatusMsg("Not a list item", true)
+			return m, nil
+		}
+		m.content, m.cursor = updated, cursor
+		m.saved = false
+		return m, nil
+
 	case "G":
 		m.cursor.row = len(m.content) - 1
 		m.cursor.col = len(m.content[m.cursor.row])
@@ -119,6 +245,11 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.mode = ModeInsert
 		return m, nil
 
+	case ":":
+		m.mode = ModeCommand
+		m.commandLine = ""
+		return m, nil
+
 	case "a":
 		m.mode = ModeInsert
 		if m.cursor.col < len(m.content[m.cursor.row]) {
@@ -139,7 +270,6 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cursor.row++
 		m.cursor.col = 0
 		m.saved = false
-		m.codeBlocksDirty = true
 		m.adjustViewport()
 		return m, nil
 
@@ -150,7 +280,6 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.content = append(m.content[:m.cursor.row], append([]string{newLine}, m.content[m.cursor.row:]...)...)
 		m.cursor.col = 0
 		m.saved = false
-		m.codeBlocksDirty = true
 		m.adjustViewport()
 		return m, nil
 
@@ -160,7 +289,6 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			line := m.content[m.cursor.row]
 			m.content[m.cursor.row] = line[:m.cursor.col] + line[m.cursor.col+1:]
 			m.saved = false
-			m.codeBlocksDirty = true
 		} else if m.cursor.row < len(m.content)-1 {
 			// At end of line, join with next line
 			currentLine := m.content[m.cursor.row]
@@ -168,7 +296,6 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.content[m.cursor.row] = currentLine + nextLine
 			m.content = append(m.content[:m.cursor.row+1], m.content[m.cursor.row+2:]...)
 			m.saved = false
-			m.codeBlocksDirty = true
 		}
 		return m, nil
 
@@ -183,12 +310,10 @@ func (m Model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.cursor.col = len(m.content[m.cursor.row])
 			}
 			m.saved = false
-			m.codeBlocksDirty = true
 		} else {
 			m.content[0] = ""
 			m.cursor.col = 0
 			m.saved = false
-			m.codeBlocksDirty = true
 		}
 		m.adjustViewport()
 		return m, nil
@@ -224,7 +349,7 @@ func (m *Model) handlePreviewMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "j", "down":
 		// Calculate max scroll based on rendered content
-		markdown := strings.Join(m.content, "\n")
+		markdown := previewMarkdown(m.content, m.config.FrontMatterCard)
 		if strings.TrimSpace(markdown) != "" && m.renderer != nil {
 			if rendered, err := m.renderer.Render(markdown); err == nil {
 				lines := strings.Split(rendered, "\n")
@@ -245,9 +370,19 @@ func (m *Model) handlePreviewMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Go to top
 		m.previewOffset = 0
 		return m, nil
+	case ":":
+		m.mode = ModeCommand
+		m.commandLine = ""
+		return m, nil
+	case "n":
+		return m.selectPreviewLink(1)
+	case "N":
+		return m.selectPreviewLink(-1)
+	case "enter":
+		return m.followPreviewLink()
 	case "G":
 		// Go to bottom
-		markdown := strings.Join(m.content, "\n")
+		markdown := previewMarkdown(m.content, m.config.FrontMatterCard)
 		if strings.TrimSpace(markdown) != "" && m.renderer != nil {
 			if rendered, err := m.renderer.Render(markdown); err == nil {
 				lines := strings.Split(rendered, "\n")
@@ -261,8 +396,15 @@ func (m *Model) handlePreviewMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.readOnly {
+		m.mode = ModeNormal
+		m.setStatusMsg(readOnlyMsg, true)
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "esc":
+		m.formatTable()
 		m.mode = ModeNormal
 		if m.cursor.col > 0 {
 			m.cursor.col--
@@ -304,6 +446,15 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "enter":
+		// Continue (or end) a list or quote
+		if content, cursor, ok := listEnter(m.content, m.cursor); ok {
+			m.content = content
+			m.cursor = cursor
+			m.saved = false
+			m.adjustViewport()
+			return m, nil
+		}
+
 		// Split line at cursor position
 		currentLine := m.content[m.cursor.row]
 		beforeCursor := currentLine[:m.cursor.col]
@@ -315,18 +466,22 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cursor.row++
 		m.cursor.col = 0
 		m.saved = false
-		m.codeBlocksDirty = true
 		m.adjustViewport()
 		return m, nil
 
 	case "backspace":
+		if line, col, ok := pairBackspace(m.content[m.cursor.row], m.cursor.col, m.config.AutoPairs); ok {
+			// Delete an empty pair
+			m.content[m.cursor.row], m.cursor.col = line, col
+			m.saved = false
+			return m, nil
+		}
 		if m.cursor.col > 0 {
 			// Delete character before cursor
 			line := m.content[m.cursor.row]
 			m.content[m.cursor.row] = line[:m.cursor.col-1] + line[m.cursor.col:]
 			m.cursor.col--
 			m.saved = false
-			m.codeBlocksDirty = true
 		} else if m.cursor.row > 0 {
 			// Join with previous line
 			prevLine := m.content[m.cursor.row-1]
@@ -336,7 +491,6 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.cursor.row--
 			m.cursor.col = len(prevLine)
 			m.saved = false
-			m.codeBlocksDirty = true
 		}
 		m.adjustViewport()
 		return m, nil
@@ -347,7 +501,6 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			line := m.content[m.cursor.row]
 			m.content[m.cursor.row] = line[:m.cursor.col] + line[m.cursor.col+1:]
 			m.saved = false
-			m.codeBlocksDirty = true
 		} else if m.cursor.row < len(m.content)-1 {
 			// At end of line, join with next line
 			currentLine := m.content[m.cursor.row]
@@ -355,11 +508,16 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.content[m.cursor.row] = currentLine + nextLine
 			m.content = append(m.content[:m.cursor.row+1], m.content[m.cursor.row+2:]...)
 			m.saved = false
-			m.codeBlocksDirty = true
 		}
 		return m, nil
 
-	case "ctrl+v", "ctrl+p", "shift+insert":
+	case "ctrl+n":
+		return m.startCompletion(1)
+
+	case "ctrl+p":
+		return m.startCompletion(-1)
+
+	case "ctrl+v", "shift+insert":
 		// Special paste handler to completely avoid render loops with code blocks
 		clipboard := getClipboard()
 		if clipboard == "" {
@@ -394,9 +552,7 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.cursor.row += len(lines) - 1
 				m.cursor.col = len(lines[len(lines)-1])
 				m.saved = false
-				// Completely disable code block tracking for chunked paste operations
-				// m.codeBlocksDirty = true  // DISABLED FOR PASTE
-				fmt.Fprintf(os.Stderr, "DEBUG: Chunked paste complete, content_lines=%d, NO codeBlocksDirty set\n", len(m.content))
+				fmt.Fprintf(os.Stderr, "DEBUG: Chunked paste complete, content_lines=%d\n", len(m.content))
 				return m, nil
 			}
 		}
@@ -433,20 +589,31 @@ func (m Model) handleInsertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 		m.saved = false
-		// Completely disable code block tracking for paste operations to prevent render loops
-		// m.codeBlocksDirty = true  // DISABLED FOR PASTE
-		fmt.Fprintf(os.Stderr, "DEBUG: Paste complete, content_lines=%d, NO codeBlocksDirty set\n", len(m.content))
+		fmt.Fprintf(os.Stderr, "DEBUG: Paste complete, content_lines=%d\n", len(m.content))
 		return m, nil
 
 	default:
 		// Insert character
 		if len(msg.String()) == 1 {
 			char := msg.String()
+			_, inCode := m.doc.codeBlockAt(m.cursor.row)
+			if line, col, ok := autoPair(m.content[m.cursor.row], m.cursor.col, char[0], m.config.AutoPairs, inCode); ok {
+				m.content[m.cursor.row], m.cursor.col = line, col
+				m.saved = false
+				return m, nil
+			}
 			line := m.content[m.cursor.row]
 			m.content[m.cursor.row] = line[:m.cursor.col] + char + line[m.cursor.col:]
 			m.cursor.col++
 			m.saved = false
-			m.codeBlocksDirty = true
+
+			// Break the line once it passes the text width
+			if m.config.HardWrap && char != " " {
+				if content, cursor, ok := hardWrap(m.content, m.cursor, m.config.WordWrap); ok {
+					m.content, m.cursor = content, cursor
+					m.adjustViewport()
+				}
+			}
 		}
 		return m, nil
 	}
@@ -506,7 +673,7 @@ func (m *Model) adjustViewport() {
 	}
 
 	// Horizontal scrolling with improved logic
-	contentWidth := m.width - 3 // account for UI elements
+	contentWidth := m.editorWidth() - m.gutterWidth() - 3 // account for UI elements
 	if contentWidth < 1 {
 		contentWidth = 1
 	}
@@ -526,6 +693,30 @@ func (m *Model) adjustViewport() {
 }
 
 func (m Model) saveFile() (tea.Model, tea.Cmd) {
+	return m.writeBuffer(false)
+}
+
+// writeBuffer saves the buffer to its file. force (":w!") overrides the
+// read-only state and the file's missing write permission, where possible.
+func (m Model) writeBuffer(force bool) (tea.Model, tea.Cmd) {
+	if m.readOnly && !force {
+		m.setStatusMsg("File is read-only (add ! to override, or :w !sudo tee %)", true)
+		return m, nil
+	}
+
+	if m.config.FormatOnSave {
+		m.formatBuffer(0, len(m.content)-1)
+	}
+	tocErr := m.refreshTOC()
+
+	// In pipe mode the written buffer is printed to stdout when hani exits
+	if m.pipe {
+		m.pipeOutput = append([]string(nil), m.content...)
+		m.saved = true
+		m.setStatusMsg("Buffer will be written to stdout on exit", false)
+		return m, nil
+	}
+
 	filename := m.filename
 	if filename == "" {
 		filename = "untitled.md"
@@ -542,7 +733,12 @@ func (m Model) saveFile() (tea.Model, tea.Cmd) {
 		}
 	}
 
-	err := os.WriteFile(filename, []byte(content), 0644)
+	var err error
+	if force {
+		err = forceWriteFile(filename, []byte(content))
+	} else {
+		err = os.WriteFile(filename, []byte(content), 0644)
+	}
 
 	if err != nil {
 		m.setStatusMsg("Error saving file: "+err.Error(), true)
@@ -550,11 +746,79 @@ func (m Model) saveFile() (tea.Model, tea.Cmd) {
 	}
 
 	m.saved = true
-	m.codeBlocksDirty = true // Mark for rebuild since content changed
+	if tocErr != nil {
+		m.setStatusMsg("File saved: "+filename+" (TOC not updated: "+tocErr.Error()+")", true)
+		return m, nil
+	}
+	if err := m.recordWriting(); err != nil {
+		m.setStatusMsg("File saved: "+filename+" (writing history not updated: "+err.Error()+")", true)
+		return m, nil
+	}
 	m.setStatusMsg("File saved: "+filename, false)
 	return m, nil
 }
 
+// moveTableCell handles Tab and Shift+Tab in insert mode inside a table:
+// the table is realigned and the cursor moves to the next or previous cell
+func (m Model) moveTableCell(dir int) (Model, bool) {
+	if m.activeTab != TabEditor || m.mode != ModeInsert || m.readOnly {
+		return m, false
+	}
+	content, cursor, ok := tableNextCell(m.content, m.cursor, dir)
+	if !ok {
+		return m, false
+	}
+	m.setTableContent(content, cursor)
+	return m, true
+}
+
+// indentListItem handles Tab and Shift+Tab in insert mode on a list item,
+// nesting it under the item above or moving it out a level
+func (m Model) indentListItem(dir int) (Model, bool) {
+	if m.activeTab != TabEditor || m.mode != ModeInsert || m.readOnly {
+		return m, false
+	}
+	content, cursor, ok := listIndent(m.content, m.cursor, dir)
+	if !ok {
+		return m, false
+	}
+	m.content = content
+	m.cursor = cursor
+	m.saved = false
+	m.adjustViewport()
+	return m, true
+}
+
+// formatTable realigns the table under the cursor when leaving insert mode
+func (m *Model) formatTable() {
+	if content, cursor, ok := formatTableAt(m.content, m.cursor); ok {
+		m.setTableContent(content, cursor)
+	}
+}
+
+// setTableContent replaces the buffer after a table edit, which may leave
+// the text unchanged
+func (m *Model) setTableContent(content []string, cursor Position) {
+	if !slices.Equal(content, m.content) {
+		m.content = content
+		m.saved = false
+	}
+	m.cursor = cursor
+	m.adjustViewport()
+}
+
+// refreshTOC regenerates the table of contents, if the buffer has one,
+// before it is written
+func (m *Model) refreshTOC() error {
+	content, row, changed, err := updateTOC(m.content, m.cursor.row)
+	if changed {
+		m.content = content
+		m.cursor.row = row
+		m.adjustViewport()
+	}
+	return err
+}
+
 // Word movement functions
 func (m Model) nextWord() Position {
 	row := m.cursor.row
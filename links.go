@@ -0,0 +1,324 @@
+package main
+
+import (
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	// linkInlineRe matches "[text](dest "title")" and images, allowing one
+	// level of brackets in the text so "[![badge](x.svg)](url)" is one link
+	linkInlineRe = regexp.MustCompile(`!?\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\(\s*(<[^>]*>|[^\s()]*(?:\([^\s()]*\)[^\s()]*)*)(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*\)`)
+	linkRefRe    = regexp.MustCompile(`!?\[((?:[^\[\]]|\[[^\[\]]*\])*)\](?:\[([^\[\]]*)\])?`)
+	linkAutoRe   = regexp.MustCompile(`<((?:https?|ftp|mailto):[^\s<>]+)>`)
+	linkBareRe   = regexp.MustCompile(`https?://[^\s<>()\[\]]*[^\s<>()\[\].,;:!?'"*_]`)
+	linkDefRe    = regexp.MustCompile(`^ {0,3}\[((?:[^\[\]\\]|\\.)+)\]:\s*(<[^>]*>|\S+)(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*$`)
+	linkSchemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	htmlAnchorRe = regexp.MustCompile(`<a\s[^>]*\b(?:name|id)\s*=\s*"([^"]*)"`)
+)
+
+// maxJumps bounds the jump list Ctrl+O walks back through
+const maxJumps = 100
+
+// jump is a place the cursor left by following a link
+type jump struct {
+	file string
+	pos  Position
+}
+
+// mdLink is a link in a line of Markdown source
+type mdLink struct {
+	line       int
+	start, end int    // byte columns of the whole link
+	text       string // link text, image alt text or the URL itself
+	target     string // destination, "" for an undefined reference
+	ref        string // label of a reference link; "" for inline links
+	defLine    int    // line of a reference link's definition
+	def        bool   // the link is a reference definition line
+}
+
+// linkDef is a reference definition, "[label]: destination"
+type linkDef struct {
+	line   int
+	target string
+}
+
+// normalizeLabel folds a reference label the way Markdown matches them:
+// case-insensitively, with runs of whitespace as one space
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// linkDefinitions returns the document's reference definitions by label.
+// The first definition of a label wins.
+func linkDefinitions(content []string, doc *docStructure) map[string]linkDef {
+	defs := make(map[string]linkDef)
+	for i, line := range content {
+		match := linkDefRe.FindStringSubmatch(line)
+		if match == nil || inCodeOrFrontMatter(doc, i) {
+			continue
+		}
+		label := normalizeLabel(match[1])
+		if _, seen := defs[label]; !seen && label != "" {
+			defs[label] = linkDef{line: i, target: strings.Trim(match[2], "<>")}
+		}
+	}
+	return defs
+}
+
+// inCodeOrFrontMatter reports whether line i is code or front matter, where
+// brackets and URLs are not links
+func inCodeOrFrontMatter(doc *docStructure, i int) bool {
+	if _, inCode := doc.codeBlockAt(i); inCode {
+		return true
+	}
+	_, end, ok := doc.frontMatter()
+	return ok && i <= end
+}
+
+// lineLinks finds the links on line i: inline links and images, reference
+// links, autolinks and bare URLs. A shortcut reference ("[label]") only
+// counts when the label is defined, so task boxes and stray brackets don't.
+func lineLinks(line string, i int, defs map[string]linkDef) []mdLink {
+	if match := linkDefRe.FindStringSubmatch(line); match != nil {
+		return []mdLink{{line: i, start: 0, end: len(line), text: match[1], target: strings.Trim(match[2], "<>"), ref: match[1], def: true}}
+	}
+
+	// Matched text is blanked out so later patterns don't find links in it;
+	// code spans are blanked first since they hold no links at all
+	masked := []byte(line)
+	mask := func(start, end int) {
+		for j := start; j < end; j++ {
+			masked[j] = ' '
+		}
+	}
+	for _, span := range inlineCodeSpanRe.FindAllStringIndex(line, -1) {
+		mask(span[0], span[1])
+	}
+
+	var links []mdLink
+	for _, m := range linkInlineRe.FindAllSubmatchIndex(masked, -1) {
+		links = append(links, mdLink{line: i, start: m[0], end: m[1], text: line[m[2]:m[3]], target: strings.Trim(line[m[4]:m[5]], "<>")})
+		mask(m[0], m[1])
+	}
+	for _, m := range linkRefRe.FindAllSubmatchIndex(masked, -1) {
+		text, label := line[m[2]:m[3]], line[m[2]:m[3]]
+		if m[4] >= 0 && m[5] > m[4] {
+			label = line[m[4]:m[5]] // [text][label]; "[text][]" uses the text
+		}
+		def, defined := defs[normalizeLabel(label)]
+		if !defined && m[4] < 0 {
+			continue
+		}
+		links = append(links, mdLink{line: i, start: m[0], end: m[1], text: text, target: def.target, ref: label, defLine: def.line})
+		mask(m[0], m[1])
+	}
+	for _, re := range []*regexp.Regexp{linkAutoRe, linkBareRe} {
+		for _, m := range re.FindAllIndex(masked, -1) {
+			target := strings.Trim(line[m[0]:m[1]], "<>")
+			links = append(links, mdLink{line: i, start: m[0], end: m[1], text: target, target: target})
+			mask(m[0], m[1])
+		}
+	}
+
+	slices.SortFunc(links, func(a, b mdLink) int { return a.start - b.start })
+	return links
+}
+
+// documentLinks returns every link in content outside code blocks and front
+// matter, in document order
+func documentLinks(content []string) []mdLink {
+	doc := newDocStructure()
+	doc.update(content)
+	defs := linkDefinitions(content, doc)
+
+	var links []mdLink
+	for i, line := range content {
+		if inCodeOrFrontMatter(doc, i) || !strings.ContainsAny(line, "[<:") {
+			continue
+		}
+		links = append(links, lineLinks(line, i, defs)...)
+	}
+	return links
+}
+
+// linkAt returns the link under pos
+func linkAt(content []string, pos Position) (mdLink, bool) {
+	doc := newDocStructure()
+	doc.update(content)
+	if inCodeOrFrontMatter(doc, pos.row) {
+		return mdLink{}, false
+	}
+
+	for _, link := range lineLinks(content[pos.row], pos.row, linkDefinitions(content, doc)) {
+		if link.start <= pos.col && pos.col < link.end {
+			return link, true
+		}
+	}
+	return mdLink{}, false
+}
+
+// fileNameAt returns the file name at col, for following a path written
+// as plain text the way vim's gf does
+func fileNameAt(line string, col int) string {
+	isName := func(c byte) bool {
+		return c > ' ' && !strings.ContainsRune("()[]<>\"'`,;", rune(c))
+	}
+	if col >= len(line) || !isName(line[col]) {
+		return ""
+	}
+	start, end := col, col
+	for start > 0 && isName(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isName(line[end]) {
+		end++
+	}
+	return strings.TrimRight(line[start:end], ".:!?")
+}
+
+// linkDest is where a link destination leads
+type linkDest struct {
+	url    string // a URL for the opener
+	file   string // a local file; empty for a place in the document itself
+	anchor string // heading anchor, without the "#"
+}
+
+// resolveLink interprets a link destination found in docFile. Relative paths
+// are taken from the document's directory.
+func resolveLink(target, docFile string) linkDest {
+	target = strings.Trim(target, "<>")
+	if linkSchemeRe.MatchString(target) && !strings.HasPrefix(strings.ToLower(target), "file:") {
+		return linkDest{url: target}
+	}
+	target = strings.TrimPrefix(target, "file://")
+
+	path, anchor, _ := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	if unescaped, err := url.PathUnescape(anchor); err == nil {
+		anchor = unescaped
+	}
+	if path == "" {
+		return linkDest{anchor: anchor}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(docFile), path)
+	}
+	return linkDest{file: path, anchor: anchor}
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// anchorLine returns the line a "#anchor" points at: the heading whose
+// GitHub anchor it is, or an HTML <a name> or <a id> tag
+func anchorLine(content []string, anchor string) (int, bool) {
+	doc := newDocStructure()
+	doc.update(content)
+
+	slugs := newSlugger()
+	for _, h := range doc.headings {
+		if strings.EqualFold(slugs.slug(h.Text), anchor) {
+			return h.Line, true
+		}
+	}
+	for i, line := range content {
+		for _, match := range htmlAnchorRe.FindAllStringSubmatch(line, -1) {
+			if match[1] == anchor && !inCodeOrFrontMatter(doc, i) {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// defaultOpener is the command that opens a URL or file in its usual
+// application on this platform
+func defaultOpener() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "rundll32 url.dll,FileProtocolHandler"
+	}
+	return "xdg-open"
+}
+
+// openExternal hands target to the opener command (the "opener" setting,
+// or the platform's default) without waiting for it
+func openExternal(opener, target string) error {
+	if strings.TrimSpace(opener) == "" {
+		opener = defaultOpener()
+	}
+	fields := strings.Fields(opener)
+	cmd := exec.Command(fields[0], append(fields[1:], target)...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// renderedTextLine returns the first line of the rendered preview that
+// shows text, or -1
+func renderedTextLine(rendered []string, text string) int {
+	for i, line := range rendered {
+		if strings.Contains(ansi.Strip(line), text) {
+			return i
+		}
+	}
+	return -1
+}
+
+// renderedLinkLine finds the line of the rendered preview that shows
+// links[sel]. The links are looked up in order so a link text that appears
+// several times resolves to the right occurrence. It returns -1 if the link
+// can't be found.
+func renderedLinkLine(rendered []string, links []mdLink, sel int) int {
+	plain := make([]string, len(rendered))
+	for i, line := range rendered {
+		plain[i] = ansi.Strip(line)
+	}
+
+	line, col := 0, 0
+	found := -1
+	for i := 0; i <= sel && i < len(links); i++ {
+		text := stripInlineMarkdown(links[i].text)
+		if words := strings.Fields(text); len(words) > 3 {
+			text = strings.Join(words[:3], " ") // long texts wrap
+		}
+		if text == "" {
+			continue
+		}
+		for l := line; l < len(plain); l++ {
+			from := 0
+			if l == line {
+				from = min(col, len(plain[l]))
+			}
+			if at := strings.Index(plain[l][from:], text); at >= 0 {
+				line, col = l, from+at+len(text)
+				if i == sel {
+					found = l
+				}
+				break
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// lintConfigName is the per-project rule configuration, looked up from the
+// document's directory upwards. It uses markdownlint's format, so an
+// existing .markdownlint.json applies as it is.
+const lintConfigName = ".markdownlint.json"
+
+// thematicBreakRe matches "***", "- - -" and the like, which look like
+// list items to parseListItem
+var thematicBreakRe = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+
+// lintDiagnostic is a problem a lint rule found in a document
+type lintDiagnostic struct {
+	File    string
+	Line    int // 0-based
+	Col     int // 0-based byte column where the problem starts
+	End     int // byte column where it ends, for underlining
+	Rule    string
+	Name    string
+	Message string
+}
+
+func (d lintDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s/%s %s", d.File, d.Line+1, d.Col+1, d.Rule, d.Name, d.Message)
+}
+
+// lintRule is a check with markdownlint's ID and name, so configurations
+// can refer to it by either
+type lintRule struct {
+	id    string
+	name  string
+	about string
+	check func(c *lintContext)
+}
+
+var lintRules = []lintRule{
+	{"MD001", "heading-increment", "heading levels go up one at a time", lintHeadingIncrement},
+	{"MD004", "ul-style", "bullet markers are consistent (style: consistent, dash, asterisk, plus)", lintListMarkers},
+	{"MD009", "no-trailing-spaces", "no trailing spaces, except br_spaces (2) for a line break", lintTrailingSpaces},
+	{"MD013", "line-length", "lines fit in line_length (word_wrap); code_blocks, tables and headings are checked too", lintLineLength},
+	{"MD025", "single-h1", "one top-level heading per document", lintSingleH1},
+	{"MD034", "no-bare-urls", "URLs are written as links or <autolinks>", lintBareURLs},
+	{"MD040", "fenced-code-language", "fenced code blocks name their language", lintCodeLanguage},
+}
+
+// lookupLintRule finds a rule by ID or name, ignoring case
+func lookupLintRule(key string) (lintRule, bool) {
+	for _, rule := range lintRules {
+		if strings.EqualFold(key, rule.id) || strings.EqualFold(key, rule.name) {
+			return rule, true
+		}
+	}
+	return lintRule{}, false
+}
+
+// lintConfig says which rules run and with what options
+type lintConfig struct {
+	all        bool // rules the file doesn't mention run ("default")
+	enabled    map[string]bool
+	options    map[string]map[string]any
+	lineLength int // line-length's limit unless the file sets line_length
+}
+
+// defaultLintConfig runs every rule, with lines as long as the wrap width
+func defaultLintConfig(wordWrap int) lintConfig {
+	return lintConfig{
+		all:        true,
+		enabled:    make(map[string]bool),
+		options:    make(map[string]map[string]any),
+		lineLength: wordWrap,
+	}
+}
+
+// parseLintConfig reads a markdownlint configuration: "default" turns all
+// rules on or off, and each rule, by ID or name, is true, false or an
+// object of options. Keys for rules hani doesn't have are ignored.
+func parseLintConfig(data []byte, wordWrap int) (lintConfig, error) {
+	config := defaultLintConfig(wordWrap)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return config, err
+	}
+	if value, ok := raw["default"]; ok {
+		if err := json.Unmarshal(value, &config.all); err != nil {
+			return config, fmt.Errorf("default: want true or false")
+		}
+	}
+
+	for key, value := range raw {
+		rule, ok := lookupLintRule(key)
+		if !ok {
+			continue
+		}
+		var on bool
+		if err := json.Unmarshal(value, &on); err == nil {
+			config.enabled[rule.id] = on
+			continue
+		}
+		var options map[string]any
+		if err := json.Unmarshal(value, &options); err != nil {
+			return config, fmt.Errorf("%s: want true, false or an object of options", key)
+		}
+		config.enabled[rule.id] = true
+		config.options[rule.id] = options
+	}
+	return config, nil
+}
+
+// readLintConfig reads the configuration file at path
+func readLintConfig(path string, wordWrap int) (lintConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaultLintConfig(wordWrap), err
+	}
+	config, err := parseLintConfig(data, wordWrap)
+	if err != nil {
+		return config, fmt.Errorf("invalid lint config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// loadLintConfig finds the lint configuration for file: the nearest
+// .markdownlint.json in its directory or above, or the defaults. A buffer
+// without a file looks from the working directory.
+func loadLintConfig(file string, wordWrap int) (lintConfig, error) {
+	dir := "."
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return defaultLintConfig(wordWrap), err
+	}
+
+	for {
+		path := filepath.Join(dir, lintConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return readLintConfig(path, wordWrap)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return defaultLintConfig(wordWrap), err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return defaultLintConfig(wordWrap), nil
+		}
+		dir = parent
+	}
+}
+
+func (c lintConfig) runs(rule lintRule) bool {
+	if on, ok := c.enabled[rule.id]; ok {
+		return on
+	}
+	return c.all
+}
+
+// lintContext is what a rule sees: the document, its index and the rule's
+// options
+type lintContext struct {
+	content []string
+	doc     *docStructure
+	config  lintConfig
+	rule    lintRule
+	found   []lintDiagnostic
+}
+
+func (c *lintContext) report(line, start, end int, format string, args ...any) {
+	c.found = append(c.found, lintDiagnostic{
+		Line:    line,
+		Col:     start,
+		End:     end,
+		Rule:    c.rule.id,
+		Name:    c.rule.name,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *lintContext) option(name string) (any, bool) {
+	value, ok := c.config.options[c.rule.id][name]
+	return value, ok
+}
+
+func (c *lintContext) intOption(name string, def int) int {
+	if value, ok := c.option(name); ok {
+		if n, ok := value.(float64); ok {
+			return int(n)
+		}
+	}
+	return def
+}
+
+func (c *lintContext) boolOption(name string, def bool) bool {
+	if value, ok := c.option(name); ok {
+		if b, ok := value.(bool); ok {
+			return b
+		}
+	}
+	return def
+}
+
+func (c *lintContext) stringOption(name, def string) string {
+	if value, ok := c.option(name); ok {
+		if s, ok := value.(string); ok {
+			return s
+		}
+	}
+	return def
+}
+
+// lintDocument runs the configured rules over content, the text of file,
+// and returns what they found in document order
+func lintDocument(content []string, file string, config lintConfig) []lintDiagnostic {
+	doc := newDocStructure()
+	doc.update(content)
+	return lintStructure(content, doc, file, config)
+}
+
+// lintStructure is lintDocument for a buffer whose structure is already
+// indexed in doc
+func lintStructure(content []string, doc *docStructure, file string, config lintConfig) []lintDiagnostic {
+	var found []lintDiagnostic
+	for _, rule := range lintRules {
+		if !config.runs(rule) {
+			continue
+		}
+		c := &lintContext{content: content, doc: doc, config: config, rule: rule}
+		rule.check(c)
+		found = append(found, c.found...)
+	}
+
+	for i := range found {
+		found[i].File = file
+	}
+	slices.SortStableFunc(found, func(a, b lintDiagnostic) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Col - b.Col
+	})
+	return found
+}
+
+// lintHeadingIncrement: MD001 heading-increment
+func lintHeadingIncrement(c *lintContext) {
+	prev := 0
+	for _, h := range c.doc.headings {
+		if prev > 0 && h.Level > prev+1 {
+			c.report(h.Line, 0, len(c.content[h.Line]), "heading level jumps from h%d to h%d", prev, h.Level)
+		}
+		prev = h.Level
+	}
+}
+
+// lintListMarkers: MD004 ul-style
+func lintListMarkers(c *lintContext) {
+	markers := map[string]string{"dash": "-", "asterisk": "*", "plus": "+"}
+	style := c.stringOption("style", "consistent")
+	want, fixed := markers[style]
+
+	for i, line := range c.content {
+		if inCodeOrFrontMatter(c.doc, i) || thematicBreakRe.MatchString(line) {
+			continue
+		}
+		item, ok := parseListItem(line)
+		if !ok || item.marker == "" || item.ordered() {
+			continue
+		}
+		if want == "" && !fixed {
+			want = item.marker // the first bullet sets the style
+		}
+		if item.marker != want {
+			col := len(item.lead)
+			c.report(i, col, col+1, "bullet %q, expected %q", item.marker, want)
+		}
+	}
+}
+
+// lintTrailingSpaces: MD009 no-trailing-spaces
+func lintTrailingSpaces(c *lintContext) {
+	brSpaces := c.intOption("br_spaces", 2)
+	for i, line := range c.content {
+		text := strings.TrimRight(line, " \t")
+		trailing := len(line) - len(text)
+		if trailing == 0 {
+			continue
+		}
+		if _, inCode := c.doc.codeBlockAt(i); inCode {
+			continue
+		}
+		// Two spaces (br_spaces) end a line with a hard break
+		if brSpaces >= 2 && trailing == brSpaces && text != "" && strings.Trim(line[len(text):], " ") == "" {
+			continue
+		}
+		c.report(i, len(text), len(line), "trailing spaces (%d)", trailing)
+	}
+}
+
+// lintLineLength: MD013 line-length. As in markdownlint, a line only counts
+// as too long if it could be wrapped, so a long URL on its own is fine.
+func lintLineLength(c *lintContext) {
+	limit := c.intOption("line_length", c.config.lineLength)
+	if limit <= 0 {
+		return
+	}
+	codeBlocks := c.boolOption("code_blocks", true)
+	tables := c.boolOption("tables", true)
+	headings := c.boolOption("headings", true)
+
+	for i, line := range c.content {
+		if utf8.RuneCountInString(line) <= limit {
+			continue
+		}
+		if _, end, ok := c.doc.frontMatter(); ok && i <= end {
+			continue
+		}
+		if _, inCode := c.doc.codeBlockAt(i); inCode && !codeBlocks {
+			continue
+		}
+		if (!tables && isTableRow(line)) || (!headings && atxHeadingRe.MatchString(line)) || linkDefRe.MatchString(line) {
+			continue
+		}
+
+		// Byte offset of the first character past the limit
+		over := 0
+		for range limit {
+			_, size := utf8.DecodeRuneInString(line[over:])
+			over += size
+		}
+		if !strings.ContainsAny(line[over:], " \t") {
+			continue
+		}
+		c.report(i, over, len(line), "line is %d characters long (limit %d)", utf8.RuneCountInString(line), limit)
+	}
+}
+
+// lintSingleH1: MD025 single-h1
+func lintSingleH1(c *lintContext) {
+	first := -1
+	for _, h := range c.doc.headings {
+		if h.Level != 1 {
+			continue
+		}
+		if first < 0 {
+			first = h.Line
+			continue
+		}
+		c.report(h.Line, 0, len(c.content[h.Line]), "another top-level heading (the first is on line %d)", first+1)
+	}
+}
+
+// lintBareURLs: MD034 no-bare-urls
+func lintBareURLs(c *lintContext) {
+	defs := linkDefinitions(c.content, c.doc)
+	for i, line := range c.content {
+		if inCodeOrFrontMatter(c.doc, i) || !strings.Contains(line, "://") {
+			continue
+		}
+		for _, link := range lineLinks(line, i, defs) {
+			// Bare URLs are the links that start with the URL itself; one in
+			// quotes is an HTML attribute
+			if link.def || !strings.HasPrefix(line[link.start:], "http") {
+				continue
+			}
+			if link.start > 0 && strings.IndexByte(`"'=`, line[link.start-1]) >= 0 {
+				continue
+			}
+			c.report(i, link.start, link.end, "bare URL, write <%s>", link.target)
+		}
+	}
+}
+
+// lintCodeLanguage: MD040 fenced-code-language
+func lintCodeLanguage(c *lintContext) {
+	for _, block := range c.doc.codeBlocks {
+		if block.lang == "" {
+			line := c.content[block.start]
+			c.report(block.start, len(line)-len(strings.TrimLeft(line, " ")), len(line), "fenced code block without a language")
+		}
+	}
+}
+
+// lineDiagnostics returns the diagnostics on line
+func lineDiagnostics(diagnostics []lintDiagnostic, line int) []lintDiagnostic {
+	var found []lintDiagnostic
+	for _, d := range diagnostics {
+		if d.Line == line {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// nextDiagnostic returns the diagnostic after pos (dir > 0) or before it,
+// wrapping around the ends of the document the way ]d and [d do
+func nextDiagnostic(diagnostics []lintDiagnostic, pos Position, dir int) (lintDiagnostic, bool) {
+	if len(diagnostics) == 0 {
+		return lintDiagnostic{}, false
+	}
+	after := func(d lintDiagnostic) bool {
+		return d.Line > pos.row || (d.Line == pos.row && d.Col > pos.col)
+	}
+	if dir > 0 {
+		for _, d := range diagnostics {
+			if after(d) {
+				return d, true
+			}
+		}
+		return diagnostics[0], true
+	}
+	for i := len(diagnostics) - 1; i >= 0; i-- {
+		d := diagnostics[i]
+		if d.Line < pos.row || (d.Line == pos.row && d.Col < pos.col) {
+			return d, true
+		}
+	}
+	return diagnostics[len(diagnostics)-1], true
+}
+
+// diagnosticSpans returns the columns [start, end) of line's diagnostics,
+// at least one column wide so a problem at the end of a line still shows
+func diagnosticSpans(diagnostics []lintDiagnostic, line int) [][2]int {
+	var spans [][2]int
+	for _, d := range diagnostics {
+		if d.Line == line {
+			spans = append(spans, [2]int{d.Col, max(d.End, d.Col+1)})
+		}
+	}
+	return spans
+}
+
+// lintJSON is a diagnostic as `hani lint --format json` prints it, with
+// 1-based lines and columns
+type lintJSON struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndColumn int    `json:"endColumn"`
+	Rule      string `json:"rule"`
+	Name      string `json:"name"`
+	Message   string `json:"message"`
+}
+
+// runLint implements `hani lint`, which reports what the lint rules find in
+// Markdown files and exits with status 1 if there is anything
+func runLint(args []string) int {
+	config := LoadConfig()
+
+	fs := newSubcommandFlags("lint", "hani lint [--format text|json] [--config FILE] FILE|DIR...")
+	format := fs.String("format", "text", "output `format`: text (file:line:col: rule message) or json")
+	configPath := fs.String("config", "", "rule configuration `FILE` (default: the nearest "+lintConfigName+")")
+	rules := fs.Bool("rules", false, "list the rules and exit")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if *rules {
+		for _, rule := range lintRules {
+			fmt.Printf("%s %-22s %s\n", rule.id, rule.name, rule.about)
+		}
+		return 0
+	}
+	if len(paths) == 0 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	var fixed *lintConfig
+	if *configPath != "" {
+		loaded, err := readLintConfig(*configPath, config.WordWrap)
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		fixed = &loaded
+	}
+
+	// Directories stand for the Markdown files under them
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			found, err := markdownFiles(path)
+			if err != nil {
+				return fatalf("%v", err)
+			}
+			files = append(files, found...)
+			continue
+		}
+		files = append(files, path)
+	}
+
+	status := 0
+	found := []lintJSON{}
+	for _, file := range files {
+		source, err := readDocument(file)
+		if err != nil {
+			status = fatalf("%v", err)
+			continue
+		}
+		fileConfig := fixed
+		if fileConfig == nil {
+			loaded, err := loadLintConfig(file, config.WordWrap)
+			if err != nil {
+				status = fatalf("%v", err)
+				continue
+			}
+			fileConfig = &loaded
+		}
+
+		for _, d := range lintDocument(strings.Split(string(source), "\n"), file, *fileConfig) {
+			if *format == "text" {
+				fmt.Println(d)
+			}
+			found = append(found, lintJSON{File: d.File, Line: d.Line + 1, Column: d.Col + 1, EndColumn: d.End + 1, Rule: d.Rule, Name: d.Name, Message: d.Message})
+		}
+	}
+
+	if *format == "json" {
+		out, _ := json.MarshalIndent(found, "", "  ")
+		fmt.Println(string(out))
+	}
+	if len(found) > 0 {
+		fmt.Fprintf(os.Stderr, "hani: %d lint problems (%d files checked)\n", len(found), len(files))
+		status = max(status, 1)
+	}
+	return status
+}
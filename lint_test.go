@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lint runs the rules configured by config (markdownlint JSON, or "" for
+// the defaults) over doc and returns "line:col rule" for each diagnostic,
+// 1-based
+func lint(t *testing.T, doc, config string) []string {
+	t.Helper()
+	c := defaultLintConfig(40)
+	if config != "" {
+		var err error
+		if c, err = parseLintConfig([]byte(config), 40); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var found []string
+	for _, d := range lintDocument(strings.Split(doc, "\n"), "doc.md", c) {
+		found = append(found, fmt.Sprintf("%d:%d %s", d.Line+1, d.Col+1, d.Rule))
+	}
+	return found
+}
+
+func TestLintRules(t *testing.T) {
+	long := strings.TrimSpace(strings.Repeat("word ", 10)) // 49 characters, over the limit of 40
+	tests := []struct {
+		name, doc, config string
+		want              []string
+	}{
+		{"clean", "# Title\n\n## Part\n\nText.\n", "", nil},
+
+		{"heading jump", "# A\n\n### B\n", "", []string{"3:1 MD001"}},
+		{"heading back up", "# A\n\n## B\n\n### C\n\n## D\n", "", nil},
+
+		{"mixed bullets", "- a\n* b\n- c\n", "", []string{"2:1 MD004"}},
+		{"bullet style", "- a\n", `{"MD004": {"style": "asterisk"}}`, []string{"1:1 MD004"}},
+		{"thematic break is no bullet", "- a\n\n* * *\n", "", nil},
+		{"nested bullets", "- a\n  * b\n", "", []string{"2:3 MD004"}},
+
+		{"trailing spaces", "a \nb\n", "", []string{"1:2 MD009"}},
+		{"line break", "a  \nb\n", "", nil},
+		{"br_spaces off", "a  \nb\n", `{"no-trailing-spaces": {"br_spaces": 0}}`, []string{"1:2 MD009"}},
+		{"trailing spaces in code", "```go\nx := 1 \n```\n", "", nil},
+
+		{"long line", long + "\n", "", []string{"1:41 MD013"}},
+		{"long URL", strings.Repeat("x", 50) + "\n", "", nil},
+		{"line_length", long + "\n", `{"MD013": {"line_length": 80}}`, nil},
+		{"long code line", "```go\n" + long + "\n```\n", `{"MD013": {"code_blocks": false}}`, nil},
+		{"long heading", "# " + long + "\n", `{"MD013": {"headings": false}}`, nil},
+
+		{"two h1", "# A\n\n# B\n", "", []string{"3:1 MD025"}},
+		{"setext h1", "A\n===\n\n# B\n", "", []string{"4:1 MD025"}},
+
+		{"bare URL", "See https://example.com.\n", "", []string{"1:5 MD034"}},
+		{"autolink", "See <https://example.com>.\n", "", nil},
+		{"link", "See [it](https://example.com).\n", "", nil},
+		{"URL in an attribute", `<a href="https://example.com">it</a>` + "\n", "", nil},
+		{"URL in code", "`https://example.com`\n", "", nil},
+
+		{"code without a language", "```\nx\n```\n", "", []string{"1:1 MD040"}},
+		{"code with a language", "```go\nx\n```\n", "", nil},
+
+		{"rule off by name", "# A\n\n# B\n", `{"single-h1": false}`, nil},
+		{"default off", "# A\n\n# B\n", `{"default": false, "MD001": true}`, nil},
+		{"front matter", "---\ntitle: " + long + "\n---\n", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lint(t, tt.doc, tt.config); !slices.Equal(got, tt.want) {
+				t.Errorf("lint(%q) = %q, want %q", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLintConfigErrors(t *testing.T) {
+	for _, config := range []string{`{`, `{"default": "yes"}`, `{"MD013": 80}`} {
+		if _, err := parseLintConfig([]byte(config), 80); err == nil {
+			t.Errorf("parseLintConfig(%s) succeeded", config)
+		}
+	}
+}
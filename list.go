@@ -0,0 +1,305 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// listItemRe splits a line into its block prefix: indentation and "> "
+// quote markers, then an optional list marker and task checkbox
+var listItemRe = regexp.MustCompile(`^((?:[ \t]*>[ \t]?)*[ \t]*)(?:([-*+]|(\d{1,9})([.)]))([ \t]+|$)(\[[ xX]\](?:[ \t]+|$))?)?`)
+
+// listItem is the prefix of a list item or blockquote line
+type listItem struct {
+	lead   string // indentation and quote markers before the list marker
+	marker string // "-", "*", "+", "1." or "1)"; empty on a plain quote line
+	number int    // for ordered items
+	delim  string // "." or ")" for ordered items
+	space  string // whitespace after the marker
+	task   bool
+	done   bool // the checkbox is ticked
+	length int  // bytes of the whole prefix, checkbox included
+}
+
+// parseListItem parses the list or quote prefix of line. ok is false for
+// lines that are neither.
+func parseListItem(line string) (listItem, bool) {
+	m := listItemRe.FindStringSubmatch(line)
+	item := listItem{lead: m[1], marker: m[2], delim: m[4], space: m[5], task: m[6] != "", done: strings.ContainsAny(m[6], "xX"), length: len(m[0])}
+	if rest := line[len(item.quote()):]; item.marker != "" && thematicBreakRe.MatchString(rest) {
+		// "* * *" and "- - -" are thematic breaks, not items
+		item = listItem{lead: line[:len(line)-len(strings.TrimLeft(rest, " \t"))]}
+		item.length = len(item.lead)
+	}
+	if item.marker == "" && !strings.Contains(item.lead, ">") {
+		return item, false
+	}
+	if m[3] != "" {
+		item.number, _ = strconv.Atoi(m[3])
+	}
+	if item.marker != "" && item.space == "" {
+		item.space = " " // a bare "-" at the end of the line
+	}
+	return item, true
+}
+
+func (it listItem) ordered() bool {
+	return it.delim != ""
+}
+
+// indent is the column of the list marker, ignoring quote markers
+func (it listItem) indent() int {
+	lead := expandTabs(it.lead)
+	if i := strings.LastIndex(lead, ">"); i >= 0 {
+		lead = strings.TrimPrefix(lead[i+1:], " ")
+	}
+	return len(lead)
+}
+
+// quote returns the "> " markers of the prefix
+func (it listItem) quote() string {
+	if i := strings.LastIndex(it.lead, ">"); i >= 0 {
+		return it.lead[:i+1]
+	}
+	return ""
+}
+
+// withNumber returns the item's marker renumbered to n
+func (it listItem) withNumber(n int) listItem {
+	it.number = n
+	it.marker = strconv.Itoa(n) + it.delim
+	return it
+}
+
+// prefix renders the prefix for a new item like this one
+func (it listItem) prefix() string {
+	if it.marker == "" {
+		return it.lead
+	}
+	prefix := it.lead + it.marker + it.space
+	if it.task {
+		prefix += "[ ] "
+	}
+	return prefix
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
+}
+
+// listEnter handles Enter on a list item or quote line: the new line starts
+// with the same marker (the next number for ordered lists, an unchecked box
+// for tasks). Enter on an empty item ends the list by clearing its marker.
+// ok is false when Enter should just split the line.
+func listEnter(content []string, pos Position) ([]string, Position, bool) {
+	line := content[pos.row]
+	item, ok := parseListItem(line)
+	if !ok || pos.col < item.length {
+		return content, pos, false
+	}
+
+	if strings.TrimSpace(line[item.length:]) == "" {
+		// An empty nested item moves out a level; at the top it ends the list
+		if item.marker != "" && item.indent() > 0 {
+			if updated, moved, ok := listIndent(content, pos, -1); ok {
+				return updated, moved, true
+			}
+		}
+		cleared := "" // an empty quote line ends the quote
+		if item.marker != "" && item.quote() != "" {
+			cleared = item.quote() + " " // stay in the quote, leave the list
+		}
+		content[pos.row] = cleared
+		return content, Position{row: pos.row, col: len(cleared)}, true
+	}
+
+	next := item
+	if item.ordered() {
+		next = item.withNumber(item.number + 1)
+	}
+	prefix := next.prefix()
+	rest := strings.TrimLeft(line[pos.col:], " \t")
+
+	updated := make([]string, 0, len(content)+1)
+	updated = append(updated, content[:pos.row]...)
+	updated = append(updated, line[:item.length]+strings.TrimRight(line[item.length:pos.col], " \t"), prefix+rest)
+	updated = append(updated, content[pos.row+1:]...)
+	return updated, Position{row: pos.row + 1, col: len(prefix)}, true
+}
+
+// listIndent nests the list item at pos under the item above it (dir 1) or
+// moves it out a level (dir -1), taking its nested lines along. Ordered
+// items are renumbered to follow their new siblings.
+func listIndent(content []string, pos Position, dir int) ([]string, Position, bool) {
+	item, ok := parseListItem(content[pos.row])
+	if !ok || item.marker == "" {
+		return content, pos, false
+	}
+	current := item.indent()
+
+	// The new indentation: under the previous sibling's text when nesting,
+	// at the parent's marker when un-nesting
+	target := -1
+	for i := pos.row - 1; i >= 0; i-- {
+		above, ok := parseListItem(content[i])
+		if !ok || above.marker == "" || above.quote() != item.quote() {
+			// Blank lines and indented text inside items don't end the list
+			if strings.TrimSpace(content[i]) == "" || bodyIndent(content[i], item.quote()) > current {
+				continue
+			}
+			break
+		}
+		if dir > 0 && above.indent() == current {
+			target = current + len(above.marker) + len(above.space)
+			break
+		}
+		if dir < 0 && above.indent() < current {
+			target = above.indent()
+			break
+		}
+		if above.indent() < current {
+			break
+		}
+	}
+	if target < 0 {
+		if dir > 0 || current == 0 {
+			return content, pos, false
+		}
+		target = 0
+	}
+	shift := target - current
+
+	// The item's own nested lines move with it
+	end := pos.row
+	for end+1 < len(content) {
+		line := content[end+1]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		below, ok := parseListItem(line)
+		if ok && below.marker != "" && below.indent() <= current {
+			break
+		}
+		if (!ok || below.marker == "") && bodyIndent(line, item.quote()) <= current {
+			break
+		}
+		end++
+	}
+
+	updated := append([]string(nil), content...)
+	for i := pos.row; i <= end; i++ {
+		updated[i] = shiftLine(updated[i], item.quote(), shift)
+	}
+
+	if item.ordered() {
+		moved, _ := parseListItem(updated[pos.row])
+		number := 1
+		for i := pos.row - 1; i >= 0; i-- {
+			above, ok := parseListItem(updated[i])
+			if strings.TrimSpace(updated[i]) == "" {
+				continue
+			}
+			if !ok || above.marker == "" || above.indent() < target {
+				break
+			}
+			if above.indent() == target {
+				if above.ordered() {
+					number = above.number + 1
+				}
+				break
+			}
+		}
+		renumbered := moved.withNumber(number)
+		updated[pos.row] = renumbered.lead + renumbered.marker + updated[pos.row][len(moved.lead)+len(moved.marker):]
+	}
+
+	after, _ := parseListItem(updated[pos.row])
+	col := max(after.length, pos.col+after.length-item.length)
+	return updated, Position{row: pos.row, col: col}, true
+}
+
+// bodyIndent is the indentation of line in columns, after its quote markers
+func bodyIndent(line, quote string) int {
+	body := expandTabs(strings.TrimPrefix(strings.TrimPrefix(line, quote), " "))
+	if quote == "" {
+		body = expandTabs(line)
+	}
+	return len(body) - len(strings.TrimLeft(body, " "))
+}
+
+// shiftLine indents line by shift columns (or removes that many), keeping
+// its quote markers in front
+func shiftLine(line, quote string, shift int) string {
+	body := strings.TrimPrefix(line, quote)
+	space := ""
+	if quote != "" && strings.HasPrefix(body, " ") {
+		space, body = " ", body[1:]
+	}
+	body = expandTabs(body)
+	indent := len(body) - len(strings.TrimLeft(body, " "))
+	return quote + space + strings.Repeat(" ", max(0, indent+shift)) + body[indent:]
+}
+
+// renumberLists renumbers every ordered list in content so its items count
+// up from the list's first number, including nested lists and lists in
+// quotes. Code blocks are left alone. It returns how many lines changed.
+func renumberLists(content []string) ([]string, int) {
+	doc := newDocStructure()
+	doc.update(content)
+
+	type level struct {
+		indent int
+		next   int
+		delim  string
+	}
+	var levels []level
+	quote := ""
+	changed := 0
+	updated := append([]string(nil), content...)
+
+	for i, line := range updated {
+		if _, inCode := doc.codeBlockAt(i); inCode {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		item, ok := parseListItem(line)
+		if item.quote() != quote {
+			levels, quote = nil, item.quote()
+		}
+		if !ok || item.marker == "" {
+			// Text indented less than an item's content ends that item's list
+			width := bodyIndent(line, quote)
+			for len(levels) > 0 && width <= levels[len(levels)-1].indent {
+				levels = levels[:len(levels)-1]
+			}
+			continue
+		}
+
+		indent := item.indent()
+		for len(levels) > 0 && levels[len(levels)-1].indent > indent {
+			levels = levels[:len(levels)-1]
+		}
+		top := len(levels) - 1
+		if top >= 0 && levels[top].indent == indent && levels[top].delim != item.delim {
+			levels, top = levels[:top], top-1 // a different kind of list starts here
+		}
+		if top < 0 || levels[top].indent != indent {
+			levels = append(levels, level{indent: indent, next: item.number, delim: item.delim})
+			top++
+		}
+
+		if item.ordered() {
+			if item.number != levels[top].next {
+				renumbered := item.withNumber(levels[top].next)
+				updated[i] = renumbered.lead + renumbered.marker + line[len(item.lead)+len(item.marker):]
+				changed++
+			}
+			levels[top].next++
+		}
+	}
+	return updated, changed
+}
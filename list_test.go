@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestListEnter(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		pos  Position
+		want string
+		at   Position
+		ok   bool
+	}{
+		{"bullet", "- a", Position{0, 3}, "- a\n- ", Position{1, 2}, true},
+		{"split", "- ab", Position{0, 3}, "- a\n- b", Position{1, 2}, true},
+		{"ordered", "1. a", Position{0, 4}, "1. a\n2. ", Position{1, 3}, true},
+		{"closing paren", "9) a", Position{0, 4}, "9) a\n10) ", Position{1, 4}, true},
+		{"task", "- [x] a", Position{0, 7}, "- [x] a\n- [ ] ", Position{1, 6}, true},
+		{"quote", "> a", Position{0, 3}, "> a\n> ", Position{1, 2}, true},
+		{"empty item ends the list", "- a\n- ", Position{1, 2}, "- a\n", Position{1, 0}, true},
+		{"empty nested item moves out", "- a\n  - ", Position{1, 4}, "- a\n- ", Position{1, 2}, true},
+		{"empty item in a quote", "> - ", Position{0, 4}, "> ", Position{0, 2}, true},
+		{"before the marker", "- a", Position{0, 1}, "- a", Position{0, 1}, false},
+		{"plain text", "a", Position{0, 1}, "a", Position{0, 1}, false},
+		{"thematic break", "* * *", Position{0, 5}, "* * *", Position{0, 5}, false},
+		{"dashed break", "- - -", Position{0, 5}, "- - -", Position{0, 5}, false},
+		{"thematic break in a quote", "> - - -", Position{0, 7}, "> - - -\n> ", Position{1, 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, at, ok := listEnter(strings.Split(tt.doc, "\n"), tt.pos)
+			if ok != tt.ok || strings.Join(got, "\n") != tt.want || at != tt.at {
+				t.Errorf("listEnter(%q, %v) = %q, %v, %v; want %q, %v, %v", tt.doc, tt.pos, strings.Join(got, "\n"), at, ok, tt.want, tt.at, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRenumberLists(t *testing.T) {
+	tests := []struct {
+		name, doc, want string
+		changed         int
+	}{
+		{"counts up", "1. a\n1. b\n1. c", "1. a\n2. b\n3. c", 2},
+		{"keeps the first number", "3. a\n7. b", "3. a\n4. b", 1},
+		{"nested", "1. a\n   1. x\n   5. y\n2. b", "1. a\n   1. x\n   2. y\n2. b", 1},
+		{"after a nested list", "1. a\n   - x\n3. b", "1. a\n   - x\n2. b", 1},
+		{"in a quote", "> 1. a\n> 1. b", "> 1. a\n> 2. b", 1},
+		{"lists apart", "1. a\n\ntext\n\n1. b", "1. a\n\ntext\n\n1. b", 0},
+		{"other delimiter", "1. a\n1) b", "1. a\n1) b", 0},
+		{"code block", "```\n1. a\n3. b\n```", "```\n1. a\n3. b\n```", 0},
+		{"thematic break", "1. a\n* * *\n5. b", "1. a\n* * *\n5. b", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := strings.Split(tt.doc, "\n")
+			before := slices.Clone(content)
+			got, changed := renumberLists(content)
+			if strings.Join(got, "\n") != tt.want || changed != tt.changed {
+				t.Errorf("renumberLists(%q) = %q, %d; want %q, %d", tt.doc, strings.Join(got, "\n"), changed, tt.want, tt.changed)
+			}
+			if !slices.Equal(content, before) {
+				t.Errorf("renumberLists changed its input to %q", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// location is a place in the buffer or another file, with what is there
+type location struct {
+	file string // empty for the buffer
+	line int
+	text string
+}
+
+// locationList shows a list of locations in place of the editor, such as
+// the results of ":checklinks" and ":lint"
+type locationList struct {
+	open    bool
+	title   string
+	entries []location
+	sel     int
+}
+
+// listRow is one line of a list view: text on the left, where it is on the
+// right. Dim rows are drawn greyed out.
+type listRow struct {
+	text     string
+	location string
+	dim      bool
+}
+
+// renderList draws a title line and then the rows, scrolled to keep the
+// selected row in view. The task view and location lists share it.
+func renderList(title string, rows []listRow, sel, width, height int) string {
+	lines := make([]string, height)
+	lines[0] = keyStyle.Render(ansi.Truncate(title, width, "…"))
+
+	visible := height - 1
+	sel = min(sel, max(0, len(rows)-1))
+	offset := max(0, min(sel-visible/2, len(rows)-visible))
+
+	for i := range visible {
+		n := offset + i
+		if n >= len(rows) {
+			break
+		}
+		row := rows[n]
+		locationWidth := ansi.StringWidth(row.location)
+		text := ansi.Truncate(row.text, max(0, width-locationWidth-2), "…")
+		entry := text + strings.Repeat(" ", max(1, width-lipgloss.Width(text)-locationWidth)) + row.location
+
+		switch {
+		case n == sel:
+			lines[i+1] = outlineSelectedStyle.Render(entry)
+		case row.dim:
+			lines[i+1] = separatorStyle.Render(entry)
+		default:
+			lines[i+1] = entry
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jumpToLocation puts the cursor at the start of line in file, opening the
+// file first if it is not the buffer's. The place it leaves goes on the
+// jump list.
+func (m Model) jumpToLocation(file string, line int) (Model, bool) {
+	from := jump{file: m.filename, pos: m.cursor}
+	if file != "" && file != m.filename && !sameFile(file, m.filename) {
+		opened, ok := m.openFile(file)
+		if !ok {
+			return opened, false
+		}
+		m = opened
+	}
+
+	m.jumps = append(m.jumps, from)
+	m.activeTab = TabEditor
+	m.cursor = Position{row: max(0, min(line, len(m.content)-1)), col: 0}
+	m.adjustViewport()
+	return m, true
+}
+
+// handleLocationListMode moves the selection and jumps to the selected
+// location
+func (m Model) handleLocationListMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	entries := m.locations.entries
+	m.locations.sel = min(m.locations.sel, max(0, len(entries)-1))
+
+	switch msg.String() {
+	case "j", "down":
+		if m.locations.sel < len(entries)-1 {
+			m.locations.sel++
+		}
+
+	case "k", "up":
+		if m.locations.sel > 0 {
+			m.locations.sel--
+		}
+
+	case "g", "home":
+		m.locations.sel = 0
+
+	case "G", "end":
+		m.locations.sel = max(0, len(entries)-1)
+
+	case "enter":
+		if len(entries) > 0 {
+			entry := entries[m.locations.sel]
+			jumped, ok := m.jumpToLocation(entry.file, entry.line)
+			if ok {
+				jumped.locations.open = false
+			}
+			return jumped, nil
+		}
+
+	case "esc", "q":
+		m.locations.open = false
+
+	case ":":
+		m.mode = ModeCommand
+		m.commandLine = ""
+	}
+	return m, nil
+}
+
+// renderLocationList draws the open location list
+func (m Model) renderLocationList(height int) string {
+	rows := make([]listRow, len(m.locations.entries))
+	for i, entry := range m.locations.entries {
+		rows[i] = listRow{text: entry.text, location: lineLocation(entry.file, entry.line)}
+	}
+	return renderList(m.locations.title, rows, m.locations.sel, m.width, height)
+}
+
+// lineLocation formats a 0-based line as "file:N", or "N" in the buffer
+func lineLocation(file string, line int) string {
+	if file == "" {
+		return strconv.Itoa(line + 1)
+	}
+	return file + ":" + strconv.Itoa(line+1)
+}
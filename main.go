@@ -11,7 +11,12 @@
 //
 // Usage:
 //
-//	hani [filename]     # Start with optional file
+//	hani [filename]     # Start with optional file (see --help for options)
+//	hani +42 notes.md   # Open at line 42 (+/pattern for the first match)
+//	hani render FILE|-  # Print the rendered document and exit
+//	hani serve FILE     # Preview in the browser, reloading on save
+//	cmd | hani -        # Edit standard input
+//	hani --pipe         # Write the buffer to stdout on :wq
 //
 // Key Bindings:
 //
@@ -30,50 +35,95 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 func main() {
-	// Handle command line arguments
-	if len(os.Args) > 1 {
-		arg := os.Args[1]
+	// Non-interactive subcommands run without starting the editor
+	if code, ok := runSubcommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
 
-		switch arg {
-		case "-v", "--version":
-			PrintVersion()
-			return
-		case "-h", "--help":
-			PrintHelp()
-			return
-		case "--version-short":
-			PrintVersionShort()
-			return
-		}
+	opts, code, exit := parseCommandLine(os.Args[1:])
+	if exit {
+		os.Exit(code)
+	}
+
+	startEditor(opts)
+}
 
-		// If it's not a flag, treat it as a filename
-		if !strings.HasPrefix(arg, "-") {
-			startEditor(arg)
-			return
+// startEditor initializes and runs the editor as the options describe.
+// "-" edits standard input; in pipe mode the written buffer goes to stdout
+// on exit, and quitting without writing exits with status 1.
+func startEditor(opts Options) {
+	config, err := opts.Config()
+	if err != nil {
+		os.Exit(fatalf("%v", err))
+	}
+
+	filename := opts.Filename
+	var input []string
+	if readsStdin(filename, opts.Pipe) {
+		lines, err := readStdinBuffer()
+		if err != nil {
+			log.Fatal(err)
 		}
+		input, filename = lines, ""
+	}
 
-		// Unknown flag
-		fmt.Fprintf(os.Stderr, "Unknown flag: %s\n\n", arg)
-		PrintHelp()
-		os.Exit(1)
+	stdout, err := attachTerminal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if os.Stdout != stdout {
+		// lipgloss detected colors on the redirected stdout at startup
+		lipgloss.SetColorProfile(termenv.NewOutput(os.Stdout).EnvColorProfile())
 	}
 
-	// No arguments - start with empty file
-	startEditor("")
-}
+	m := NewModel(filename, config)
+	m.pipe = opts.Pipe
+	m.readOnly, m.readOnlyFlag = opts.ReadOnly, opts.ReadOnly
+	if filename != "" && !isWritable(filename) {
+		m.readOnly = true
+		m.setStatusMsg(filename+" is not writable; opened read-only", false)
+	}
+	m.split = opts.Split
+	if opts.Preview {
+		m.activeTab = TabPreview
+	}
+	if input != nil {
+		m.content = input
+		m.saved = false
+		m.recheck()
+		m.startWriting()
+		m.setStatusMsg(fmt.Sprintf("Read %d lines from stdin", len(input)), false)
+	}
+	if row, err := opts.StartLine(m.content); err != nil {
+		m.setStatusMsg(err.Error(), true)
+	} else {
+		m.cursor.row = row
+	}
 
-// startEditor initializes and runs the editor with the given filename
-func startEditor(filename string) {
-	m := NewModel(filename)
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithInput(os.Stdin), tea.WithOutput(os.Stdout))
 
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	fm, _ := final.(Model)
+	if fm.preview != nil {
+		fm.preview.Close()
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if opts.Pipe {
+		if fm.pipeOutput == nil {
+			os.Exit(1)
+		}
+		if err := writePipeOutput(stdout, fm.pipeOutput); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
@@ -3,17 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 // Configuration constants
 const (
-	DefaultWordWrap   = 80
 	MaxWordWrap       = 120
 	MinWordWrap       = 40
 	WordWrapMargin    = 10
@@ -66,6 +67,7 @@ type Mode int
 const (
 	ModeNormal Mode = iota
 	ModeInsert
+	ModeCommand
 )
 
 type Tab int
@@ -91,10 +93,40 @@ type Model struct {
 	statusMsg        string
 	statusMsgTimeout time.Time
 	cursorBlink      bool
-	codeBlocks       []CodeBlock
-	codeBlocksDirty  bool
+	doc              *docStructure // headings and code blocks, kept current by Update
 	config           Config
 	lastError        error
+	commandLine      string
+	preview          *previewServer
+	pipe             bool     // --pipe: writing sends the buffer to stdout
+	readOnly         bool     // the buffer may not be written
+	readOnlyFlag     bool     // -R: every file opens read-only
+	split            bool     // --split: editor and preview side by side
+	pipeOutput       []string // buffer as last written in pipe mode
+	pendingKey       string   // first key of a two-key command such as "gg"
+	outlineOpen      bool
+	outlineFocus     bool // keys move the outline selection
+	outlineSel       int  // selected heading while the outline has the focus
+	tasks            taskView
+	stats            statsView
+	locations        locationList // results of ":checklinks" and ":lint"
+	jumps            []jump       // where followed links were followed from, for Ctrl+O
+	previewLink      int          // link selected in the preview, counting from 1; 0 for none
+	lint             lintConfig
+	diagnostics      []lintDiagnostic // lint problems in the buffer, kept current by Update
+	counts           textCounts       // words and characters in the buffer, kept current by Update
+	goal             writingGoal
+	goalStart        int           // words in the buffer when it was opened, for session goals
+	recorded         int           // words in the buffer when the writing history last heard of it
+	zen              bool          // zen mode: the text alone, with typewriter scrolling
+	focus            string        // what zen mode leaves bright, or focusOff
+	spell            *spellChecker // nil while spell checking is off
+	misspellings     []misspelling // kept current by Update
+	spellPopup       spellPopup
+	completion       completion
+	snippets         []snippet
+	snippet          *snippetSession // the snippet whose fields Tab visits
+	frontMatterErr   error           // kept current by Update
 }
 
 type Position struct {
@@ -109,53 +141,15 @@ type Viewport struct {
 
 type BlinkMsg struct{}
 
-type CodeBlock struct {
-	start int
-	end   int
-	lang  string
-}
-
-func NewModel(filename string) Model {
+func NewModel(filename string, config Config) Model {
 	content := []string{""}
-	saved := false
+	saved := true
 	var statusMsg string
 	var lastError error
 
-	// Load configuration
-	config := LoadConfig()
-
 	// Load file if it exists
 	if filename != "" {
-		if info, err := os.Stat(filename); err == nil {
-			// Check file size
-			if info.Size() > MaxFileSize {
-				statusMsg = fmt.Sprintf("File too large (%d MB). Maximum size is %d MB",
-					info.Size()/(1024*1024), MaxFileSize/(1024*1024))
-				lastError = fmt.Errorf("file too large: %d bytes", info.Size())
-			} else if data, err := os.ReadFile(filename); err == nil {
-				// Check if file is binary
-				if isBinaryFile(data) {
-					statusMsg = "Cannot edit binary file: " + filename
-					lastError = fmt.Errorf("binary file detected")
-				} else {
-					content = strings.Split(string(data), "\n")
-					if len(content) > 0 && content[len(content)-1] == "" {
-						content = content[:len(content)-1]
-					}
-					saved = true
-				}
-			} else {
-				statusMsg = "Error reading file: " + err.Error()
-				lastError = err
-				saved = false
-			}
-		} else {
-			// File doesn't exist - this is okay for new files
-			statusMsg = "New file: " + filename
-			saved = false
-		}
-	} else {
-		saved = true
+		content, saved, statusMsg, lastError = loadFile(filename)
 	}
 
 	// Initialize glamour renderer with configuration (lazy initialization for better startup performance)
@@ -169,7 +163,7 @@ func NewModel(filename string) Model {
 	// This improves startup performance significantly
 	if wordWrap > MinWordWrap && wordWrap < MaxWordWrap*2 {
 		if r, err := glamour.NewTermRenderer(
-			glamour.WithAutoStyle(),
+			glamourStyleOption(config.Theme),
 			glamour.WithWordWrap(wordWrap),
 		); err == nil {
 			renderer = r
@@ -195,17 +189,107 @@ func NewModel(filename string) Model {
 		statusMsg:        statusMsg,
 		statusMsgTimeout: time.Now().Add(StatusMsgDuration),
 		cursorBlink:      true,
-		codeBlocksDirty:  true,
+		doc:              newDocStructure(),
 		config:           config,
 		lastError:        lastError,
 	}
 
-	// Initialize code blocks
-	m.rebuildCodeBlocks()
+	m.zen = config.Zen
+	if focus, err := parseFocus(config.ZenFocus); err == nil {
+		m.focus = focus
+	} else {
+		m.setStatusMsg("Config: zen_focus: "+err.Error(), true)
+	}
+
+	m.doc.update(m.content)
+	m.loadLint()
+	m.loadSpell()
+	m.loadSnippets()
+	m.checkFrontMatter()
+	m.recount()
+	m.startWriting()
 
 	return m
 }
 
+// loadFile reads filename into buffer lines. A file that doesn't exist yet
+// is an empty new buffer; statusMsg says so, or why the file can't be edited.
+func loadFile(filename string) (content []string, saved bool, statusMsg string, err error) {
+	content = []string{""}
+
+	info, statErr := os.Stat(filename)
+	if statErr != nil {
+		// File doesn't exist - this is okay for new files
+		return content, false, "New file: " + filename, nil
+	}
+
+	// Check file size
+	if info.Size() > MaxFileSize {
+		statusMsg = fmt.Sprintf("File too large (%d MB). Maximum size is %d MB",
+			info.Size()/(1024*1024), MaxFileSize/(1024*1024))
+		return content, false, statusMsg, fmt.Errorf("file too large: %d bytes", info.Size())
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return content, false, "Error reading file: " + err.Error(), err
+	}
+
+	// Check if file is binary
+	if isBinaryFile(data) {
+		return content, false, "Cannot edit binary file: " + filename, fmt.Errorf("binary file detected")
+	}
+
+	content = strings.Split(string(data), "\n")
+	if len(content) > 0 && content[len(content)-1] == "" {
+		content = content[:len(content)-1]
+	}
+	return content, true, "", nil
+}
+
+// openFile replaces the buffer with filename, refusing while the buffer has
+// unsaved changes. ok is false when the file was not opened.
+func (m Model) openFile(filename string) (Model, bool) {
+	if !m.saved {
+		m.setStatusMsg("No write since last change (:w first)", true)
+		return m, false
+	}
+	if m.pipe {
+		m.setStatusMsg("Cannot open another file in pipe mode", true)
+		return m, false
+	}
+
+	content, saved, statusMsg, err := loadFile(filename)
+	if err != nil {
+		m.lastError = err
+		m.setStatusMsg(statusMsg, true)
+		return m, false
+	}
+
+	m.filename = filename
+	m.content = content
+	m.saved = saved
+	m.cursor = Position{row: 0, col: 0}
+	m.viewport = Viewport{offsetRow: 0, offsetCol: 0}
+	m.previewOffset = 0
+	m.previewLink = 0
+	m.snippet = nil
+	m.doc = newDocStructure()
+	m.doc.update(m.content)
+	m.loadLint()
+	m.loadSpell()
+	m.loadSnippets()
+	m.checkFrontMatter()
+	m.recount()
+	m.startWriting()
+	m.ensureCursorBounds()
+	m.readOnly = m.readOnlyFlag || !isWritable(filename)
+	if statusMsg != "" {
+		m.setStatusMsg(statusMsg, false)
+	}
+	return m, true
+}
+
 // isBinaryFile checks if the file content appears to be binary
 func isBinaryFile(data []byte) bool {
 	if len(data) == 0 {
@@ -256,7 +340,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Only update glamour renderer if width changed significantly (performance optimization)
 		if m.width > 20 && m.renderer != nil && abs(m.width-oldWidth) > 10 {
-			wordWrap := m.width - WordWrapMargin
+			wordWrap := m.previewWidth() - WordWrapMargin
 			if wordWrap > MaxWordWrap {
 				wordWrap = MaxWordWrap
 			} else if wordWrap < MinWordWrap {
@@ -264,7 +348,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			if renderer, err := glamour.NewTermRenderer(
-				glamour.WithAutoStyle(),
+				glamourStyleOption(m.config.Theme),
 				glamour.WithWordWrap(wordWrap),
 			); err == nil {
 				m.renderer = renderer
@@ -289,7 +373,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
-		return m.handleKeyPress(msg)
+		if m.snippet != nil {
+			m.snippet.mark(m.content, m.cursor)
+		}
+		model, cmd := m.handleKeyPress(msg)
+		updated, ok := model.(Model)
+		if !ok {
+			return model, cmd
+		}
+		updated.followSnippet()
+		updated.recheck()
+		if updated.preview != nil {
+			updated.preview.Update(updated.content, updated.filename, updated.cursor.row)
+		}
+		return updated, cmd
+
+	case linkCheckMsg:
+		return m.showLinkProblems(msg), nil
+
+	case writeFilterMsg:
+		m.setStatusMsg(filterStatus(msg.cmdline, msg.output, msg.err), msg.err != nil)
+		if msg.err == nil && filterWritesFile(msg.cmdline) {
+			m.saved = true
+		}
+		return m, nil
 
 	case BlinkMsg:
 		m.cursorBlink = !m.cursorBlink
@@ -325,6 +432,10 @@ func (m Model) View() string {
 			Render("Terminal too small")
 	}
 
+	if m.zenActive() {
+		return m.renderZen()
+	}
+
 	// Create UI elements
 	tabBar := m.renderTabBar()
 	statusBar := m.renderStatusBar()
@@ -339,9 +450,18 @@ func (m Model) View() string {
 
 	// Create content based on active tab
 	var content string
-	if m.activeTab == TabEditor {
-		content = m.renderEditor(contentHeight)
-	} else {
+	switch {
+	case m.tasks.open:
+		content = m.renderTaskView(contentHeight)
+	case m.locations.open:
+		content = m.renderLocationList(contentHeight)
+	case m.stats.open:
+		content = m.renderStatsView(contentHeight)
+	case m.split:
+		content = m.renderSplit(contentHeight)
+	case m.activeTab == TabEditor:
+		content = m.renderEditorPane(contentHeight)
+	default:
 		content = m.renderPreview(contentHeight)
 	}
 
@@ -355,12 +475,60 @@ func (m Model) View() string {
 	)
 }
 
+// leftPaneWidth is the width of the editor side, outline included: the whole
+// window, or the left half in split view
+func (m Model) leftPaneWidth() int {
+	if m.split {
+		return m.width / 2
+	}
+	return m.width
+}
+
+// editorWidth is the width of the editor text beside the outline
+func (m Model) editorWidth() int {
+	if m.outlineOpen {
+		return m.leftPaneWidth() - m.outlineWidth() - 1 // divider
+	}
+	return m.leftPaneWidth()
+}
+
+// previewWidth is the width of the preview pane
+func (m Model) previewWidth() int {
+	if m.split {
+		return m.width - m.leftPaneWidth() - 1 // divider
+	}
+	return m.width
+}
+
+// renderSplit shows the editor and the preview side by side. Tab still moves
+// the keyboard focus between them.
+func (m Model) renderSplit(height int) string {
+	editorWidth, previewWidth := m.leftPaneWidth(), m.previewWidth()
+	editor := strings.Split(m.renderEditorPane(height), "\n")
+	preview := strings.Split(m.renderPreview(height), "\n")
+	divider := footerStyle.Render("│")
+
+	lines := make([]string, height)
+	for i := range height {
+		var left, right string
+		if i < len(editor) {
+			left = ansi.Truncate(editor[i], editorWidth, "")
+		}
+		if i < len(preview) {
+			right = ansi.Truncate(preview[i], previewWidth, "")
+		}
+		lines[i] = left + strings.Repeat(" ", max(0, editorWidth-lipgloss.Width(left))) + divider + right
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (m Model) renderEditor(height int) string {
 	lines := make([]string, height)
 
 	// Note: Since we're using a value receiver, we can't modify m.highlighter here
 	// The proper initialization should happen in Update or a method with pointer receiver
 	// We'll just use the highlighter if it's available
+	frontMatter := m.highlighter.HighlightFrontMatter(m.content, m.doc)
 
 	for i := range height {
 		lineNum := m.viewport.offsetRow + i
@@ -370,6 +538,7 @@ func (m Model) renderEditor(height int) string {
 		}
 
 		originalLine := m.content[lineNum]
+		gutter := m.gutter(lineNum)
 
 		// Handle horizontal scrolling on original line
 		visibleLine := originalLine
@@ -386,17 +555,41 @@ func (m Model) renderEditor(height int) string {
 		displayLine := visibleLine
 
 		// Add cursor if this is the cursor line and cursor is visible
+		cursorPos := -1
 		if lineNum == m.cursor.row && m.cursorBlink {
-			cursorPos := m.cursor.col - m.viewport.offsetCol
-			if cursorPos >= 0 && cursorPos <= len(visibleLine) {
-				// Insert cursor without breaking syntax highlighting
-				displayLine = m.insertCursor(displayLine, visibleLine, cursorPos)
+			if pos := m.cursor.col - m.viewport.offsetCol; pos >= 0 && pos <= len(visibleLine) {
+				cursorPos = pos
+			}
+		}
+
+		// Underline lint problems, which draws the cursor as it goes
+		spans, misspelled := diagnosticSpans(m.diagnostics, lineNum), misspellingSpans(m.misspellings, lineNum)
+		if len(spans) > 0 || len(misspelled) > 0 {
+			for _, list := range [][][2]int{spans, misspelled} {
+				for j := range list {
+					list[j][0] -= m.viewport.offsetCol
+					list[j][1] -= m.viewport.offsetCol
+				}
 			}
+			displayLine = underlineSpans(visibleLine, spans, misspelled, cursorPos)
+		} else if cursorPos >= 0 {
+			// Insert cursor without breaking syntax highlighting
+			displayLine = m.insertCursor(displayLine, visibleLine, cursorPos)
+		} else if lineNum < len(frontMatter) && lineNum != m.cursor.row {
+			// Front matter is highlighted as YAML or TOML, except on the
+			// cursor's line, which stays plain to edit
+			displayLine = ansi.TruncateLeft(frontMatter[lineNum], ansi.StringWidth(originalLine[:len(originalLine)-len(visibleLine)]), "")
 		}
 
-		lines[i] = displayLine
+		lines[i] = gutter + displayLine
 	}
 
+	if m.spellPopup.open {
+		m.drawSpellPopup(lines)
+	}
+	if m.completion.open && m.mode == ModeInsert {
+		m.drawCompletion(lines)
+	}
 	return strings.Join(lines, "\n")
 }
 
@@ -419,7 +612,7 @@ func (m Model) insertCursor(displayLine, originalLine string, cursorPos int) str
 func (m Model) renderPreview(height int) string {
 	// Lazy rendering: Only render when we're actually on the preview tab
 	// This prevents expensive markdown rendering when on editor tab
-	if m.activeTab != TabPreview {
+	if m.activeTab != TabPreview && !m.split {
 		return "Preview not rendered (not active tab)"
 	}
 
@@ -428,7 +621,7 @@ func (m Model) renderPreview(height int) string {
 		return "Preview not available"
 	}
 
-	markdown := strings.Join(m.content, "\n")
+	markdown := previewMarkdown(m.content, m.config.FrontMatterCard)
 	if strings.TrimSpace(markdown) == "" {
 		return "No content to preview"
 	}
@@ -444,6 +637,13 @@ func (m Model) renderPreview(height int) string {
 	// Apply scrolling by splitting into lines and applying offset
 	lines := strings.Split(rendered, "\n")
 
+	// Highlight the line of the link selected with n/N
+	if m.previewLink > 0 && m.activeTab == TabPreview {
+		if line := renderedLinkLine(lines, m.previewLinks(), m.previewLink-1); line >= 0 {
+			lines[line] = outlineSelectedStyle.Render(ansi.Strip(lines[line]))
+		}
+	}
+
 	// Calculate safe offset bounds
 	offset := m.previewOffset
 	if offset < 0 {
@@ -472,6 +672,11 @@ func (m Model) renderPreview(height int) string {
 }
 
 func (m Model) renderStatusBar() string {
+	// The command line replaces the status bar while it is being edited
+	if m.mode == ModeCommand {
+		return statusBarStyle.Width(m.width).Render(":" + m.commandLine + "█")
+	}
+
 	// Show status message if active and not expired
 	if m.statusMsg != "" && time.Now().Before(m.statusMsgTimeout) {
 		style := statusBarStyle
@@ -505,6 +710,9 @@ func (m Model) renderStatusBar() string {
 			fileStatus += " [modified]"
 		}
 	}
+	if m.readOnly {
+		fileStatus += " [RO]"
+	}
 
 	// Position
 	position := fmt.Sprintf("(%d,%d)", m.cursor.row+1, m.cursor.col+1)
@@ -521,11 +729,46 @@ func (m Model) renderStatusBar() string {
 		statusBarStyle.Render(" "+fileStatus+" "),
 	)
 
+	// Live preview address while `:serve` is running
+	serving := ""
+	if m.preview != nil {
+		serving = m.preview.URL() + " "
+	}
+
+	// Task progress, for buffers with task lists
+	progress := ""
+	if done, total := countTasks(m.doc.tasks); total > 0 {
+		progress = fmt.Sprintf("☑ %d/%d ", done, total)
+	}
+
+	// Lint problems in the buffer
+	problems := ""
+	if len(m.diagnostics) > 0 {
+		problems = fmt.Sprintf("%s %d ", lintSign, len(m.diagnostics))
+	}
+
+	// Words, characters and reading time
+	words := fmt.Sprintf("%d words %d chars %s ", m.counts.words, m.counts.chars, readingTime(m.counts.words))
+	if m.goal.target > 0 {
+		words += m.goal.bar(m.counts.words, m.goalStart) + " "
+	}
+
 	rightSection := lipgloss.JoinHorizontal(lipgloss.Right,
-		statusBarStyle.Render(position),
+		statusBarStyle.Render(serving+progress+problems+words+position),
 		errorIndicator,
 	)
 
+	// A front matter problem, or else the lint problem on the cursor's
+	// line, in whatever room is left
+	room := m.width - lipgloss.Width(leftSection) - lipgloss.Width(rightSection) - 4
+	if m.frontMatterErr != nil && room > 10 {
+		message := ansi.Truncate("Front matter "+m.frontMatterErr.Error(), room, "…")
+		leftSection = lipgloss.JoinHorizontal(lipgloss.Left, leftSection, errorStyle.Render(message))
+	} else if d, ok := m.cursorDiagnostic(); ok && room > 10 {
+		message := ansi.Truncate(d.Rule+" "+d.Message, room, "…")
+		leftSection = lipgloss.JoinHorizontal(lipgloss.Left, leftSection, lintSignStyle.Render(message))
+	}
+
 	// Calculate spacing
 	usedWidth := lipgloss.Width(leftSection) + lipgloss.Width(rightSection)
 	spacerWidth := max(0, m.width-usedWidth)
@@ -571,7 +814,57 @@ func (m Model) renderTabBar() string {
 func (m Model) renderFooter() string {
 	var commands []string
 
-	if m.activeTab == TabEditor {
+	if m.mode == ModeCommand {
+		commands = []string{
+			keyStyle.Render("Enter") + " Run",
+			keyStyle.Render("Esc") + " Cancel",
+			keyStyle.Render(":w :q :wq") + " Write/Quit",
+			keyStyle.Render(":export html") + " Export",
+		}
+	} else if m.locations.open {
+		commands = []string{
+			keyStyle.Render("j/k") + " Select",
+			keyStyle.Render("Enter") + " Jump",
+			keyStyle.Render("q") + " Close",
+			keyStyle.Render("Ctrl+Q") + " Quit",
+		}
+	} else if m.stats.open {
+		commands = []string{
+			keyStyle.Render("j/k") + " Select",
+			keyStyle.Render("Enter") + " Jump",
+			keyStyle.Render("q") + " Close",
+			keyStyle.Render("Ctrl+Q") + " Quit",
+		}
+	} else if m.tasks.open {
+		commands = []string{
+			keyStyle.Render("j/k") + " Select",
+			keyStyle.Render("Enter") + " Jump",
+			keyStyle.Render("x") + " Toggle",
+			keyStyle.Render("q") + " Close",
+			keyStyle.Render("Ctrl+Q") + " Quit",
+		}
+	} else if m.completion.open && m.mode == ModeInsert && m.activeTab == TabEditor {
+		commands = []string{
+			keyStyle.Render("Ctrl+N/P") + " Select",
+			keyStyle.Render("Enter") + " Complete",
+			keyStyle.Render("Ctrl+E") + " Cancel",
+		}
+	} else if m.spellPopup.open {
+		commands = []string{
+			keyStyle.Render("1-9") + " Choose",
+			keyStyle.Render("j/k") + " Select",
+			keyStyle.Render("Enter") + " Replace",
+			keyStyle.Render("Esc") + " Cancel",
+		}
+	} else if m.activeTab == TabEditor && m.outlineFocus {
+		commands = []string{
+			keyStyle.Render("j/k") + " Select",
+			keyStyle.Render("Enter") + " Jump",
+			keyStyle.Render("Esc") + " Editor",
+			keyStyle.Render("q") + " Close",
+			keyStyle.Render("Ctrl+Q") + " Quit",
+		}
+	} else if m.activeTab == TabEditor {
 		if m.mode == ModeNormal {
 			// Normal mode commands
 			commands = []string{
@@ -580,6 +873,19 @@ func (m Model) renderFooter() string {
 				keyStyle.Render("Ctrl+S") + " Save",
 				keyStyle.Render("o") + " New Line",
 				keyStyle.Render("dd") + " Delete Line",
+				keyStyle.Render("gO") + " Outline",
+				keyStyle.Render("gc") + " Check Task",
+				keyStyle.Render(":") + " Command",
+				keyStyle.Render("Ctrl+Q") + " Quit",
+			}
+			if len(m.diagnostics) > 0 {
+				commands = slices.Insert(commands, len(commands)-2, keyStyle.Render("]d/[d")+" Problems")
+			}
+		} else if m.snippet != nil {
+			commands = []string{
+				keyStyle.Render("Tab") + " Next Field",
+				keyStyle.Render("Shift+Tab") + " Previous Field",
+				keyStyle.Render("Esc") + " Normal",
 				keyStyle.Render("Ctrl+Q") + " Quit",
 			}
 		} else {
@@ -599,6 +905,9 @@ func (m Model) renderFooter() string {
 			keyStyle.Render("Tab") + " Editor",
 			keyStyle.Render("j/k") + " Scroll",
 			keyStyle.Render("g/G") + " Top/Bottom",
+			keyStyle.Render("n/N") + " Links",
+			keyStyle.Render("Enter") + " Follow",
+			keyStyle.Render(":") + " Command",
 			keyStyle.Render("Ctrl+S") + " Save",
 			keyStyle.Render("Ctrl+Q") + " Quit",
 		}
@@ -615,51 +924,11 @@ func (m Model) renderFooter() string {
 		Render(commandText)
 }
 
-// rebuildCodeBlocks analyzes the content and identifies code blocks
-func (m *Model) rebuildCodeBlocks() {
-	// Only rebuild if dirty
-	if !m.codeBlocksDirty {
-		return
-	}
-
-	m.codeBlocks = []CodeBlock{}
-	inCodeBlock := false
-	var currentBlock CodeBlock
-
-	for i, line := range m.content {
-		if lang, found := strings.CutPrefix(line, "```"); found {
-			if !inCodeBlock {
-				// Start of code block
-				inCodeBlock = true
-				currentBlock = CodeBlock{
-					start: i,
-					lang:  strings.TrimSpace(lang),
-				}
-			} else {
-				// End of code block
-				inCodeBlock = false
-				currentBlock.end = i
-				m.codeBlocks = append(m.codeBlocks, currentBlock)
-			}
-		}
-	}
-
-	// Handle unclosed code block
-	if inCodeBlock {
-		currentBlock.end = len(m.content) - 1
-		m.codeBlocks = append(m.codeBlocks, currentBlock)
-	}
-
-	m.codeBlocksDirty = false
-}
-
 // isInCodeBlock checks if a line is inside a code block
 func (m *Model) isInCodeBlock(lineNum int) (bool, string) {
-	for _, block := range m.codeBlocks {
-		// Include lines within the code block content (not the fences)
-		if lineNum > block.start && lineNum < block.end {
-			return true, block.lang
-		}
+	// Include lines within the code block content (not the fences)
+	if block, ok := m.doc.codeBlockAt(lineNum); ok && lineNum > block.start && lineNum < block.end {
+		return true, block.lang
 	}
 	return false, ""
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Options holds what the command line asks the editor to do
+type Options struct {
+	Filename string
+	Line     int    // +N: 1-based line to start on, -1 for the last line
+	Pattern  string // +/PATTERN: start on the first matching line
+
+	ReadOnly bool
+	Preview  bool // start on the preview tab
+	Split    bool // show the editor and preview side by side
+	Pipe     bool
+
+	ConfigPath string
+	NoConfig   bool
+	Theme      string
+
+	Help         bool
+	Version      bool
+	VersionShort bool
+}
+
+// cliFlag describes one editor command-line option. Both entry points parse
+// with this table and PrintHelp lists it, so the two never disagree.
+type cliFlag struct {
+	names []string // spellings such as "-R" and "--readonly"; names starting with "+" match as a prefix
+	arg   string   // placeholder for the value the flag takes, if any
+	usage string
+	apply func(o *Options, value string) error
+}
+
+var cliFlags = []cliFlag{
+	{names: []string{"+"}, arg: "N", usage: "Start on line N (a bare + starts on the last line)", apply: func(o *Options, v string) error {
+		if v == "" {
+			o.Line = -1
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid line number: +%s", v)
+		}
+		o.Line = n
+		return nil
+	}},
+	{names: []string{"+/"}, arg: "PATTERN", usage: "Start on the first line matching PATTERN", apply: func(o *Options, v string) error {
+		if _, err := regexp.Compile(v); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", v, err)
+		}
+		o.Pattern = v
+		return nil
+	}},
+	{names: []string{"-R", "--readonly"}, usage: "Open the file read-only", apply: func(o *Options, _ string) error {
+		o.ReadOnly = true
+		return nil
+	}},
+	{names: []string{"--preview"}, usage: "Start on the preview tab", apply: func(o *Options, _ string) error {
+		o.Preview = true
+		return nil
+	}},
+	{names: []string{"--split"}, usage: "Show the editor and preview side by side", apply: func(o *Options, _ string) error {
+		o.Split = true
+		return nil
+	}},
+	{names: []string{"--pipe"}, usage: "Write the buffer to stdout on :wq", apply: func(o *Options, _ string) error {
+		o.Pipe = true
+		return nil
+	}},
+	{names: []string{"--config"}, arg: "PATH", usage: "Read settings from PATH", apply: func(o *Options, v string) error {
+		o.ConfigPath = v
+		return nil
+	}},
+	{names: []string{"--no-config"}, usage: "Ignore the config file and use defaults", apply: func(o *Options, _ string) error {
+		o.NoConfig = true
+		return nil
+	}},
+	{names: []string{"--theme"}, arg: "NAME", usage: "Preview theme (glamour style or JSON file)", apply: func(o *Options, v string) error {
+		o.Theme = v
+		return nil
+	}},
+	{names: []string{"-v", "--version"}, usage: "Show version information", apply: func(o *Options, _ string) error {
+		o.Version = true
+		return nil
+	}},
+	{names: []string{"--version-short"}, usage: "Print just the version number", apply: func(o *Options, _ string) error {
+		o.VersionShort = true
+		return nil
+	}},
+	{names: []string{"-h", "--help"}, usage: "Show this help message", apply: func(o *Options, _ string) error {
+		o.Help = true
+		return nil
+	}},
+}
+
+// isPrefixFlag reports whether name matches arguments by prefix ("+N", "+/PATTERN")
+func isPrefixFlag(name string) bool {
+	return strings.HasPrefix(name, "+")
+}
+
+// parseArgs parses the editor's arguments. Flags and the file may come in
+// any order; "--" ends the flags, so `hani -- -notes.md` opens "-notes.md".
+// Values are given as `--theme dark` or `--theme=dark`.
+func parseArgs(args []string) (Options, error) {
+	var opts Options
+	var files []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		if arg == "-" || !(strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+")) {
+			files = append(files, arg)
+			continue
+		}
+
+		flag, value, needsValue, err := matchFlag(arg)
+		if err != nil {
+			return opts, err
+		}
+		if needsValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("flag needs an argument: %s %s", arg, flag.arg)
+			}
+			i++
+			value = args[i]
+		}
+		if err := flag.apply(&opts, value); err != nil {
+			return opts, err
+		}
+	}
+
+	if len(files) > 1 {
+		return opts, fmt.Errorf("too many files: %s", strings.Join(files, " "))
+	}
+	if len(files) == 1 {
+		opts.Filename = files[0]
+	}
+	return opts, nil
+}
+
+// matchFlag finds the table entry for arg and any value attached to it.
+// needsValue reports that the value is the next argument.
+func matchFlag(arg string) (flag cliFlag, value string, needsValue bool, err error) {
+	// "+" flags take the rest of the argument; the longest prefix wins
+	if strings.HasPrefix(arg, "+") {
+		best := ""
+		for _, f := range cliFlags {
+			if n := f.names[0]; isPrefixFlag(n) && strings.HasPrefix(arg, n) && len(n) > len(best) {
+				best, flag = n, f
+			}
+		}
+		return flag, arg[len(best):], false, nil
+	}
+
+	name, value, hasValue := strings.Cut(arg, "=")
+	for _, flag := range cliFlags {
+		for _, n := range flag.names {
+			if n != name {
+				continue
+			}
+			if hasValue && flag.arg == "" {
+				return flag, "", false, fmt.Errorf("flag does not take a value: %s", name)
+			}
+			return flag, value, flag.arg != "" && !hasValue, nil
+		}
+	}
+	return cliFlag{}, "", false, fmt.Errorf("unknown flag: %s", name)
+}
+
+// parseCommandLine parses the editor's arguments and handles --help,
+// --version and usage errors itself. When exit is true the caller should
+// exit with code instead of starting the editor.
+func parseCommandLine(args []string) (opts Options, code int, exit bool) {
+	// `hani view FILE` (or hani installed as "view") opens read-only
+	view := isViewAlias()
+	if len(args) > 0 && args[0] == "view" {
+		view, args = true, args[1:]
+	}
+
+	opts, err := parseArgs(args)
+	opts.ReadOnly = opts.ReadOnly || view
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hani: %v\nRun 'hani --help' for usage.\n", err)
+		return opts, 2, true
+	}
+
+	switch {
+	case opts.Help:
+		PrintHelp()
+		return opts, 0, true
+	case opts.Version:
+		PrintVersion()
+		return opts, 0, true
+	case opts.VersionShort:
+		PrintVersionShort()
+		return opts, 0, true
+	}
+	return opts, 0, false
+}
+
+// Config returns the configuration the options select: the user's config
+// file, the one named by --config, or the defaults with --no-config, with
+// --theme applied on top
+func (o Options) Config() (Config, error) {
+	var config Config
+	switch {
+	case o.NoConfig:
+		config = DefaultConfig()
+	case o.ConfigPath != "":
+		var err error
+		if config, err = LoadConfigFile(o.ConfigPath); err != nil {
+			return config, err
+		}
+	default:
+		config = LoadConfig()
+	}
+
+	if o.Theme != "" {
+		config.Theme = o.Theme
+	}
+	return config, nil
+}
+
+// StartLine returns the 0-based line the cursor should start on in content
+func (o Options) StartLine(content []string) (int, error) {
+	if o.Pattern != "" {
+		re := regexp.MustCompile(o.Pattern) // validated by parseArgs
+		for i, line := range content {
+			if re.MatchString(line) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("pattern not found: %s", o.Pattern)
+	}
+
+	switch {
+	case o.Line == 0:
+		return 0, nil
+	case o.Line < 0 || o.Line > len(content):
+		return max(0, len(content)-1), nil
+	}
+	return o.Line - 1, nil
+}
+
+// writeFlagHelp writes the OPTIONS section of the help text
+func writeFlagHelp(b *strings.Builder) {
+	b.WriteString("OPTIONS:\n")
+	for _, flag := range cliFlags {
+		var spelling string
+		if isPrefixFlag(flag.names[0]) {
+			spelling = flag.names[0] + flag.arg
+		} else {
+			spelling = strings.Join(flag.names, ", ")
+			if flag.arg != "" {
+				spelling += " " + flag.arg
+			}
+		}
+		fmt.Fprintf(b, "  %-19s %s\n", spelling, flag.usage)
+	}
+	fmt.Fprintf(b, "  %-19s %s\n", "--", "End of options; the next argument is the file")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const maxOutlineWidth = 32
+
+// outlineHelp is PrintHelp's line for the outline, which the DIY editor
+// doesn't have
+const outlineHelp = "  gO, :outline        Toggle the heading outline (Enter jumps to a heading)\n"
+
+var (
+	outlineCurrentStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#7D56F4")).
+				Bold(true)
+
+	outlineSelectedStyle = lipgloss.NewStyle().
+				Reverse(true)
+)
+
+// toggleOutline opens the outline with the keyboard focus on the current
+// section, or closes it
+func (m Model) toggleOutline() (tea.Model, tea.Cmd) {
+	m.outlineOpen = !m.outlineOpen
+	m.outlineFocus = m.outlineOpen
+	m.outlineSel = max(0, m.doc.sectionAt(m.cursor.row))
+	m.adjustViewport()
+	return m, nil
+}
+
+// handleOutlineMode moves the selection while the outline has the focus
+func (m Model) handleOutlineMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	headings := m.doc.headings
+	m.outlineSel = min(m.outlineSel, max(0, len(headings)-1))
+
+	switch msg.String() {
+	case "j", "down":
+		if m.outlineSel < len(headings)-1 {
+			m.outlineSel++
+		}
+
+	case "k", "up":
+		if m.outlineSel > 0 {
+			m.outlineSel--
+		}
+
+	case "g", "home":
+		m.outlineSel = 0
+
+	case "G", "end":
+		m.outlineSel = max(0, len(headings)-1)
+
+	case "enter":
+		if len(headings) > 0 {
+			m.cursor = Position{row: headings[m.outlineSel].Line, col: 0}
+			m.adjustViewport()
+		}
+		m.outlineFocus = false
+
+	case "esc":
+		m.outlineFocus = false
+
+	case "q":
+		return m.toggleOutline()
+
+	case ":":
+		m.outlineFocus = false
+		m.mode = ModeCommand
+		m.commandLine = ""
+	}
+	return m, nil
+}
+
+// outlineWidth is the width of the outline panel, 0 while it is closed
+func (m Model) outlineWidth() int {
+	if !m.outlineOpen {
+		return 0
+	}
+	return min(maxOutlineWidth, m.leftPaneWidth()/3)
+}
+
+// renderOutline lists the headings as a tree indented by level. The section
+// holding the cursor is highlighted, and the list scrolls to keep the
+// selection (or the current section) in view.
+func (m Model) renderOutline(height int) []string {
+	width := m.outlineWidth()
+	lines := make([]string, height)
+	headings := m.doc.headings
+
+	if len(headings) == 0 {
+		lines[0] = separatorStyle.Render(ansi.Truncate("No headings", width, ""))
+		return lines
+	}
+
+	current := m.doc.sectionAt(m.cursor.row)
+	target := max(0, current)
+	if m.outlineFocus {
+		target = min(m.outlineSel, len(headings)-1)
+	}
+	offset := max(0, min(target-height/2, len(headings)-height))
+
+	top := 6
+	for _, h := range headings {
+		top = min(top, h.Level)
+	}
+
+	for i := range height {
+		n := offset + i
+		if n >= len(headings) {
+			break
+		}
+		h := headings[n]
+		entry := strings.Repeat("  ", h.Level-top) + h.Title()
+		entry = ansi.Truncate(entry, width, "…")
+		entry += strings.Repeat(" ", max(0, width-lipgloss.Width(entry)))
+
+		switch {
+		case m.outlineFocus && n == target:
+			lines[i] = outlineSelectedStyle.Render(entry)
+		case n == current:
+			lines[i] = outlineCurrentStyle.Render(entry)
+		default:
+			lines[i] = entry
+		}
+	}
+	return lines
+}
+
+// renderEditorPane is the editor with the outline to its left when it is open
+func (m Model) renderEditorPane(height int) string {
+	if !m.outlineOpen {
+		return m.renderEditor(height)
+	}
+
+	width, editorWidth := m.outlineWidth(), m.editorWidth()
+	outline := m.renderOutline(height)
+	editor := strings.Split(m.renderEditor(height), "\n")
+	divider := footerStyle.Render("│")
+
+	lines := make([]string, height)
+	for i := range height {
+		left := outline[i] + strings.Repeat(" ", max(0, width-lipgloss.Width(outline[i])))
+		var right string
+		if i < len(editor) {
+			right = ansi.Truncate(editor[i], editorWidth, "")
+		}
+		lines[i] = left + divider + right
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultAutoPairs are the characters typing pairs by default
+const DefaultAutoPairs = "[(`*_"
+
+// pairClose returns the character that closes ch when it is typed as an
+// opening one
+func pairClose(ch byte) (byte, bool) {
+	switch ch {
+	case '(':
+		return ')', true
+	case '[':
+		return ']', true
+	case '{':
+		return '}', true
+	case '<':
+		return '>', true
+	case '`', '*', '_', '~', '"', '\'':
+		return ch, true
+	}
+	return 0, false
+}
+
+// pairOpen returns the opening character that ch closes, for the bracket
+// pairs
+func pairOpen(ch byte) (byte, bool) {
+	switch ch {
+	case ')':
+		return '(', true
+	case ']':
+		return '[', true
+	case '}':
+		return '{', true
+	case '>':
+		return '<', true
+	}
+	return 0, false
+}
+
+// inCodeSpan reports whether byte col of line is inside a code span: there
+// is an odd number of backticks before it, so a span being typed counts
+// before its closing backtick is
+func inCodeSpan(line string, col int) bool {
+	return strings.Count(line[:min(col, len(line))], "`")%2 == 1
+}
+
+// autoPair types ch at byte col of line, pairing it with its closing
+// character or stepping over a closing character that is already there.
+// pairs are the opening characters that pair. Nothing pairs in code
+// (inCode for a code block; code spans are found here), where only the
+// closing backtick of a span is stepped over. Emphasis markers, quotes and
+// backticks only pair at the start of a word, and brackets before a space
+// or punctuation, so typing in the middle of words is left alone. ok is
+// false when ch should be typed as usual.
+func autoPair(line string, col int, ch byte, pairs string, inCode bool) (string, int, bool) {
+	if pairs == "" || inCode {
+		return line, col, false
+	}
+	var prev, next, beforePrev byte
+	if col > 0 {
+		prev = line[col-1]
+	}
+	if col > 1 {
+		beforePrev = line[col-2]
+	}
+	if col < len(line) {
+		next = line[col]
+	}
+	if inCodeSpan(line, col) {
+		if ch == '`' && next == '`' && strings.IndexByte(pairs, '`') >= 0 {
+			return line, col + 1, true
+		}
+		return line, col, false
+	}
+
+	// Step over the closing character. "*" typed between "*" and "*" opens
+	// bold instead, making "**|**".
+	if next == ch && pairsCloser(ch, pairs) {
+		symmetric := ch == '*' || ch == '_' || ch == '~'
+		if !(symmetric && prev == ch && beforePrev != ch && !isWordByte(beforePrev)) {
+			return line, col + 1, true
+		}
+	}
+
+	if strings.IndexByte(pairs, ch) < 0 {
+		return line, col, false
+	}
+	closing, ok := pairClose(ch)
+	if !ok {
+		return line, col, false
+	}
+	if ch == closing {
+		// Emphasis, code and quotes open only at the start of a word, and
+		// not where a line starts a list item, rule or code fence
+		if isWordByte(prev) || prev == '\\' || (next != 0 && next != ch && !isSpaceByte(next) && !isClosingPunct(next)) {
+			return line, col, false
+		}
+		if strings.TrimLeft(line[:col], " \t"+string(ch)) == "" && ch != '"' && ch != '\'' {
+			return line, col, false
+		}
+	} else if next != 0 && !isSpaceByte(next) && !isClosingPunct(next) {
+		return line, col, false
+	}
+	return line[:col] + string(ch) + string(closing) + line[col:], col + 1, true
+}
+
+// pairsCloser reports whether ch closes one of the pairs
+func pairsCloser(ch byte, pairs string) bool {
+	if open, ok := pairOpen(ch); ok {
+		return strings.IndexByte(pairs, open) >= 0
+	}
+	return strings.IndexByte(pairs, ch) >= 0
+}
+
+// pairBackspace deletes an empty pair around byte col of line, "(|)" or
+// "*|*", with one backspace. ok is false when there is none.
+func pairBackspace(line string, col int, pairs string) (string, int, bool) {
+	if col == 0 || col >= len(line) || strings.IndexByte(pairs, line[col-1]) < 0 {
+		return line, col, false
+	}
+	if closing, ok := pairClose(line[col-1]); !ok || line[col] != closing {
+		return line, col, false
+	}
+	return line[:col-1] + line[col+1:], col - 1, true
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// isClosingPunct reports whether b may follow a pair that is being opened
+func isClosingPunct(b byte) bool {
+	return strings.IndexByte(")]}>.,;:!?'\"", b) >= 0
+}
+
+// surroundPair returns the delimiters a surround character stands for:
+// itself for "*", "_", "`", quotes; "~" is "~~" and "b" is "**"; either
+// bracket for its pair; "l" for a link, "[...]()"
+func surroundPair(ch string) (open, close string, ok bool) {
+	switch ch {
+	case "*", "_", "`", "\"", "'":
+		return ch, ch, true
+	case "~":
+		return "~~", "~~", true
+	case "b":
+		return "**", "**", true
+	case "(", ")":
+		return "(", ")", true
+	case "[", "]":
+		return "[", "]", true
+	case "{", "}":
+		return "{", "}", true
+	case "<", ">":
+		return "<", ">", true
+	case "l":
+		return "[", "]()", true
+	}
+	return "", "", false
+}
+
+// surroundMotions are the motions ys takes, longest first where one starts
+// another
+var surroundMotions = []string{"iw", "aw", "iW", "aW", "w", "W", "e", "E", "$", "0", "s"}
+
+// parseSurround splits the keys typed after ys, cs or ds into the motion
+// and the surround characters. pending is true while more keys are needed,
+// ok is false for keys that make no command.
+func parseSurround(op, keys string) (motion string, chars []string, pending, ok bool) {
+	switch op {
+	case "ys":
+		if keys == "" || keys == "i" || keys == "a" {
+			return "", nil, true, true
+		}
+		for _, m := range surroundMotions {
+			if rest, found := strings.CutPrefix(keys, m); found {
+				if rest == "" {
+					return m, nil, true, true
+				}
+				return m, []string{rest}, false, true
+			}
+		}
+		return "", nil, false, false
+	case "ds":
+		if keys == "" {
+			return "", nil, true, true
+		}
+		return "", []string{keys}, false, true
+	case "cs":
+		if keys == "" {
+			return "", nil, true, true
+		}
+		first, size := utf8.DecodeRuneInString(keys)
+		if len(keys) == size {
+			return "", nil, true, true
+		}
+		return "", []string{string(first), keys[size:]}, false, true
+	}
+	return "", nil, false, false
+}
+
+// isWordRune reports whether r is part of a word for iw and w
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// surroundSpan returns the bytes of line a ys motion covers from byte col:
+// the word (iw, aw) or the run of non-blanks (iW, aW) under the cursor, to
+// the end of the word (w, e, W, E), to the end or start of the line ($, 0),
+// or the whole line without its indentation (s). The span never includes
+// the blanks around it.
+func surroundSpan(line string, col int, motion string) (start, end int, ok bool) {
+	if col >= len(line) {
+		// Past the end, as after $ or moving down to a shorter line
+		col = max(0, len(line)-1)
+	}
+	inWord := func(i int, big bool) bool {
+		r, _ := utf8.DecodeRuneInString(line[i:])
+		if big {
+			return !unicode.IsSpace(r)
+		}
+		return isWordRune(r)
+	}
+	wordBounds := func(big bool) (int, int, bool) {
+		if col >= len(line) || !inWord(col, big) {
+			return 0, 0, false
+		}
+		start, end := col, col
+		for start > 0 {
+			_, size := utf8.DecodeLastRuneInString(line[:start])
+			if !inWord(start-size, big) {
+				break
+			}
+			start -= size
+		}
+		for end < len(line) && inWord(end, big) {
+			_, size := utf8.DecodeRuneInString(line[end:])
+			end += size
+		}
+		return start, end, true
+	}
+
+	switch motion {
+	case "iw", "aw":
+		return wordBounds(false)
+	case "iW", "aW":
+		return wordBounds(true)
+	case "w", "e", "W", "E":
+		_, end, ok := wordBounds(motion == "W" || motion == "E")
+		return col, end, ok
+	case "$":
+		return col, len(strings.TrimRight(line, " \t")), col < len(line)
+	case "0":
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		return start, col + 1, col >= start && col < len(line)
+	case "s":
+		trimmed := strings.TrimSpace(line)
+		start := strings.Index(line, trimmed)
+		return start, start + len(trimmed), trimmed != ""
+	}
+	return 0, 0, false
+}
+
+// surroundAdd puts the delimiters for ch around bytes start to end of
+// line. It returns the line and the cursor: on the opening delimiter, or
+// for a link between the parentheses, where insert reports that the URL
+// is to be typed.
+func surroundAdd(line string, start, end int, ch string) (string, int, bool, bool) {
+	open, close, ok := surroundPair(ch)
+	if !ok || start > end || end > len(line) {
+		return line, start, false, false
+	}
+	updated := line[:start] + open + line[start:end] + close + line[end:]
+	if ch == "l" {
+		return updated, end + len(open) + 2, true, true
+	}
+	return updated, start, false, true
+}
+
+// surroundFind finds the delimiters for ch around byte col of line,
+// returning where the opening one starts and ends and where the closing one
+// starts and ends. For a link they are the "[" (or "![") and the "](url)".
+func surroundFind(line string, col int, ch string) (openStart, openEnd, closeStart, closeEnd int, ok bool) {
+	open, close, ok := surroundPair(ch)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	col = min(col, len(line))
+
+	switch {
+	case ch == "l":
+		for _, m := range linkInlineRe.FindAllStringSubmatchIndex(line, -1) {
+			if m[0] <= col && col < m[1] {
+				return m[0], m[2], m[3], m[1], true
+			}
+		}
+		return 0, 0, 0, 0, false
+
+	case open != close:
+		// Brackets nest: look back for the unmatched opening one, then
+		// forward for the one that closes it
+		depth := 0
+		o := -1
+		for i := min(col, len(line)-1); i >= 0; i-- {
+			switch {
+			case strings.HasPrefix(line[i:], close) && i != col:
+				depth++
+			case strings.HasPrefix(line[i:], open):
+				if depth == 0 {
+					o = i
+				} else {
+					depth--
+				}
+			}
+			if o >= 0 {
+				break
+			}
+		}
+		if o < 0 {
+			return 0, 0, 0, 0, false
+		}
+		depth = 0
+		for i := o + 1; i < len(line); i++ {
+			switch {
+			case strings.HasPrefix(line[i:], open):
+				depth++
+			case strings.HasPrefix(line[i:], close):
+				if depth == 0 {
+					return o, o + len(open), i, i + len(close), i+len(close) > col
+				}
+				depth--
+			}
+		}
+		return 0, 0, 0, 0, false
+
+	default:
+		// The same delimiter both sides: the nearest pair that contains the
+		// cursor, which may be on either delimiter
+		o := strings.LastIndex(line[:min(len(line), col+len(open))], open)
+		for o >= 0 {
+			if c := strings.Index(line[o+len(open):], close); c >= 0 {
+				c += o + len(open)
+				if c+len(close) > col {
+					return o, o + len(open), c, c + len(close), true
+				}
+			}
+			o = strings.LastIndex(line[:o], open)
+		}
+		return 0, 0, 0, 0, false
+	}
+}
+
+// surroundDelete removes the delimiters for ch around byte col of line,
+// leaving the cursor where the opening one was
+func surroundDelete(line string, col int, ch string) (string, int, bool) {
+	openStart, openEnd, closeStart, closeEnd, ok := surroundFind(line, col, ch)
+	if !ok {
+		return line, col, false
+	}
+	return line[:openStart] + line[openEnd:closeStart] + line[closeEnd:], openStart, true
+}
+
+// surroundChange replaces the delimiters for from around byte col of line
+// with those for to, leaving the cursor on the opening one, or for a link
+// between its parentheses
+func surroundChange(line string, col int, from, to string) (string, int, bool) {
+	openStart, openEnd, closeStart, closeEnd, ok := surroundFind(line, col, from)
+	if !ok {
+		return line, col, false
+	}
+	open, close, ok := surroundPair(to)
+	if !ok {
+		return line, col, false
+	}
+	updated := line[:openStart] + open + line[openEnd:closeStart] + close + line[closeEnd:]
+	if to == "l" {
+		// Between the parentheses, for the URL
+		return updated, openStart + len(open) + closeStart - openEnd + 2, true
+	}
+	return updated, openStart, true
+}
+
+// surroundLine carries out a surround command on line, with the cursor at
+// byte col: ys adds the delimiters for chars[0] around what motion covers,
+// ds deletes those around the cursor, and cs changes them from chars[0] to
+// chars[1]. It returns the line, the cursor and whether to type a link's
+// URL in insert mode.
+func surroundLine(line string, col int, op, motion string, chars []string) (string, int, bool, error) {
+	switch op {
+	case "ys":
+		start, end, ok := surroundSpan(line, col, motion)
+		if !ok {
+			return line, col, false, errors.New("nothing to surround")
+		}
+		updated, cursor, insert, ok := surroundAdd(line, start, end, chars[0])
+		if !ok {
+			return line, col, false, fmt.Errorf("can't surround with %q", chars[0])
+		}
+		return updated, cursor, insert, nil
+	case "ds":
+		updated, cursor, ok := surroundDelete(line, col, chars[0])
+		if !ok {
+			return line, col, false, fmt.Errorf("no %q around the cursor", chars[0])
+		}
+		return updated, cursor, false, nil
+	case "cs":
+		if _, _, ok := surroundPair(chars[1]); !ok {
+			return line, col, false, fmt.Errorf("can't surround with %q", chars[1])
+		}
+		updated, cursor, ok := surroundChange(line, col, chars[0], chars[1])
+		if !ok {
+			return line, col, false, fmt.Errorf("no %q around the cursor", chars[0])
+		}
+		return updated, cursor, chars[1] == "l", nil
+	}
+	return line, col, false, fmt.Errorf("unknown surround command %q", op)
+}
+
+// surroundArgs turns the arguments of ":ys MOTION CHAR", ":cs FROM TO" and
+// ":ds CHAR" into those of surroundLine
+func surroundArgs(op string, args []string) (motion string, chars []string, err error) {
+	want := map[string]int{"ys": 2, "cs": 2, "ds": 1}[op]
+	if len(args) != want {
+		usage := map[string]string{"ys": ":ys MOTION CHAR", "cs": ":cs FROM TO", "ds": ":ds CHAR"}[op]
+		return "", nil, errors.New("usage: " + usage)
+	}
+	if op == "ys" {
+		if !slices.Contains(surroundMotions, args[0]) {
+			return "", nil, fmt.Errorf("unknown motion %q", args[0])
+		}
+		return args[0], args[1:], nil
+	}
+	return "", args, nil
+}
+
+// The styles of :bold, :italic and :code
+const (
+	styleBold   = "bold"
+	styleItalic = "italic"
+	styleCode   = "code"
+)
+
+// toggleStyle makes the word under byte col of line bold, italic or code,
+// or takes the style away when the word already has it. "**" and "*"
+// combine, so "***word***" is both bold and italic. ok is false when there
+// is no word under the cursor.
+func toggleStyle(line string, col int, style string) (string, int, bool) {
+	delim, n := byte('*'), 2
+	switch style {
+	case styleItalic:
+		n = 1
+	case styleCode:
+		delim, n = '`', 1
+	}
+
+	start, end, ok := styleWordSpan(line, col, delim)
+	if !ok {
+		return line, col, false
+	}
+	before := 0
+	for before < 3 && start-before > 0 && line[start-before-1] == delim {
+		before++
+	}
+	after := 0
+	for after < 3 && end+after < len(line) && line[end+after] == delim {
+		after++
+	}
+	has := min(before, after)
+	if delim == '*' && has == 3 || has == n {
+		return line[:start-n] + line[start:end] + line[end+n:], start - n, true
+	}
+	mark := strings.Repeat(string(delim), n)
+	return line[:start] + mark + line[start:end] + mark + line[end:], start + n, true
+}
+
+// styleWordSpan returns the word under byte col of line for toggleStyle:
+// a run of non-blanks without the delimiters and the punctuation around it,
+// so "(**word**)," gives "word". The cursor may be on a delimiter.
+func styleWordSpan(line string, col int, delim byte) (start, end int, ok bool) {
+	start, end, ok = surroundSpan(line, col, "iW")
+	if !ok && col > 0 {
+		// Just after the word, as when typing
+		start, end, ok = surroundSpan(line, col-1, "iW")
+	}
+	if !ok {
+		return 0, 0, false
+	}
+	trim := func(b byte) bool {
+		return b == delim || strings.IndexByte(`()[]{}<>"'.,;:!?`, b) >= 0
+	}
+	for start < end && trim(line[start]) {
+		start++
+	}
+	for end > start && trim(line[end-1]) {
+		end--
+	}
+	return start, end, start < end
+}
+
+// linkWord makes the word under byte col of line a link to target, or
+// takes the link under the cursor away, leaving its text. The cursor goes
+// between the parentheses when target is empty, for the URL to be typed
+// (insert is true then).
+func linkWord(line string, col int, target string) (updated string, cursor int, insert, ok bool) {
+	if unlinked, cursor, found := surroundDelete(line, col, "l"); found {
+		return unlinked, cursor, false, true
+	}
+	start, end, found := styleWordSpan(line, col, '*')
+	if !found {
+		return line, col, false, false
+	}
+	updated = line[:start] + "[" + line[start:end] + "](" + target + ")" + line[end:]
+	if target == "" {
+		return updated, end + 3, true, true
+	}
+	return updated, start, false, true
+}
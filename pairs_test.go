@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// withCursor splits "ab|c" into the line "abc" and the cursor's byte
+// column, 2
+func withCursor(s string) (string, int) {
+	col := strings.IndexByte(s, '|')
+	return s[:col] + s[col+1:], col
+}
+
+// markCursor puts "|" back in line at col
+func markCursor(line string, col int) string {
+	return line[:col] + "|" + line[col:]
+}
+
+func TestAutoPair(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		ch     byte
+		after  string // unchanged when ch is typed as usual
+		inCode bool
+	}{
+		{"bracket", "|", '[', "[|]", false},
+		{"paren before space", "a| b", '(', "a(|) b", false},
+		{"paren before a word", "|word", '(', "|word", false},
+		{"paren before punctuation", "|.", '(', "(|).", false},
+		{"step over bracket", "[a|]", ']', "[a]|", false},
+		{"step over paren", "(|)", ')', "()|", false},
+		{"emphasis", "a |", '*', "a *|*", false},
+		{"bold", "a *|*", '*', "a **|**", false},
+		{"step over emphasis", "a *b|*", '*', "a *b*|", false},
+		{"inside a word", "snake|", '_', "snake|", false},
+		{"after a backslash", `\|`, '*', `\|`, false},
+		{"bullet", "|", '*', "|", false},
+		{"indented bullet", "  |", '-', "  |", false},
+		{"code fence", "``|", '`', "``|", false},
+		{"code span", "a |", '`', "a `|`", false},
+		{"in a code span", "`a|`", '*', "`a|`", false},
+		{"closing a code span", "`a|`", '`', "`a`|", false},
+		{"in a code block", "|", '[', "|", true},
+		{"not a pair", "|", '{', "|", false},
+		{"quote not configured", "a |", '"', "a |", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col := withCursor(tt.before)
+			got, at, ok := autoPair(line, col, tt.ch, DefaultAutoPairs, tt.inCode)
+			if ok != (tt.after != tt.before) || markCursor(got, at) != tt.after {
+				t.Errorf("autoPair(%q, %q) = %q, %v; want %q", tt.before, tt.ch, markCursor(got, at), ok, tt.after)
+			}
+		})
+	}
+}
+
+func TestPairBackspace(t *testing.T) {
+	tests := []struct {
+		before, after string
+		ok            bool
+	}{
+		{"(|)", "|", true},
+		{"a *|* b", "a | b", true},
+		{"(|x)", "(|x)", false},
+		{"|()", "|()", false},
+		{"{|}", "{|}", false}, // "{" is not in the default pairs
+	}
+	for _, tt := range tests {
+		line, col := withCursor(tt.before)
+		got, at, ok := pairBackspace(line, col, DefaultAutoPairs)
+		if ok != tt.ok || markCursor(got, at) != tt.after {
+			t.Errorf("pairBackspace(%q) = %q, %v; want %q, %v", tt.before, markCursor(got, at), ok, tt.after, tt.ok)
+		}
+	}
+}
+
+func TestSurroundLine(t *testing.T) {
+	tests := []struct {
+		name, before, op, motion string
+		chars                    []string
+		after                    string
+		insert, fails            bool
+	}{
+		{"word", "a wo|rd b", "ys", "iw", []string{"*"}, "a |*word* b", false, false},
+		{"bold", "a wo|rd b", "ys", "iw", []string{"b"}, "a |**word** b", false, false},
+		{"strikethrough", "|word", "ys", "iw", []string{"~"}, "|~~word~~", false, false},
+		{"rest of the word", "wo|rd", "ys", "e", []string{"`"}, "wo|`rd`", false, false},
+		{"big word", "see x.m|d now", "ys", "iW", []string{"`"}, "see |`x.md` now", false, false},
+		{"to the end", "a |b c  ", "ys", "$", []string{"_"}, "a |_b c_  ", false, false},
+		{"line", "  a |b", "ys", "s", []string{"("}, "  |(a b)", false, false},
+		{"link", "a wo|rd", "ys", "iw", []string{"l"}, "a [word](|)", true, false},
+		{"on a blank", "a | b", "ys", "iw", []string{"*"}, "a | b", false, true},
+		{"unknown character", "wo|rd", "ys", "iw", []string{"x"}, "wo|rd", false, true},
+		{"delete", "a **wo|rd** b", "ds", "", []string{"b"}, "a |word b", false, false},
+		{"delete brackets", "(a |b)", "ds", "", []string{")"}, "|a b", false, false},
+		{"delete missing", "a wo|rd", "ds", "", []string{"*"}, "a wo|rd", false, true},
+		{"change", "a *wo|rd* b", "cs", "", []string{"*", "`"}, "a |`word` b", false, false},
+		{"change to a link", "a *wo|rd*", "cs", "", []string{"*", "l"}, "a [word](|)", true, false},
+		{"delete a link", "a [wo|rd](x.md) b", "ds", "", []string{"l"}, "a |word b", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col := withCursor(tt.before)
+			got, at, insert, err := surroundLine(line, col, tt.op, tt.motion, tt.chars)
+			if (err != nil) != tt.fails {
+				t.Fatalf("surroundLine(%q, %s %s %q) error %v, want failure %v", tt.before, tt.op, tt.motion, tt.chars, err, tt.fails)
+			}
+			if markCursor(got, at) != tt.after || insert != tt.insert {
+				t.Errorf("surroundLine(%q, %s %s %q) = %q, insert %v; want %q, insert %v", tt.before, tt.op, tt.motion, tt.chars, markCursor(got, at), insert, tt.after, tt.insert)
+			}
+		})
+	}
+}
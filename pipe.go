@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Pipe support: `cmd | hani -` edits standard input, and `hani --pipe` writes
+// the buffer to standard output when it is written (:w, :wq, Ctrl+S) instead
+// of saving a file, so hani can sit in the middle of a shell pipeline.
+
+// readsStdin reports whether the buffer comes from standard input: either "-"
+// was given, or --pipe was given without a file while stdin is redirected
+func readsStdin(filename string, pipe bool) bool {
+	if filename == "-" {
+		return true
+	}
+	return pipe && filename == "" && !term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readStdinBuffer reads standard input into editor lines, dropping the
+// final newline the way loading a file does
+func readStdinBuffer() ([]string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// attachTerminal reconnects the editor to the controlling terminal when
+// stdin or stdout is redirected: keys (and raw mode) need a terminal on
+// os.Stdin, and the screen must not be drawn into a pipe. It returns the
+// original stdout, where pipe mode writes the finished buffer.
+func attachTerminal() (*os.File, error) {
+	stdout := os.Stdout
+	stdinTTY := term.IsTerminal(int(os.Stdin.Fd()))
+	stdoutTTY := term.IsTerminal(int(os.Stdout.Fd()))
+	if stdinTTY && stdoutTTY {
+		return stdout, nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal for keyboard input: %w", err)
+	}
+	if !stdinTTY {
+		os.Stdin = tty
+	}
+	if !stdoutTTY {
+		os.Stdout = tty
+	}
+	return stdout, nil
+}
+
+// writePipeOutput writes a buffer to w as a text stream, newline-terminated
+func writePipeOutput(w io.Writer, content []string) error {
+	_, err := io.WriteString(w, strings.Join(content, "\n")+"\n")
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readOnlyMsg is shown when a key or command would change a read-only buffer
+const readOnlyMsg = "Buffer is read-only (:set noro to allow changes)"
+
+// isWritable reports whether the current user may write an existing file.
+// Files that don't exist yet count as writable; creating them reports its own error.
+func isWritable(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return true
+	}
+	return unix.Access(path, unix.W_OK) == nil
+}
+
+// isViewAlias reports whether hani was started as `view`, which opens
+// files read-only the way vim's view does
+func isViewAlias() bool {
+	return filepath.Base(os.Args[0]) == "view"
+}
+
+// forceWriteFile writes data for ":w!". A file we own but that lacks write
+// permission is made writable for the write and its mode restored afterwards.
+func forceWriteFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil || isWritable(path) {
+		return os.WriteFile(path, data, 0644)
+	}
+
+	mode := info.Mode().Perm()
+	if err := os.Chmod(path, mode|0200); err != nil {
+		return fmt.Errorf("cannot make %s writable (try :w !sudo tee %%): %w", path, err)
+	}
+	defer os.Chmod(path, mode)
+	return os.WriteFile(path, data, mode)
+}
+
+// writeFilterCommand builds the command for ":w !cmd": the buffer is fed to
+// cmd on stdin, exactly as :w would write it, and every "%" in cmd becomes
+// the file name, so ":w !sudo tee %" saves a file we can't write directly.
+// Output and errors are collected in the returned buffer.
+func writeFilterCommand(cmdline, filename string, content []string) (*exec.Cmd, *bytes.Buffer) {
+	cmdline = strings.ReplaceAll(cmdline, "%", shellQuote(filename))
+
+	cmd := exec.Command("sh", "-c", cmdline)
+	cmd.Stdin = strings.NewReader(strings.Join(content, "\n"))
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	return cmd, &output
+}
+
+// filterWritesFile reports whether a ":w !cmd" command writes the buffer's
+// own file (it mentions "%"), in which case the buffer counts as saved
+func filterWritesFile(cmdline string) bool {
+	return strings.Contains(cmdline, "%")
+}
+
+// filterStatus summarizes a finished ":w !cmd" for the status bar: the last
+// line of output, or a confirmation for commands that write the file
+func filterStatus(cmdline string, output string, err error) string {
+	last := ""
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > 0 {
+		last = lines[len(lines)-1]
+	}
+	switch {
+	case err != nil && last != "":
+		return fmt.Sprintf("!%s failed: %s", cmdline, last)
+	case err != nil:
+		return fmt.Sprintf("!%s failed: %v", cmdline, err)
+	case last != "" && !filterWritesFile(cmdline):
+		return last
+	}
+	return fmt.Sprintf("Buffer written to !%s", cmdline)
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
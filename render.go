@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// RenderOptions controls how a document is rendered outside the editor
+type RenderOptions struct {
+	Width   int
+	Style   string
+	NoColor bool
+}
+
+// renderMarkdown renders markdown through the same glamour pipeline as the preview tab
+func renderMarkdown(markdown string, opts RenderOptions) (string, error) {
+	options := []glamour.TermRendererOption{
+		glamourStyleOption(opts.Style),
+		glamour.WithWordWrap(opts.Width),
+	}
+	if opts.NoColor {
+		options = append(options, glamour.WithColorProfile(termenv.Ascii))
+	}
+
+	renderer, err := glamour.NewTermRenderer(options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize markdown renderer: %w", err)
+	}
+
+	return renderer.Render(markdown)
+}
+
+// readDocument reads a markdown document from a file, or from stdin when name is "-"
+func readDocument(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// runRender implements `hani render`, which prints a rendered document and exits
+func runRender(args []string) int {
+	config := LoadConfig()
+
+	fs := newSubcommandFlags("render", "hani render [flags] FILE|-")
+	width := fs.Int("width", 0, "wrap output at `N` columns (default: word_wrap, capped to the terminal)")
+	style := fs.String("style", config.Theme, "glamour `style` name (auto, dark, light, ...) or JSON style file")
+	noColor := fs.Bool("no-color", false, "render without ANSI colors")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readDocument(files[0])
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	stdoutTTY := term.IsTerminal(int(os.Stdout.Fd()))
+
+	opts := RenderOptions{Width: *width, Style: *style, NoColor: *noColor}
+	if opts.Width <= 0 {
+		opts.Width = config.WordWrap
+		if opts.Width <= 0 {
+			opts.Width = DefaultWordWrap
+		}
+		if stdoutTTY {
+			if termWidth, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && termWidth < opts.Width {
+				opts.Width = termWidth
+			}
+		}
+	}
+
+	rendered, err := renderMarkdown(previewMarkdown(strings.Split(string(data), "\n"), config.FrontMatterCard), opts)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	if stdoutTTY {
+		err = pageOutput(rendered)
+	} else {
+		_, err = io.WriteString(os.Stdout, rendered)
+	}
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	return 0
+}
+
+// pageOutput shows text through $PAGER (default "less"), printing it directly
+// when no pager can be started. Like git, it sets LESS=FRX when LESS is unset
+// so colors pass through and short documents don't wait for a keypress.
+func pageOutput(text string) error {
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	if err := cmd.Start(); err != nil {
+		_, err = io.WriteString(os.Stdout, text)
+		return err
+	}
+	return cmd.Wait()
+}
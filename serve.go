@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// previewServer serves the HTML rendering of a document on the loopback
+// interface and pushes every change to connected browsers over Server-Sent
+// Events, together with the editor's cursor line so the page can follow along.
+type previewServer struct {
+	url    string
+	theme  string
+	server *http.Server
+
+	mu      sync.Mutex
+	source  string
+	title   string
+	line    int
+	version int
+	changed chan struct{} // closed and replaced whenever state changes
+	done    chan struct{}
+}
+
+// startPreviewServer listens on 127.0.0.1:port (0 picks a free port) and
+// starts serving in the background
+func startPreviewServer(port int, theme string) (*previewServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &previewServer{
+		url:     "http://" + listener.Addr().String() + "/",
+		theme:   theme,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/events", s.handleEvents)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// URL returns the address browsers should open
+func (s *previewServer) URL() string {
+	return s.url
+}
+
+// Update publishes the current buffer and cursor line (-1 when there is no
+// cursor to follow). Unchanged state is ignored, so it is cheap to call after
+// every keystroke.
+func (s *previewServer) Update(content []string, filename string, line int) {
+	source := strings.Join(content, "\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source == s.source && line == s.line && s.version > 0 {
+		return
+	}
+	if source != s.source || s.version == 0 {
+		s.source = source
+		s.title = documentTitle(content, filename)
+		s.version++
+	}
+	s.line = line
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Close disconnects all browsers and stops the server
+func (s *previewServer) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return nil
+	default:
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// snapshot returns the current state and the channel that signals the next change
+func (s *previewServer) snapshot() (source, title string, line, version int, changed chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.source, s.title, s.line, s.version, s.changed
+}
+
+func (s *previewServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	css, err := themeCSS(s.theme)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	source, title, _, _, _ := s.snapshot()
+	var body bytes.Buffer
+	if err := convertHTML(&body, []byte(source), true); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, servePageTemplate, html.EscapeString(title), css, body.String(), serveScript)
+}
+
+// handleEvents streams "update" events (new HTML) and "cursor" events (the
+// editor moved to another line) until the client or the server goes away
+func (s *previewServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+
+	lastVersion, lastLine := -1, -1
+	for {
+		source, title, line, version, changed := s.snapshot()
+
+		if version != lastVersion {
+			var body bytes.Buffer
+			if err := convertHTML(&body, []byte(source), true); err != nil {
+				return
+			}
+			writeEvent(w, "update", map[string]any{"html": body.String(), "title": title, "line": line})
+		} else if line != lastLine {
+			writeEvent(w, "cursor", map[string]any{"line": line})
+		}
+		flusher.Flush()
+		lastVersion, lastLine = version, line
+
+		select {
+		case <-changed:
+		case <-s.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes one Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, payload any) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+const servePageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+<main class="markdown-body" id="content">
+%s</main>
+<script>
+%s</script>
+</body>
+</html>
+`
+
+// serveScript swaps in new HTML as it arrives and scrolls to the heading of
+// the section containing the editor cursor, only when that section changes
+// so it doesn't fight a reader who scrolls by hand.
+const serveScript = `(function () {
+  var content = document.getElementById("content");
+  var current = null;
+
+  function follow(line) {
+    if (line < 0) return;
+    var target = null;
+    var headings = content.querySelectorAll("[data-line]");
+    for (var i = 0; i < headings.length; i++) {
+      if (Number(headings[i].dataset.line) > line) break;
+      target = headings[i];
+    }
+    var key = target ? target.dataset.line : "top";
+    if (key === current) return;
+    current = key;
+    if (target) {
+      target.scrollIntoView({behavior: "smooth", block: "start"});
+    } else {
+      window.scrollTo({top: 0, behavior: "smooth"});
+    }
+  }
+
+  var events = new EventSource("/events");
+  events.addEventListener("update", function (e) {
+    var data = JSON.parse(e.data);
+    var y = window.scrollY;
+    content.innerHTML = data.html;
+    document.title = data.title;
+    window.scrollTo(0, y);
+    follow(data.line);
+  });
+  events.addEventListener("cursor", function (e) {
+    follow(JSON.parse(e.data).line);
+  });
+})();
+`
+
+// runServe implements `hani serve`, previewing a file in the browser and
+// reloading whenever it changes on disk
+func runServe(args []string) int {
+	config := LoadConfig()
+
+	fs := newSubcommandFlags("serve", "hani serve [--port N] [--theme NAME] FILE")
+	port := fs.Int("port", 0, "listen on `port` (default: any free port)")
+	theme := fs.String("theme", config.Theme, "glamour `theme` the styling is derived from")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	name := files[0]
+
+	source, err := readDocument(name)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	server, err := startPreviewServer(*port, *theme)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	defer server.Close()
+
+	server.Update(strings.Split(string(source), "\n"), name, -1)
+	fmt.Fprintf(os.Stderr, "Serving %s at %s (Ctrl+C to stop)\n", name, server.URL())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	// Poll rather than watch: it is portable and survives editors that save
+	// by replacing the file
+	var lastMod time.Time
+	if info, err := os.Stat(name); err == nil {
+		lastMod = info.ModTime()
+	}
+	ticker := time.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			return 0
+		case <-ticker.C:
+			if name == "-" {
+				continue
+			}
+			info, err := os.Stat(name)
+			if err != nil || info.ModTime().Equal(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			if source, err := os.ReadFile(name); err == nil {
+				server.Update(strings.Split(string(source), "\n"), name, -1)
+			}
+		}
+	}
+}
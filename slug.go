@@ -0,0 +1,89 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	inlineImageRe    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	inlineLinkRe     = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	inlineAutoRe     = regexp.MustCompile(`<((?:https?|mailto|ftp):[^>]*)>`)
+	inlineHTMLRe     = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	inlineEscapeRe   = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+	inlineEmphRe     = regexp.MustCompile(`\*+|~~|(^|[^\pL\pN])_+|_+([^\pL\pN]|$)`)
+	inlineCodeSpanRe = regexp.MustCompile("`+([^`]*)`+")
+)
+
+// stripInlineMarkdown reduces inline markdown to the text a reader would see,
+// e.g. "Use `go` [here](x.md)" becomes "Use go here".
+func stripInlineMarkdown(text string) string {
+	text = inlineImageRe.ReplaceAllString(text, "$1")
+	text = inlineLinkRe.ReplaceAllString(text, "$1")
+	text = inlineAutoRe.ReplaceAllString(text, "$1")
+	text = inlineHTMLRe.ReplaceAllString(text, "")
+
+	// Code spans keep their content verbatim, so protect them from the
+	// emphasis and escape rules below.
+	var spans []string
+	text = inlineCodeSpanRe.ReplaceAllStringFunc(text, func(span string) string {
+		spans = append(spans, strings.Trim(span, "`"))
+		return "\x00" + strconv.Itoa(len(spans)-1) + "\x00"
+	})
+
+	text = inlineEmphRe.ReplaceAllString(text, "$1$2")
+	text = inlineEscapeRe.ReplaceAllString(text, "$1")
+
+	for i, span := range spans {
+		text = strings.Replace(text, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+	return strings.TrimSpace(text)
+}
+
+// githubSlug converts heading text into the anchor GitHub generates for it:
+// lowercase, spaces become hyphens, and punctuation other than '-' and '_' is dropped.
+func githubSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(stripInlineMarkdown(text)) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// slugger hands out unique heading anchors, numbering repeats the way
+// GitHub does ("intro", "intro-1", "intro-2").
+type slugger struct {
+	occurrences map[string]int
+}
+
+func newSlugger() *slugger {
+	return &slugger{occurrences: make(map[string]int)}
+}
+
+// slug returns the unique anchor for the given heading text
+func (s *slugger) slug(text string) string {
+	return s.unique(githubSlug(text))
+}
+
+// unique de-duplicates an already slugified anchor
+func (s *slugger) unique(base string) string {
+	result := base
+	for {
+		if _, taken := s.occurrences[result]; !taken {
+			break
+		}
+		s.occurrences[base]++
+		result = base + "-" + strconv.Itoa(s.occurrences[base])
+	}
+	s.occurrences[result] = 0
+	return result
+}
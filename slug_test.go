@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGithubSlug(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Getting Started", "getting-started"},
+		{"What's new?", "whats-new"},
+		{"snake_case and kebab-case", "snake_case-and-kebab-case"},
+		{"  Two  spaces ", "two--spaces"},
+		{"Use `go build`", "use-go-build"},
+		{"See [the docs](docs.md)", "see-the-docs"},
+		{"**Bold** and _italic_", "bold-and-italic"},
+		{"Version 1.2.3", "version-123"},
+		{"Café crème", "café-crème"},
+		{"日本語の見出し", "日本語の見出し"},
+		{"Emoji 🎉 party", "emoji--party"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := githubSlug(tt.text); got != tt.want {
+			t.Errorf("githubSlug(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSluggerUnique(t *testing.T) {
+	tests := []struct {
+		name        string
+		bases, want []string
+	}{
+		{"distinct", []string{"a", "b"}, []string{"a", "b"}},
+		{"repeats", []string{"intro", "intro", "intro"}, []string{"intro", "intro-1", "intro-2"}},
+		{"taken suffix", []string{"intro-1", "intro", "intro"}, []string{"intro-1", "intro", "intro-2"}},
+		{"suffix after repeats", []string{"intro", "intro", "intro-1"}, []string{"intro", "intro-1", "intro-1-1"}},
+		{"empty", []string{"", ""}, []string{"", "-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSlugger()
+			var got []string
+			for _, base := range tt.bases {
+				got = append(got, s.unique(base))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unique(%q) = %q, want %q", tt.bases, got, tt.want)
+			}
+		})
+	}
+}
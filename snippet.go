@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snippet is a piece of text that its prefix, typed before the cursor in
+// insert mode, expands into on Tab. The body uses the snippet syntax of
+// VS Code and TextMate: $1, ${1:default} and ${1|one,two|} are fields,
+// repeated numbers mirror each other, $0 is where the cursor ends up, and
+// $NAME or ${NAME:default} insert a variable.
+type snippet struct {
+	Prefix      string
+	Body        string
+	Description string
+}
+
+// markdownSnippets are the snippets Markdown buffers have without a
+// snippet file; the user's snippets replace those with the same prefix
+var markdownSnippets = []snippet{
+	{"table", "| ${1:Column} | ${2:Column} |\n| --- | --- |\n| $3 | $4 |\n$0", "Table"},
+	{"code", "```${1:language}\n$0\n```", "Fenced code block"},
+	{"details", "<details>\n<summary>${1:Summary}</summary>\n\n$0\n\n</details>", "Collapsible section"},
+	{"front", "---\ntitle: ${1:$TM_FILENAME_BASE}\ndate: $CURRENT_YEAR-$CURRENT_MONTH-$CURRENT_DATE\ntags: [$2]\n---\n\n$0", "YAML front matter"},
+	{"link", "[${1:text}](${2:url})$0", "Link"},
+	{"img", "![${1:alt text}](${2:path})$0", "Image"},
+	{"!note", "> [!NOTE]\n> $0", "Note admonition"},
+	{"!tip", "> [!TIP]\n> $0", "Tip admonition"},
+	{"!important", "> [!IMPORTANT]\n> $0", "Important admonition"},
+	{"!warning", "> [!WARNING]\n> $0", "Warning admonition"},
+	{"!caution", "> [!CAUTION]\n> $0", "Caution admonition"},
+	{"date", "$CURRENT_YEAR-$CURRENT_MONTH-$CURRENT_DATE", "Today's date"},
+}
+
+// snippetDir is where snippet files live, ~/.config/hani/snippets
+func snippetDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "hani", "snippets"), nil
+}
+
+// snippetFiletype names the snippet file for file: "markdown" for Markdown
+// files and buffers without a name, otherwise the extension
+func snippetFiletype(file string) string {
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case "", ".md", ".markdown", ".mdown", ".mkd", ".mkdn", ".mdx":
+		return "markdown"
+	default:
+		return ext[1:]
+	}
+}
+
+// loadSnippets returns the snippets for file: the built-in ones for
+// Markdown, then those of all.json and of the file type's own file (such as
+// markdown.json) in the snippet directory. A file that can't be read is
+// reported, and the rest are still loaded.
+func loadSnippets(file string) ([]snippet, error) {
+	filetype := snippetFiletype(file)
+	var snippets []snippet
+	if filetype == "markdown" {
+		snippets = slices.Clone(markdownSnippets)
+	}
+	dir, err := snippetDir()
+	if err != nil {
+		return snippets, err
+	}
+	var errs []error
+	for _, name := range []string{"all", filetype} {
+		loaded, err := readSnippetFile(filepath.Join(dir, name+".json"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		snippets = append(snippets, loaded...)
+	}
+	return snippets, errors.Join(errs...)
+}
+
+// readSnippetFile reads a snippet file in VS Code's format: an object of
+// named snippets, each with a prefix (or a list of them), a body (a string
+// or a list of lines) and an optional description
+func readSnippetFile(path string) ([]snippet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file map[string]struct {
+		Prefix      json.RawMessage `json:"prefix"`
+		Body        json.RawMessage `json:"body"`
+		Description string          `json:"description"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(file))
+	for name := range file {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var snippets []snippet
+	for _, name := range names {
+		def := file[name]
+		prefixes, err := stringOrList(def.Prefix)
+		if err != nil || len(prefixes) == 0 {
+			return snippets, fmt.Errorf("%s: snippet %q needs a prefix", path, name)
+		}
+		body, err := stringOrList(def.Body)
+		if err != nil {
+			return snippets, fmt.Errorf("%s: snippet %q: body must be a string or a list of lines", path, name)
+		}
+		description := def.Description
+		if description == "" {
+			description = name
+		}
+		for _, prefix := range prefixes {
+			snippets = append(snippets, snippet{prefix, strings.Join(body, "\n"), description})
+		}
+	}
+	return snippets, nil
+}
+
+// stringOrList decodes a JSON string, or a list of them
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []string{s}, nil
+	}
+	var list []string
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+// snippetSummary lists the prefixes of snippets, for ":snippets"
+func snippetSummary(snippets []snippet) string {
+	seen := map[string]bool{}
+	var prefixes []string
+	for _, s := range snippets {
+		if !seen[s.Prefix] {
+			seen[s.Prefix] = true
+			prefixes = append(prefixes, s.Prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return "No snippets"
+	}
+	return fmt.Sprintf("%d snippets: %s", len(prefixes), strings.Join(prefixes, " "))
+}
+
+// findSnippet returns the snippet whose prefix ends at byte col of line,
+// and where the prefix starts. The longest prefix wins, and of equal ones
+// the last loaded. A prefix can't start in the middle of a word.
+func findSnippet(snippets []snippet, line string, col int) (snippet, int, bool) {
+	before := line[:min(col, len(line))]
+	var found snippet
+	start, ok := 0, false
+	for _, s := range snippets {
+		if s.Prefix == "" || !strings.HasSuffix(before, s.Prefix) || (ok && len(s.Prefix) < len(found.Prefix)) {
+			continue
+		}
+		at := len(before) - len(s.Prefix)
+		if at > 0 && isWordByte(before[at-1]) && isWordByte(s.Prefix[0]) {
+			continue
+		}
+		found, start, ok = s, at, true
+	}
+	return found, start, ok
+}
+
+// isWordByte reports whether b can be part of a word
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// snippetNode is a piece of a parsed snippet body: literal text, a field
+// (stop >= 0) or a variable, the last two with their default as children
+type snippetNode struct {
+	text     string
+	stop     int
+	variable string
+	children []snippetNode
+}
+
+// snippetParser parses snippet bodies
+type snippetParser struct {
+	s string
+	i int
+}
+
+// parseSnippet parses a snippet body into nodes
+func parseSnippet(body string) []snippetNode {
+	p := &snippetParser{s: body}
+	return p.nodes(false)
+}
+
+// nodes parses up to the end of the body, or in a default up to its "}"
+func (p *snippetParser) nodes(inner bool) []snippetNode {
+	var nodes []snippetNode
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, snippetNode{text: text.String(), stop: -1})
+			text.Reset()
+		}
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.s) && strings.IndexByte(`$}\`, p.s[p.i+1]) >= 0:
+			text.WriteByte(p.s[p.i+1])
+			p.i += 2
+		case c == '}' && inner:
+			flush()
+			return nodes
+		case c == '$':
+			if node, ok := p.dollar(); ok {
+				flush()
+				nodes = append(nodes, node)
+				continue
+			}
+			text.WriteByte(c)
+			p.i++
+		default:
+			text.WriteByte(c)
+			p.i++
+		}
+	}
+	flush()
+	return nodes
+}
+
+// dollar parses the field or variable at a "$", or reports that it is a
+// plain dollar sign, leaving the position alone
+func (p *snippetParser) dollar() (snippetNode, bool) {
+	start := p.i
+	p.i++
+	braced := p.i < len(p.s) && p.s[p.i] == '{'
+	if braced {
+		p.i++
+	}
+
+	node := snippetNode{stop: -1}
+	if n := p.digits(); n != "" {
+		node.stop, _ = strconv.Atoi(n)
+	} else if name := p.name(); name != "" {
+		node.variable = name
+	} else {
+		p.i = start
+		return node, false
+	}
+	if !braced {
+		return node, true
+	}
+
+	switch {
+	case p.i < len(p.s) && p.s[p.i] == '}':
+		p.i++
+		return node, true
+	case p.i < len(p.s) && p.s[p.i] == ':':
+		p.i++
+		node.children = p.nodes(true)
+		if p.i < len(p.s) {
+			p.i++ // the closing "}"
+			return node, true
+		}
+	case p.i < len(p.s) && p.s[p.i] == '|' && node.stop >= 0:
+		if end := strings.Index(p.s[p.i+1:], "|}"); end >= 0 {
+			choices := p.s[p.i+1 : p.i+1+end]
+			first, _, _ := strings.Cut(choices, ",")
+			node.children = []snippetNode{{text: first, stop: -1}}
+			p.i += end + 3
+			return node, true
+		}
+	}
+	p.i = start
+	return node, false
+}
+
+// digits consumes a run of digits
+func (p *snippetParser) digits() string {
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// name consumes a variable name
+func (p *snippetParser) name() string {
+	start := p.i
+	for p.i < len(p.s) && (p.s[p.i] == '_' || p.s[p.i] >= 'A' && p.s[p.i] <= 'Z' || p.s[p.i] >= 'a' && p.s[p.i] <= 'z' ||
+		p.i > start && p.s[p.i] >= '0' && p.s[p.i] <= '9') {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// snippetVariable returns the value of a snippet variable for a buffer
+// editing file, or false if there is no such variable. clipboard is only
+// called for $CLIPBOARD.
+func snippetVariable(name, file string, now time.Time, clipboard func() string) (string, bool) {
+	switch name {
+	case "CURRENT_YEAR":
+		return now.Format("2006"), true
+	case "CURRENT_YEAR_SHORT":
+		return now.Format("06"), true
+	case "CURRENT_MONTH":
+		return now.Format("01"), true
+	case "CURRENT_MONTH_NAME":
+		return now.Format("January"), true
+	case "CURRENT_MONTH_NAME_SHORT":
+		return now.Format("Jan"), true
+	case "CURRENT_DATE":
+		return now.Format("02"), true
+	case "CURRENT_DAY_NAME":
+		return now.Format("Monday"), true
+	case "CURRENT_DAY_NAME_SHORT":
+		return now.Format("Mon"), true
+	case "CURRENT_HOUR":
+		return now.Format("15"), true
+	case "CURRENT_MINUTE":
+		return now.Format("04"), true
+	case "CURRENT_SECOND":
+		return now.Format("05"), true
+	case "CURRENT_SECONDS_UNIX":
+		return strconv.FormatInt(now.Unix(), 10), true
+	case "DATE":
+		return now.Format("2006-01-02"), true
+	case "TIME":
+		return now.Format("15:04"), true
+	case "TM_FILENAME", "FILENAME":
+		return filepath.Base(file), file != ""
+	case "TM_FILENAME_BASE":
+		base := filepath.Base(file)
+		return strings.TrimSuffix(base, filepath.Ext(base)), file != ""
+	case "TM_FILEPATH":
+		abs, err := filepath.Abs(file)
+		return abs, file != "" && err == nil
+	case "TM_DIRECTORY":
+		abs, err := filepath.Abs(filepath.Dir(file))
+		return abs, err == nil
+	case "CLIPBOARD":
+		return clipboard(), true
+	}
+	return "", false
+}
+
+// snippetRange is where one occurrence of a field is in the buffer: bytes
+// col to end of row. seq orders the occurrences as they are in the body.
+type snippetRange struct {
+	row, col, end int
+	seq           int
+	dead          bool // typed over by an enclosing field
+}
+
+// snippetStop is a field: its number and every place it occurs, the first
+// of which is the one edited and the rest mirrors of it
+type snippetStop struct {
+	num    int
+	ranges []*snippetRange
+}
+
+// snippetSession is an expanded snippet whose fields Tab and Shift+Tab
+// move between. It lasts while the text is edited within the current field
+// and the cursor stays on the snippet's lines, and ends at the last field.
+type snippetSession struct {
+	stops       []snippetStop // in the order Tab visits them, $0 last
+	current     int
+	fresh       bool // typing replaces the field, as if it were selected
+	top, bottom int  // the rows the snippet covers
+
+	// The buffer as it was before the key being handled
+	line   string
+	rows   int
+	cursor Position
+}
+
+// expandSnippet replaces the prefix from byte start of the cursor's line
+// with s's body, indenting its lines like the cursor's. It returns the new
+// content, the cursor on the first field and, if the snippet has fields to
+// visit, the session that moves between them.
+func expandSnippet(content []string, cursor Position, start int, s snippet, variable func(string) (string, bool)) ([]string, Position, *snippetSession) {
+	line := content[cursor.row]
+	b := &snippetBuilder{
+		lines:    []string{line[:start]},
+		indent:   line[:len(line)-len(strings.TrimLeft(line, " \t"))],
+		variable: variable,
+		defaults: map[int][]snippetNode{},
+	}
+	nodes := parseSnippet(s.Body)
+	b.collectDefaults(nodes)
+	b.render(nodes, 0)
+
+	stops := map[int]*snippetStop{}
+	for _, r := range b.ranges {
+		stop, ok := stops[r.num]
+		if !ok {
+			stop = &snippetStop{num: r.num}
+			stops[r.num] = stop
+		}
+		stop.ranges = append(stop.ranges, r.snippetRange)
+	}
+	last := len(b.lines) - 1
+	if _, ok := stops[0]; !ok {
+		stops[0] = &snippetStop{ranges: []*snippetRange{{row: last, col: len(b.lines[last]), end: len(b.lines[last]), seq: len(b.ranges)}}}
+	}
+	b.lines[last] += line[min(cursor.col, len(line)):]
+
+	session := &snippetSession{top: cursor.row, bottom: cursor.row + last}
+	for _, num := range slices.Sorted(maps.Keys(stops)) {
+		if num > 0 {
+			session.stops = append(session.stops, *stops[num])
+		}
+	}
+	session.stops = append(session.stops, *stops[0])
+	for _, stop := range session.stops {
+		for _, r := range stop.ranges {
+			r.row += cursor.row
+		}
+	}
+
+	updated := slices.Concat(content[:cursor.row], b.lines, content[cursor.row+1:])
+	first := session.stops[0].ranges[0]
+	pos := Position{row: first.row, col: first.end}
+	if len(session.stops) == 1 {
+		return updated, Position{row: first.row, col: first.col}, nil
+	}
+	session.fresh = true
+	session.mark(updated, pos)
+	return updated, pos, session
+}
+
+// snippetBuilder renders parsed snippet nodes into lines, noting where
+// each field ends up
+type snippetBuilder struct {
+	lines    []string
+	indent   string
+	variable func(string) (string, bool)
+	defaults map[int][]snippetNode
+	ranges   []numberedRange
+}
+
+// numberedRange is a field's range with its number
+type numberedRange struct {
+	*snippetRange
+	num int
+}
+
+// collectDefaults records the first default given for each field, which
+// its mirrors without one of their own share
+func (b *snippetBuilder) collectDefaults(nodes []snippetNode) {
+	for _, n := range nodes {
+		if n.stop >= 0 && n.children != nil {
+			if _, ok := b.defaults[n.stop]; !ok {
+				b.defaults[n.stop] = n.children
+			}
+		}
+		b.collectDefaults(n.children)
+	}
+}
+
+// write adds text, starting the lines after a newline at the indent
+func (b *snippetBuilder) write(text string) {
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.lines = append(b.lines, b.indent)
+		}
+		b.lines[len(b.lines)-1] += part
+	}
+}
+
+// render writes nodes; depth stops fields that contain themselves
+func (b *snippetBuilder) render(nodes []snippetNode, depth int) {
+	for _, n := range nodes {
+		switch {
+		case n.stop >= 0:
+			r := &snippetRange{row: len(b.lines) - 1, col: len(b.lines[len(b.lines)-1]), seq: len(b.ranges)}
+			b.ranges = append(b.ranges, numberedRange{r, n.stop})
+			children := n.children
+			if children == nil {
+				children = b.defaults[n.stop]
+			}
+			if depth < 8 {
+				b.render(children, depth+1)
+			}
+			// A field that runs over more than one line can't be edited in
+			// place, so it becomes an empty one where it starts
+			if last := len(b.lines) - 1; last == r.row {
+				r.end = len(b.lines[last])
+			} else {
+				r.end = r.col
+			}
+		case n.variable != "":
+			if value, ok := b.variable(n.variable); ok {
+				b.write(value)
+			} else if n.children != nil {
+				b.render(n.children, depth)
+			} else {
+				b.write(n.variable)
+			}
+		default:
+			b.write(n.text)
+		}
+	}
+}
+
+// mark records the buffer before a key is handled, so that follow can tell
+// what the key changed
+func (s *snippetSession) mark(content []string, cursor Position) {
+	s.rows = len(content)
+	s.cursor = cursor
+	if f := s.field(); f != nil {
+		s.line = content[f.row]
+	}
+}
+
+// field returns the occurrence of the current field that is edited
+func (s *snippetSession) field() *snippetRange {
+	if s.current >= len(s.stops) {
+		return nil
+	}
+	return s.stops[s.current].ranges[0]
+}
+
+// follow brings the session up to date after a key has changed content and
+// moved the cursor: what was typed in the current field is copied to its
+// mirrors, and if the field was fresh it replaces the default. It returns
+// the cursor, and false once the session is over.
+func (s *snippetSession) follow(content []string, cursor Position) (Position, bool) {
+	f := s.field()
+	if f == nil || len(content) != s.rows || cursor.row < s.top || cursor.row > s.bottom {
+		return cursor, false
+	}
+	old, line := s.line, content[f.row]
+	if line == old {
+		if cursor != s.cursor {
+			s.fresh = false
+		}
+		return cursor, true
+	}
+
+	delta := len(line) - len(old)
+	end := f.end + delta
+	if end < f.col || line[:f.col] != old[:f.col] || line[end:] != old[f.end:] {
+		return cursor, false
+	}
+	value := line[f.col:end]
+	offset := cursor.col - f.col
+	if s.fresh && s.cursor == (Position{row: f.row, col: f.end}) {
+		if delta > 0 && line[:f.end] == old[:f.end] {
+			value = line[f.end:end]
+		} else {
+			value = ""
+		}
+		offset = len(value)
+	}
+	s.fresh = false
+
+	content[f.row] = old
+	for _, r := range s.stops[s.current].ranges {
+		if !r.dead {
+			s.replace(content, r, value)
+		}
+	}
+	cursor = Position{row: f.row, col: f.col + offset}
+	s.mark(content, cursor)
+	return cursor, true
+}
+
+// replace puts text in place of r, moving the fields after it on its line
+// and stretching those around it
+func (s *snippetSession) replace(content []string, r *snippetRange, text string) {
+	line := content[r.row]
+	content[r.row] = line[:r.col] + text + line[r.end:]
+	start, end := r.col, r.end
+	delta := len(text) - (end - start)
+	r.end = start + len(text)
+	for _, stop := range s.stops {
+		for _, o := range stop.ranges {
+			if o == r || o.row != r.row || o.dead {
+				continue
+			}
+			switch {
+			case o.col > end || o.col == end && o.seq > r.seq:
+				o.col += delta
+				o.end += delta
+			case o.seq < r.seq && o.col <= start && o.end >= end:
+				o.end += delta
+			case o.seq > r.seq && o.col >= start && o.end <= end:
+				o.dead = true
+			}
+		}
+	}
+}
+
+// jump moves to the next field (dir 1) or the previous one (-1), with the
+// cursor after its text and typing replacing it. It returns false when the
+// jump reaches $0, which ends the session.
+func (s *snippetSession) jump(content []string, dir int) (Position, bool) {
+	next := s.current
+	for i := s.current + dir; i >= 0 && i < len(s.stops); i += dir {
+		f := s.stops[i].ranges[0]
+		if i == len(s.stops)-1 {
+			return Position{row: f.row, col: f.col}, false
+		}
+		if !f.dead {
+			next = i
+			break
+		}
+	}
+	s.current = next
+	s.fresh = true
+	f := s.field()
+	pos := Position{row: f.row, col: f.end}
+	s.mark(content, pos)
+	return pos, true
+}
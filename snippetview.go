@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// loadSnippets loads the snippets for the buffer's file type
+func (m *Model) loadSnippets() {
+	snippets, err := loadSnippets(m.filename)
+	m.snippets = snippets
+	if err != nil {
+		m.setStatusMsg("Snippets: "+err.Error(), true)
+	}
+}
+
+// snippetTab handles Tab and Shift+Tab in insert mode for snippets: in an
+// expanded snippet they move between its fields, and otherwise Tab expands
+// the snippet whose prefix is before the cursor
+func (m Model) snippetTab(dir int) (Model, bool) {
+	if m.activeTab != TabEditor || m.mode != ModeInsert || m.readOnly {
+		return m, false
+	}
+	if m.snippet != nil {
+		pos, ok := m.snippet.jump(m.content, dir)
+		if !ok {
+			m.snippet = nil
+		}
+		m.cursor = pos
+		m.adjustViewport()
+		return m, true
+	}
+	if dir < 0 {
+		return m, false
+	}
+	s, start, ok := findSnippet(m.snippets, m.content[m.cursor.row], m.cursor.col)
+	if !ok {
+		return m, false
+	}
+	m.content, m.cursor, m.snippet = expandSnippet(m.content, m.cursor, start, s, func(name string) (string, bool) {
+		return snippetVariable(name, m.filename, time.Now(), getClipboard)
+	})
+	m.saved = false
+	m.adjustViewport()
+	return m, true
+}
+
+// followSnippet keeps the snippet being filled in up to date after a key,
+// copying what was typed in a field to its mirrors. The snippet is left
+// behind once the key leaves insert mode, edits outside the field or moves
+// off the snippet's lines.
+func (m *Model) followSnippet() {
+	if m.snippet == nil {
+		return
+	}
+	if m.mode != ModeInsert || m.activeTab != TabEditor {
+		m.snippet = nil
+		return
+	}
+	cursor, ok := m.snippet.follow(m.content, m.cursor)
+	if !ok {
+		m.snippet = nil
+		return
+	}
+	m.cursor = cursor
+}
+
+// snippetsCommand implements ":snippets", which loads the snippet files
+// again and lists the prefixes there are
+func (m Model) snippetsCommand() (tea.Model, tea.Cmd) {
+	snippets, err := loadSnippets(m.filename)
+	m.snippets = snippets
+	if err != nil {
+		m.setStatusMsg("Snippets: "+err.Error(), true)
+		return m, nil
+	}
+	m.setStatusMsg(snippetSummary(snippets), false)
+	return m, nil
+}
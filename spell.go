@@ -0,0 +1,693 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// spellProjectName is the per-project word list, found like
+// .markdownlint.json in the file's directory or above; one word a line
+const spellProjectName = ".spelling"
+
+// spellMaxSuggestions is how many suggestions z= offers, one for each of
+// the keys 1-9
+const spellMaxSuggestions = 9
+
+var (
+	// spellWordRe matches a word: letters, with apostrophes inside
+	spellWordRe = regexp.MustCompile(`\pL+(?:['’]\pL+)*`)
+
+	// spellSkipRe matches what isn't prose besides code spans, HTML and
+	// URLs: link destinations and reference labels
+	spellSkipRe = regexp.MustCompile(`\]\([^)]*\)|\]\[[^\]]*\]`)
+)
+
+// misspelling is a word no dictionary knows, at bytes Col to End of Line
+type misspelling struct {
+	Line, Col, End int
+	Word           string
+}
+
+// spellPopup offers the suggestions of z= for a word, drawn below it
+type spellPopup struct {
+	open        bool
+	word        misspelling // the word to replace
+	suggestions []string
+	sel         int
+}
+
+// dictionary is a Hunspell dictionary: stems with the flags of the affixes
+// they take, and the affix rules. A plain word list is a dictionary with no
+// affixes.
+type dictionary struct {
+	words     map[string][]string
+	prefixes  []affixRule
+	suffixes  []affixRule
+	flagMode  string     // "", "long", "num" or "UTF-8"
+	aliases   [][]string // AF flag sets, referred to by number
+	try       string     // letters to try in suggestions, most common first
+	rep       [][2]string
+	needAffix string // stems with this flag only count with an affix
+	forbidden string
+}
+
+// affixRule is one PFX or SFX line: strip is taken off the stem and add put
+// on in its place, if the stem matches cond
+type affixRule struct {
+	flag   string
+	prefix bool
+	cross  bool // combines with affixes of the other kind
+	strip  string
+	add    string
+	cond   *regexp.Regexp // nil matches any stem
+}
+
+// dictionaryDirs are where Hunspell dictionaries are looked for: $DICPATH,
+// then the usual places on Linux and macOS
+func dictionaryDirs() []string {
+	var dirs []string
+	if path := os.Getenv("DICPATH"); path != "" {
+		dirs = append(dirs, filepath.SplitList(path)...)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, ".local", "share", "hunspell"),
+			filepath.Join(home, "Library", "Spelling"))
+	}
+	return append(dirs, "/usr/share/hunspell", "/usr/share/myspell", "/usr/share/myspell/dicts", "/Library/Spelling")
+}
+
+// findDictionary returns the .dic file to check with: spell_dict, or
+// spell_lang's dictionary in dictionaryDirs. Without one, English falls
+// back to the system word list.
+func findDictionary(config Config) (string, error) {
+	if config.SpellDict != "" {
+		return config.SpellDict, nil
+	}
+	lang := config.SpellLang
+	if lang == "" {
+		lang = "en_US"
+	}
+	for _, dir := range dictionaryDirs() {
+		path := filepath.Join(dir, lang+".dic")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if strings.HasPrefix(lang, "en") {
+		if _, err := os.Stat("/usr/share/dict/words"); err == nil {
+			return "/usr/share/dict/words", nil
+		}
+	}
+	return "", fmt.Errorf("no dictionary for %s (install Hunspell's %s.dic and %s.aff, or set spell_dict)", lang, lang, lang)
+}
+
+// loadDictionary reads a .dic file and the .aff file beside it. A file
+// without an .aff beside it is read as a plain list of words.
+func loadDictionary(path string) (*dictionary, error) {
+	d := &dictionary{words: make(map[string][]string)}
+	latin1 := false
+	aff, err := os.ReadFile(strings.TrimSuffix(path, ".dic") + ".aff")
+	switch {
+	case err == nil:
+		latin1 = affEncoding(aff) != "UTF-8"
+		d.parseAff(decodeDictionary(aff, latin1))
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	dic, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for n, line := range strings.Split(decodeDictionary(dic, latin1), "\n") {
+		entry, _, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if _, err := strconv.Atoi(entry); err == nil && n == 0 {
+			continue // the word count
+		}
+		entry = strings.Fields(entry)[0]
+		word, flags, _ := strings.Cut(entry, "/")
+		if word == "" {
+			continue
+		}
+		d.words[word] = append(d.words[word], d.parseFlags(flags)...)
+	}
+	if len(d.words) == 0 {
+		return nil, fmt.Errorf("%s: no words", path)
+	}
+	return d, nil
+}
+
+// affEncoding returns the SET of an .aff file; Hunspell's default is
+// ISO8859-1
+func affEncoding(aff []byte) string {
+	for _, line := range strings.Split(string(aff), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "SET" {
+			return strings.ToUpper(fields[1])
+		}
+	}
+	return "ISO8859-1"
+}
+
+// decodeDictionary returns the text of a dictionary file, converting it
+// from Latin-1 if need be
+func decodeDictionary(data []byte, latin1 bool) string {
+	if !latin1 || utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// parseAff reads the affix rules and the settings checking and suggestions
+// use. Compounding and the other settings are ignored.
+func (d *dictionary) parseAff(aff string) {
+	remaining := make(map[string]int) // rules still to come under each PFX/SFX header
+	crosses := make(map[string]bool)
+	aliasHeader := false
+
+	for _, line := range strings.Split(aff, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "FLAG":
+			d.flagMode = fields[1]
+		case "TRY":
+			d.try = fields[1]
+		case "NEEDAFFIX":
+			d.needAffix = fields[1]
+		case "FORBIDDENWORD":
+			d.forbidden = fields[1]
+		case "REP":
+			if len(fields) >= 3 {
+				d.rep = append(d.rep, [2]string{fields[1], strings.ReplaceAll(fields[2], "_", " ")})
+			}
+		case "AF":
+			if !aliasHeader {
+				aliasHeader = true // "AF count"
+				continue
+			}
+			d.aliases = append(d.aliases, d.splitFlags(fields[1]))
+		case "PFX", "SFX":
+			key := fields[0] + " " + fields[1]
+			if remaining[key] == 0 {
+				if len(fields) >= 4 {
+					crosses[key] = fields[2] == "Y"
+					remaining[key], _ = strconv.Atoi(fields[3])
+				}
+				continue
+			}
+			remaining[key]--
+			if len(fields) < 4 {
+				continue
+			}
+			rule := affixRule{flag: fields[1], prefix: fields[0] == "PFX", cross: crosses[key]}
+			rule.strip, rule.add = fields[2], fields[3]
+			rule.add, _, _ = strings.Cut(rule.add, "/") // continuation flags aren't used
+			if rule.strip == "0" {
+				rule.strip = ""
+			}
+			if rule.add == "0" {
+				rule.add = ""
+			}
+			if len(fields) >= 5 && fields[4] != "." {
+				pattern := "(?:" + fields[4] + ")$"
+				if rule.prefix {
+					pattern = "^(?:" + fields[4] + ")"
+				}
+				cond, err := regexp.Compile(pattern)
+				if err != nil {
+					continue
+				}
+				rule.cond = cond
+			}
+			if rule.prefix {
+				d.prefixes = append(d.prefixes, rule)
+			} else {
+				d.suffixes = append(d.suffixes, rule)
+			}
+		}
+	}
+}
+
+// parseFlags reads the flags after a word's "/": an AF alias number, or
+// flags in the FLAG format
+func (d *dictionary) parseFlags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if len(d.aliases) > 0 {
+		if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= len(d.aliases) {
+			return d.aliases[n-1]
+		}
+	}
+	return d.splitFlags(s)
+}
+
+// splitFlags splits flags written in the FLAG format: single characters,
+// pairs of characters ("long") or comma-separated numbers ("num")
+func (d *dictionary) splitFlags(s string) []string {
+	var flags []string
+	switch d.flagMode {
+	case "long":
+		runes := []rune(s)
+		for i := 0; i+1 < len(runes); i += 2 {
+			flags = append(flags, string(runes[i:i+2]))
+		}
+	case "num":
+		flags = strings.Split(s, ",")
+	default:
+		for _, r := range s {
+			flags = append(flags, string(r))
+		}
+	}
+	return flags
+}
+
+// stemHas reports whether stem is in the dictionary with flag, and isn't
+// forbidden
+func (d *dictionary) stemHas(stem, flag string) bool {
+	flags, ok := d.words[stem]
+	return ok && slices.Contains(flags, flag) && (d.forbidden == "" || !slices.Contains(flags, d.forbidden))
+}
+
+// knows reports whether word is a stem of the dictionary, or a stem with a
+// prefix, a suffix or both
+func (d *dictionary) knows(word string) bool {
+	if flags, ok := d.words[word]; ok {
+		if (d.forbidden == "" || !slices.Contains(flags, d.forbidden)) && (d.needAffix == "" || !slices.Contains(flags, d.needAffix)) {
+			return true
+		}
+	}
+	for _, sfx := range d.suffixes {
+		stem, ok := sfx.remove(word)
+		if !ok {
+			continue
+		}
+		if d.stemHas(stem, sfx.flag) {
+			return true
+		}
+		if !sfx.cross {
+			continue
+		}
+		for _, pfx := range d.prefixes {
+			if root, ok := pfx.remove(stem); ok && pfx.cross && d.stemHas(root, sfx.flag) && d.stemHas(root, pfx.flag) {
+				return true
+			}
+		}
+	}
+	for _, pfx := range d.prefixes {
+		if stem, ok := pfx.remove(word); ok && d.stemHas(stem, pfx.flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove undoes the rule on word, returning the stem it would come from
+func (r affixRule) remove(word string) (string, bool) {
+	if len(word) <= len(r.add) {
+		return "", false
+	}
+	var stem string
+	if r.prefix {
+		rest, ok := strings.CutPrefix(word, r.add)
+		if !ok {
+			return "", false
+		}
+		stem = r.strip + rest
+	} else {
+		rest, ok := strings.CutSuffix(word, r.add)
+		if !ok {
+			return "", false
+		}
+		stem = rest + r.strip
+	}
+	if r.cond != nil && !r.cond.MatchString(stem) {
+		return "", false
+	}
+	return stem, true
+}
+
+// spellChecker checks the prose of a document against a dictionary and the
+// user's and the project's own words. It remembers each line's
+// misspellings by its text, so only lines that changed are checked again.
+type spellChecker struct {
+	dict        *dictionary
+	good        map[string]bool // the user's and the project's words
+	userFile    string
+	projectFile string // "" when the project has no word list
+	known       map[string]bool
+	lines       map[string][]misspelling
+}
+
+// newSpellChecker loads the dictionary the config asks for and the word
+// lists for file
+func newSpellChecker(config Config, file string) (*spellChecker, error) {
+	path, err := findDictionary(config)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := loadDictionary(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &spellChecker{dict: dict}
+	return s, s.loadWords(file)
+}
+
+// userWordsPath is the personal word list, ~/.config/hani/spelling
+func userWordsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "hani", "spelling"), nil
+}
+
+// projectWordsPath returns the nearest .spelling in file's directory or
+// above, or "" if there is none
+func projectWordsPath(file string) string {
+	dir := "."
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, spellProjectName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadWords reads the user's word list and the project's for file, which
+// has just been opened, and forgets what was checked before
+func (s *spellChecker) loadWords(file string) error {
+	s.good = make(map[string]bool)
+	s.known = make(map[string]bool)
+	s.lines = nil
+	s.projectFile = projectWordsPath(file)
+
+	var err error
+	if s.userFile, err = userWordsPath(); err != nil {
+		return err
+	}
+	for _, path := range []string{s.userFile, s.projectFile} {
+		if path == "" {
+			continue
+		}
+		if err := readWordList(path, s.good); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWordList adds the words of a word list to words, skipping blank lines
+// and "#" comments
+func readWordList(path string, words map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" && !strings.HasPrefix(word, "#") {
+			words[word] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// addWord adds word to the project's word list, or to the user's when the
+// project has none, and returns the list it went to
+func (s *spellChecker) addWord(word string) (string, error) {
+	path := s.projectFile
+	if path == "" {
+		path = s.userFile
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return path, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return path, err
+	}
+	if _, err := fmt.Fprintln(file, word); err != nil {
+		file.Close()
+		return path, err
+	}
+	if err := file.Close(); err != nil {
+		return path, err
+	}
+
+	s.good[word] = true
+	s.known = make(map[string]bool)
+	s.lines = nil
+	return path, nil
+}
+
+// correct reports whether word is spelled right. A capitalized word may be
+// a lower-case one starting a sentence.
+func (s *spellChecker) correct(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	if ok, seen := s.known[word]; seen {
+		return ok
+	}
+	ok := s.good[word] || s.dict.knows(word)
+	if !ok {
+		first, size := utf8.DecodeRuneInString(word)
+		if lower := string(unicode.ToLower(first)) + word[size:]; lower != word {
+			ok = s.good[lower] || s.dict.knows(lower)
+		}
+	}
+	s.known[word] = ok
+	return ok
+}
+
+// check returns the misspellings in content outside code blocks and front
+// matter, checking only the lines it hasn't seen before
+func (s *spellChecker) check(content []string, doc *docStructure) []misspelling {
+	lines := make(map[string][]misspelling, len(s.lines))
+	var found []misspelling
+	for i, line := range content {
+		if strings.TrimSpace(line) == "" || inCodeOrFrontMatter(doc, i) {
+			continue
+		}
+		words, seen := s.lines[line]
+		if !seen {
+			words = s.lineMisspellings(line)
+		}
+		lines[line] = words
+		for _, word := range words {
+			word.Line = i
+			found = append(found, word)
+		}
+	}
+	s.lines = lines
+	return found
+}
+
+// lineMisspellings finds the misspelled words of a line of prose. Code
+// spans, URLs, link destinations and HTML are skipped, and so are words
+// that look like code or abbreviations: in a token with digits, slashes,
+// underscores, "@" or an inner ".", or in camelCase or all capitals.
+func (s *spellChecker) lineMisspellings(line string) []misspelling {
+	if linkDefRe.MatchString(line) {
+		return nil
+	}
+	masked := []byte(line)
+	for _, re := range []*regexp.Regexp{inlineCodeSpanRe, htmlCommentRe, linkAutoRe, linkBareRe, inlineHTMLRe, spellSkipRe} {
+		for _, span := range re.FindAllIndex(masked, -1) {
+			for j := span[0]; j < span[1]; j++ {
+				masked[j] = ' '
+			}
+		}
+	}
+
+	var found []misspelling
+	for _, m := range spellWordRe.FindAllIndex(masked, -1) {
+		word := line[m[0]:m[1]]
+		if utf8.RuneCountInString(word) < 2 || !spellCheckable(word) || codeLike(masked, m[0], m[1]) {
+			continue
+		}
+		if !s.correct(word) {
+			found = append(found, misspelling{Col: m[0], End: m[1], Word: word})
+		}
+	}
+	return found
+}
+
+// spellCheckable reports whether word is written in lower case, or
+// capitalized; acronyms and camelCase names are left alone
+func spellCheckable(word string) bool {
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// codeLike reports whether the word at bytes start to end of line is part
+// of a token that looks like a path, an identifier, an e-mail address or an
+// abbreviation
+func codeLike(line []byte, start, end int) bool {
+	for start > 0 && line[start-1] != ' ' && line[start-1] != '\t' {
+		start--
+	}
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		end++
+	}
+	token := strings.TrimRight(string(line[start:end]), `.,;:!?)]}"'*_`)
+	if strings.ContainsAny(token, "0123456789/\\_@=#$%&+~^<>|") {
+		return true
+	}
+	for i := 1; i+1 < len(token); i++ {
+		if token[i] == '.' && token[i+1] != '.' && token[i-1] != '.' {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest offers spellings for word, the likeliest first: the dictionary's
+// REP replacements, then words one edit away, then word split in two
+func (s *spellChecker) suggest(word string) []string {
+	word = strings.ReplaceAll(word, "’", "'")
+	runes := []rune(strings.ToLower(word))
+	title := word != strings.ToLower(word)
+
+	var suggestions []string
+	seen := map[string]bool{string(runes): true}
+	add := func(candidate string) {
+		if len(suggestions) >= spellMaxSuggestions || seen[candidate] {
+			return
+		}
+		seen[candidate] = true
+		for _, part := range strings.Fields(candidate) {
+			if !s.correct(part) {
+				return
+			}
+		}
+		if title {
+			first, size := utf8.DecodeRuneInString(candidate)
+			candidate = string(unicode.ToUpper(first)) + candidate[size:]
+		}
+		suggestions = append(suggestions, candidate)
+	}
+
+	lower := string(runes)
+	for _, rep := range s.dict.rep {
+		for i := 0; ; {
+			at := strings.Index(lower[i:], rep[0])
+			if at < 0 {
+				break
+			}
+			at += i
+			add(lower[:at] + rep[1] + lower[at+len(rep[0]):])
+			i = at + 1
+		}
+	}
+
+	letters := s.dict.try
+	if letters == "" {
+		letters = "esianrtolcdugmphbyfvkwzxjq'"
+	}
+	alphabet := []rune(strings.ToLower(letters))
+	edit := func(parts ...[]rune) string { return string(slices.Concat(parts...)) }
+	for i := 0; i+1 < len(runes); i++ { // swapped letters
+		add(edit(runes[:i], []rune{runes[i+1], runes[i]}, runes[i+2:]))
+	}
+	for i := range runes { // an extra letter
+		add(edit(runes[:i], runes[i+1:]))
+	}
+	for i := 0; i <= len(runes); i++ { // a missing letter
+		for _, r := range alphabet {
+			add(edit(runes[:i], []rune{r}, runes[i:]))
+		}
+	}
+	for i := range runes { // a wrong letter
+		for _, r := range alphabet {
+			if r != runes[i] {
+				add(edit(runes[:i], []rune{r}, runes[i+1:]))
+			}
+		}
+	}
+	for i := 1; i < len(runes); i++ { // a missing space
+		add(edit(runes[:i], []rune{' '}, runes[i:]))
+	}
+	return suggestions
+}
+
+// wordAt returns the word of line at byte col and where it starts and
+// ends
+func wordAt(line string, col int) (word string, start, end int, ok bool) {
+	for _, m := range spellWordRe.FindAllStringIndex(line, -1) {
+		if m[0] <= col && col < m[1] {
+			return line[m[0]:m[1]], m[0], m[1], true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// nextMisspelling returns the misspelling after pos (dir > 0) or before it,
+// wrapping around the end of the document, for ]s and [s
+func nextMisspelling(found []misspelling, pos Position, dir int) (misspelling, bool) {
+	if len(found) == 0 {
+		return misspelling{}, false
+	}
+	if dir > 0 {
+		for _, m := range found {
+			if m.Line > pos.row || (m.Line == pos.row && m.Col > pos.col) {
+				return m, true
+			}
+		}
+		return found[0], true
+	}
+	for i := len(found) - 1; i >= 0; i-- {
+		m := found[i]
+		if m.Line < pos.row || (m.Line == pos.row && m.Col < pos.col) {
+			return m, true
+		}
+	}
+	return found[len(found)-1], true
+}
+
+// misspellingSpans returns the columns [start, end) of line's misspellings
+func misspellingSpans(found []misspelling, line int) [][2]int {
+	var spans [][2]int
+	for _, m := range found {
+		if m.Line == line {
+			spans = append(spans, [2]int{m.Col, m.End})
+		}
+	}
+	return spans
+}
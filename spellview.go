@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var spellUnderlineStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#FF5555")).
+	Underline(true)
+
+// loadSpell loads the dictionary when spell checking is on, reads the word
+// lists for the buffer's file and checks the buffer
+func (m *Model) loadSpell() {
+	if !m.config.Spell {
+		m.spell, m.misspellings = nil, nil
+		return
+	}
+	if m.spell == nil {
+		spell, err := newSpellChecker(m.config, m.filename)
+		if spell == nil {
+			m.setStatusMsg("Spell: "+err.Error(), true)
+			m.config.Spell = false
+			return
+		}
+		m.spell = spell
+		if err != nil {
+			m.setStatusMsg("Spell: "+err.Error(), true)
+		}
+	} else if err := m.spell.loadWords(m.filename); err != nil {
+		m.setStatusMsg("Spell: "+err.Error(), true)
+	}
+	m.respell()
+}
+
+// respell brings the misspellings in line with the buffer, checking the
+// lines that changed
+func (m *Model) respell() {
+	if m.spell != nil {
+		m.misspellings = m.spell.check(m.content, m.doc)
+	}
+}
+
+// gotoMisspelling implements ]s and [s
+func (m Model) gotoMisspelling(dir int) (tea.Model, tea.Cmd) {
+	if m.spell == nil {
+		m.setStatusMsg("Spell checking is off (:set spell)", true)
+		return m, nil
+	}
+	found, ok := nextMisspelling(m.misspellings, m.cursor, dir)
+	if !ok {
+		m.setStatusMsg("No misspelled words", false)
+		return m, nil
+	}
+	m.cursor = Position{row: found.Line, col: found.Col}
+	m.adjustViewport()
+	return m, nil
+}
+
+// suggestSpelling implements z=, which opens the suggestions for the word
+// under the cursor
+func (m Model) suggestSpelling() (tea.Model, tea.Cmd) {
+	if m.spell == nil {
+		m.setStatusMsg("Spell checking is off (:set spell)", true)
+		return m, nil
+	}
+	word, start, end, ok := wordAt(m.content[m.cursor.row], m.cursor.col)
+	if !ok {
+		m.setStatusMsg("No word under the cursor", true)
+		return m, nil
+	}
+	suggestions := m.spell.suggest(word)
+	if len(suggestions) == 0 {
+		m.setStatusMsg(fmt.Sprintf("No suggestions for %q", word), false)
+		return m, nil
+	}
+	m.spellPopup = spellPopup{
+		open:        true,
+		word:        misspelling{Line: m.cursor.row, Col: start, End: end, Word: word},
+		suggestions: suggestions,
+	}
+	return m, nil
+}
+
+// goodWord implements zg and ":spellgood [WORD]", which add the word under
+// the cursor, or WORD, to the project's or the user's word list
+func (m Model) goodWord(args []string) (tea.Model, tea.Cmd) {
+	if m.spell == nil {
+		m.setStatusMsg("Spell checking is off (:set spell)", true)
+		return m, nil
+	}
+	word := strings.Join(args, " ")
+	if word == "" {
+		var ok bool
+		if word, _, _, ok = wordAt(m.content[m.cursor.row], m.cursor.col); !ok {
+			m.setStatusMsg("No word under the cursor", true)
+			return m, nil
+		}
+	}
+	path, err := m.spell.addWord(word)
+	if err != nil {
+		m.setStatusMsg("Spell: "+err.Error(), true)
+		return m, nil
+	}
+	m.respell()
+	m.setStatusMsg(fmt.Sprintf("Added %q to %s", word, path), false)
+	return m, nil
+}
+
+// handleSpellPopup chooses a suggestion with j/k and Enter, or 1-9
+func (m Model) handleSpellPopup(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.spellPopup
+	switch key := msg.String(); key {
+	case "j", "down", "ctrl+n":
+		p.sel = min(p.sel+1, len(p.suggestions)-1)
+	case "k", "up", "ctrl+p":
+		p.sel = max(p.sel-1, 0)
+	case "enter":
+		return m.replaceMisspelling(p.suggestions[p.sel])
+	case "esc", "q":
+		p.open = false
+	default:
+		if len(key) == 1 && key[0] >= '1' && int(key[0]-'0') <= len(p.suggestions) {
+			return m.replaceMisspelling(p.suggestions[key[0]-'1'])
+		}
+	}
+	return m, nil
+}
+
+// replaceMisspelling puts the chosen suggestion in place of the word and
+// closes the popup
+func (m Model) replaceMisspelling(suggestion string) (tea.Model, tea.Cmd) {
+	w := m.spellPopup.word
+	m.spellPopup = spellPopup{}
+	line := m.content[w.Line]
+	if w.End > len(line) || line[w.Col:w.End] != w.Word {
+		m.setStatusMsg("The word has changed", true)
+		return m, nil
+	}
+	m.content[w.Line] = line[:w.Col] + suggestion + line[w.End:]
+	m.cursor = Position{row: w.Line, col: w.Col}
+	m.saved = false
+	m.adjustViewport()
+	return m, nil
+}
+
+// drawSpellPopup draws the suggestions, numbered, under the word
+func (m Model) drawSpellPopup(lines []string) {
+	p := m.spellPopup
+	items := make([]string, len(p.suggestions))
+	for i, s := range p.suggestions {
+		items[i] = fmt.Sprintf("%d %s", i+1, s)
+	}
+	m.drawPopup(lines, items, p.sel, p.word.Line, p.word.Col)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// wordsPerMinute is the reading speed behind reading time estimates
+const wordsPerMinute = 200
+
+// htmlCommentRe matches HTML comments, such as the table of contents
+// markers, which readers never see
+var htmlCommentRe = regexp.MustCompile(`<!--.*?-->`)
+
+// textCounts counts the words and characters a reader sees
+type textCounts struct {
+	words int
+	chars int // characters of the text, spaces between words included
+}
+
+// readingMinutes estimates how many minutes words take to read, rounding up
+func readingMinutes(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// readingTime is the reading time as the status bar shows it, e.g. "4 min"
+func readingTime(words int) string {
+	return fmt.Sprintf("%d min", readingMinutes(words))
+}
+
+// proseText returns the text a reader sees on line i: no code, front
+// matter, link definitions or table delimiters, and no Markdown syntax
+func proseText(content []string, doc *docStructure, i int) string {
+	line := content[i]
+	if strings.TrimSpace(line) == "" || inCodeOrFrontMatter(doc, i) || linkDefRe.MatchString(line) ||
+		thematicBreakRe.MatchString(line) || setextUnderlineRe.MatchString(line) || tableDelimiterRe.MatchString(line) {
+		return ""
+	}
+	if match := atxHeadingRe.FindStringSubmatch(line); match != nil {
+		line = match[2]
+	} else if item, ok := parseListItem(line); ok {
+		line = line[item.length:]
+	}
+	if isTableRow(line) {
+		var cells []string
+		for _, cell := range splitTableRow(line) {
+			cells = append(cells, cell.text)
+		}
+		line = strings.Join(cells, " ")
+	}
+	return stripInlineMarkdown(htmlCommentRe.ReplaceAllString(line, ""))
+}
+
+// countText counts the words and characters on lines from to to
+// (inclusive)
+func countText(content []string, doc *docStructure, from, to int) textCounts {
+	var counts textCounts
+	for i := max(0, from); i <= to && i < len(content); i++ {
+		words := strings.Fields(proseText(content, doc, i))
+		counts.words += len(words)
+		for _, word := range words {
+			counts.chars += utf8.RuneCountInString(word)
+		}
+		counts.chars += max(0, len(words)-1)
+	}
+	return counts
+}
+
+// countDocument counts the words and characters of all of content
+func countDocument(content []string) textCounts {
+	doc := newDocStructure()
+	doc.update(content)
+	return countText(content, doc, 0, len(content)-1)
+}
+
+// docStats describes a document for ":stats" and `hani stats`
+type docStats struct {
+	File           string         `json:"file,omitempty"`
+	Words          int            `json:"words"`
+	Characters     int            `json:"characters"`
+	ReadingMinutes int            `json:"readingMinutes"`
+	Headings       int            `json:"headings"`
+	Links          int            `json:"links"`
+	Images         int            `json:"images"`
+	CodeBlocks     int            `json:"codeBlocks"`
+	Sentences      int            `json:"sentences"`
+	AverageWords   float64        `json:"averageSentenceWords"`
+	LongestWords   int            `json:"longestSentenceWords"`
+	Sections       []sectionStats `json:"sections"`
+}
+
+// sectionStats counts the text under one heading, up to the next heading
+// of any level. Text before the first heading has no heading.
+type sectionStats struct {
+	Heading string `json:"heading"`
+	Level   int    `json:"level"`
+	Line    int    `json:"line"` // 1-based
+	Words   int    `json:"words"`
+	Chars   int    `json:"characters"`
+}
+
+// documentStats counts content's words, sections, links, images, code
+// blocks and sentences
+func documentStats(content []string) docStats {
+	doc := newDocStructure()
+	doc.update(content)
+
+	total := countText(content, doc, 0, len(content)-1)
+	stats := docStats{
+		Words:          total.words,
+		Characters:     total.chars,
+		ReadingMinutes: readingMinutes(total.words),
+		Headings:       len(doc.headings),
+		CodeBlocks:     len(doc.codeBlocks),
+		Sections:       []sectionStats{},
+	}
+
+	// Sections run from one heading to the next
+	starts := []int{0}
+	for _, h := range doc.headings {
+		if h.Line > 0 {
+			starts = append(starts, h.Line)
+		}
+	}
+	for n, start := range starts {
+		end := len(content) - 1
+		if n+1 < len(starts) {
+			end = starts[n+1] - 1
+		}
+		section := sectionStats{Line: start + 1}
+		if i := doc.sectionAt(start); i >= 0 && doc.headings[i].Line == start {
+			section.Heading = doc.headings[i].Title()
+			section.Level = doc.headings[i].Level
+		}
+		counts := countText(content, doc, start, end)
+		section.Words, section.Chars = counts.words, counts.chars
+		if section.Level == 0 && counts.words == 0 {
+			continue // nothing before the first heading
+		}
+		stats.Sections = append(stats.Sections, section)
+	}
+
+	for _, link := range documentLinks(content) {
+		switch {
+		case link.def:
+		case strings.HasPrefix(content[link.line][link.start:], "!"):
+			stats.Images++
+		default:
+			stats.Links++
+		}
+	}
+
+	lengths := sentenceLengths(content, doc)
+	stats.Sentences = len(lengths)
+	for _, n := range lengths {
+		stats.LongestWords = max(stats.LongestWords, n)
+		stats.AverageWords += float64(n)
+	}
+	if len(lengths) > 0 {
+		stats.AverageWords /= float64(len(lengths))
+	}
+	return stats
+}
+
+// sentenceLengths returns the number of words in each sentence of the
+// document's paragraphs and list items. A sentence ends at a word ending
+// in ".", "!" or "?", or at the end of its paragraph; headings and tables
+// have no sentences.
+func sentenceLengths(content []string, doc *docStructure) []int {
+	var lengths []int
+	words := 0
+	end := func() {
+		if words > 0 {
+			lengths = append(lengths, words)
+		}
+		words = 0
+	}
+
+	headings := make(map[int]bool, len(doc.headings))
+	for _, h := range doc.headings {
+		headings[h.Line] = true
+	}
+
+	for i, line := range content {
+		text := ""
+		if !headings[i] && !isTableRow(line) {
+			text = proseText(content, doc, i)
+		}
+		if _, isItem := parseListItem(line); isItem || text == "" {
+			end()
+		}
+		for _, word := range strings.Fields(text) {
+			words++
+			if endsSentence(word) {
+				end()
+			}
+		}
+	}
+	end()
+	return lengths
+}
+
+// endsSentence reports whether word ends in a full stop, question mark or
+// exclamation mark, before any closing quotes, brackets or emphasis
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, `)]"'*_`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?")
+}
+
+// runStats implements `hani stats`, which prints the statistics of
+// documents as a table or, for other tools, as JSON
+func runStats(args []string) int {
+	fs := newSubcommandFlags("stats", "hani stats [--json] FILE...|-")
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	all := []docStats{}
+	for _, file := range files {
+		source, err := readDocument(file)
+		if err != nil {
+			status = fatalf("%v", err)
+			continue
+		}
+		stats := documentStats(strings.Split(string(source), "\n"))
+		stats.File = file
+		all = append(all, stats)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(all, "", "  ")
+		fmt.Println(string(out))
+		return status
+	}
+
+	for n, stats := range all {
+		if n > 0 {
+			fmt.Println()
+		}
+		fmt.Print(stats.String())
+	}
+	return status
+}
+
+// String lays the statistics out for reading: the totals, then a line for
+// each section
+func (s docStats) String() string {
+	var b strings.Builder
+	if s.File != "" {
+		fmt.Fprintf(&b, "%s\n", s.File)
+	}
+	fmt.Fprintf(&b, "  %d words, %d characters, %s read\n", s.Words, s.Characters, readingTime(s.Words))
+	fmt.Fprintf(&b, "  %d headings, %d links, %d images, %d code blocks\n", s.Headings, s.Links, s.Images, s.CodeBlocks)
+	fmt.Fprintf(&b, "  %d sentences, %.1f words on average, longest %d\n", s.Sentences, s.AverageWords, s.LongestWords)
+	for _, section := range s.Sections {
+		fmt.Fprintf(&b, "  %6d  %s\n", section.Words, section.title())
+	}
+	return b.String()
+}
+
+// title is the section's heading indented by its level, as the lists show
+// it
+func (s sectionStats) title() string {
+	if s.Level == 0 {
+		return "(before the first heading)"
+	}
+	return strings.Repeat("  ", s.Level-1) + s.Heading
+}
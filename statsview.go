@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// statsView shows the buffer's statistics in place of the editor, with the
+// word count of each section
+type statsView struct {
+	open  bool
+	stats docStats // counted when the view opened
+	sel   int
+}
+
+// recheck brings the structure index up to date and, when the buffer has
+// changed, the diagnostics, front matter, misspellings and counts with it
+func (m *Model) recheck() {
+	if m.doc.update(m.content) {
+		m.relint()
+		m.checkFrontMatter()
+		m.respell()
+		m.recount()
+	}
+}
+
+// recount brings the word and character counts in the status bar in line
+// with the buffer, and announces the writing goal when they reach it
+func (m *Model) recount() {
+	m.counts = countText(m.content, m.doc, 0, len(m.content)-1)
+	if m.goal.checkGoal(m.counts.words, m.goalStart) {
+		m.setStatusMsg("🎉 Goal reached: "+m.goal.describe(), false)
+	}
+}
+
+// startWriting starts counting the words written in the buffer, which has
+// just been opened
+func (m *Model) startWriting() {
+	m.goalStart, m.recorded = m.counts.words, m.counts.words
+}
+
+// recordWriting adds the words written since the last save to the history
+func (m *Model) recordWriting() error {
+	words := countDocument(m.content).words // formatting on save may have run since Update
+	err := recordWriting(m.filename, m.recorded, words, m.goal.reached, time.Now())
+	m.recorded = words
+	return err
+}
+
+// goalCommand implements ":goal [session] WORDS", ":goal off" and ":goal",
+// which shows the progress
+func (m Model) goalCommand(args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		if m.goal.target == 0 {
+			m.setStatusMsg("No goal (:goal WORDS or :goal session WORDS sets one)", false)
+		} else {
+			m.setStatusMsg("Goal: "+m.goal.describe()+", "+m.goal.bar(m.counts.words, m.goalStart), false)
+		}
+		return m, nil
+	}
+
+	goal, err := parseGoal(args)
+	if err != nil {
+		m.setStatusMsg("Goal: "+err.Error(), true)
+		return m, nil
+	}
+	m.goal = goal
+	switch {
+	case goal.target == 0:
+		m.setStatusMsg("Goal cleared", false)
+	case !m.goal.checkGoal(m.counts.words, m.goalStart):
+		m.setStatusMsg("Goal: "+goal.describe(), false)
+	default:
+		m.setStatusMsg("🎉 Goal reached: "+goal.describe(), false)
+	}
+	return m, nil
+}
+
+// goalsCommand implements ":goals", which lists the words written each day
+// by file, under the writing streak
+func (m Model) goalsCommand() (tea.Model, tea.Cmd) {
+	history, err := loadHistory()
+	if err != nil {
+		m.setStatusMsg("Goals: "+err.Error(), true)
+		return m, nil
+	}
+	totals := history.totals()
+	current, best := streaks(totals, time.Now())
+
+	var entries []location
+	for _, total := range totals {
+		for _, file := range total.files {
+			day := history.Files[file][total.date]
+			text := fmt.Sprintf("%s  %6d words", total.date, day.written())
+			if day.Reached {
+				text += "  ✓ goal"
+			}
+			entries = append(entries, location{file: file, text: text})
+		}
+	}
+	if len(entries) == 0 {
+		m.setStatusMsg("No writing recorded yet (words are counted when you save)", false)
+		return m, nil
+	}
+
+	today := 0
+	if len(totals) > 0 && totals[0].date == time.Now().Format(time.DateOnly) {
+		today = totals[0].words
+	}
+	m.tasks = taskView{}
+	m.stats.open = false
+	m.locations = locationList{
+		open:    true,
+		title:   fmt.Sprintf("Writing streak: %d days (best %d), %d words today", current, best, today),
+		entries: entries,
+	}
+	return m, nil
+}
+
+// statsCommand implements ":stats"
+func (m Model) statsCommand() (tea.Model, tea.Cmd) {
+	m.tasks = taskView{}
+	m.locations.open = false
+	m.stats = statsView{open: true, stats: documentStats(m.content)}
+
+	// Start on the section the cursor is in
+	for i, section := range m.stats.stats.Sections {
+		if section.Line-1 <= m.cursor.row {
+			m.stats.sel = i
+		}
+	}
+	return m, nil
+}
+
+// handleStatsViewMode moves the selection and jumps to the selected
+// section
+func (m Model) handleStatsViewMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	sections := m.stats.stats.Sections
+
+	switch msg.String() {
+	case "j", "down":
+		if m.stats.sel < len(sections)-1 {
+			m.stats.sel++
+		}
+
+	case "k", "up":
+		if m.stats.sel > 0 {
+			m.stats.sel--
+		}
+
+	case "g", "home":
+		m.stats.sel = 0
+
+	case "G", "end":
+		m.stats.sel = max(0, len(sections)-1)
+
+	case "enter":
+		if len(sections) > 0 {
+			jumped, _ := m.jumpToLocation("", sections[m.stats.sel].Line-1)
+			jumped.stats.open = false
+			return jumped, nil
+		}
+
+	case "esc", "q":
+		m.stats.open = false
+
+	case ":":
+		m.mode = ModeCommand
+		m.commandLine = ""
+	}
+	return m, nil
+}
+
+// renderStatsView draws the totals and below them the sections, each with
+// its word count
+func (m Model) renderStatsView(height int) string {
+	s := m.stats.stats
+	name := m.filename
+	if name == "" {
+		name = "this buffer"
+	}
+	summary := []string{
+		keyStyle.Render(ansi.Truncate("Statistics for "+name, m.width, "…")),
+		fmt.Sprintf("%d words, %d characters, %s read", s.Words, s.Characters, readingTime(s.Words)),
+		fmt.Sprintf("%d headings, %d links, %d images, %d code blocks", s.Headings, s.Links, s.Images, s.CodeBlocks),
+		fmt.Sprintf("%d sentences, %.1f words on average, the longest %d", s.Sentences, s.AverageWords, s.LongestWords),
+		"",
+	}
+	if height <= len(summary) {
+		return strings.Join(summary[:height], "\n")
+	}
+
+	rows := make([]listRow, len(s.Sections))
+	for i, section := range s.Sections {
+		rows[i] = listRow{
+			text:     section.title(),
+			location: fmt.Sprintf("%6d words  %5s", section.Words, lineLocation("", section.Line-1)),
+		}
+	}
+	title := fmt.Sprintf("Sections: %d", len(rows))
+	return strings.Join(summary, "\n") + "\n" + renderList(title, rows, m.stats.sel, m.width, height-len(summary))
+}
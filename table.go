@@ -0,0 +1,502 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tableAlign is a column's alignment, set by the colons in the delimiter row
+type tableAlign int
+
+const (
+	alignNone tableAlign = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+var (
+	tableDelimiterRe = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	errNotInTable    = errors.New("not in a table")
+)
+
+// tableCell is one cell of a table row as written: its text, trimmed, and
+// where the cell and its text start in the line
+type tableCell struct {
+	text  string
+	from  int // byte offset just past the pipe before the cell
+	start int // byte offset of text
+}
+
+// splitTableRow splits a pipe table row into cells. Leading and trailing
+// pipes are optional and "\|" is a literal pipe, as in GFM.
+func splitTableRow(line string) []tableCell {
+	i := len(line) - len(strings.TrimLeft(line, " \t"))
+	if i < len(line) && line[i] == '|' {
+		i++
+	}
+
+	var cells []tableCell
+	segment := i
+	closed := false
+	add := func(end int) {
+		raw := line[segment:end]
+		lead := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		cells = append(cells, tableCell{text: strings.TrimSpace(raw), from: segment, start: segment + lead})
+	}
+	for j := i; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case '|':
+			add(j)
+			segment = j + 1
+			closed = strings.TrimSpace(line[segment:]) == ""
+		}
+	}
+	if !closed {
+		add(len(line))
+	}
+	return cells
+}
+
+// isTableRow reports whether line contains an unescaped pipe
+func isTableRow(line string) bool {
+	return strings.Contains(strings.ReplaceAll(line, `\|`, ""), "|")
+}
+
+// mdTable is a GFM pipe table parsed out of the buffer
+type mdTable struct {
+	start  int // line of the header row
+	end    int // last line of the table
+	indent string
+	rows   [][]string // header first; the delimiter row is kept in aligns
+	aligns []tableAlign
+}
+
+// findTable parses the table around line row: a header row, a delimiter
+// row and any number of body rows
+func findTable(content []string, row int) (*mdTable, bool) {
+	if row < 0 || row >= len(content) || !isTableRow(content[row]) {
+		return nil, false
+	}
+
+	start, end := row, row
+	for start > 0 && isTableRow(content[start-1]) {
+		start--
+	}
+	for end < len(content)-1 && isTableRow(content[end+1]) {
+		end++
+	}
+
+	// The header is the line above the delimiter row; pipe lines before it
+	// are paragraph text, and everything after it belongs to the table
+	header := -1
+	for i := start + 1; i <= min(row+1, end); i++ {
+		if tableDelimiterRe.MatchString(content[i]) && len(splitTableRow(content[i])) == len(splitTableRow(content[i-1])) {
+			header = i - 1
+			break
+		}
+	}
+	if header < 0 || row < header {
+		return nil, false
+	}
+	start = header
+
+	delimiter := splitTableRow(content[start+1])
+	t := &mdTable{
+		start:  start,
+		end:    end,
+		indent: content[start][:len(content[start])-len(strings.TrimLeft(content[start], " \t"))],
+	}
+	for _, cell := range delimiter {
+		t.aligns = append(t.aligns, parseTableAlign(cell.text))
+	}
+	for i := start; i <= end; i++ {
+		if i == start+1 {
+			continue
+		}
+		var cells []string
+		for _, cell := range splitTableRow(content[i]) {
+			cells = append(cells, cell.text)
+		}
+		t.rows = append(t.rows, cells)
+	}
+
+	// Cells past the header's count are kept as extra columns rather than lost
+	columns := len(t.aligns)
+	for _, row := range t.rows {
+		columns = max(columns, len(row))
+	}
+	for len(t.aligns) < columns {
+		t.aligns = append(t.aligns, alignNone)
+	}
+	for i := range t.rows {
+		for len(t.rows[i]) < columns {
+			t.rows[i] = append(t.rows[i], "")
+		}
+	}
+	return t, true
+}
+
+func parseTableAlign(delimiter string) tableAlign {
+	left, right := strings.HasPrefix(delimiter, ":"), strings.HasSuffix(delimiter, ":")
+	switch {
+	case left && right:
+		return alignCenter
+	case right:
+		return alignRight
+	case left:
+		return alignLeft
+	}
+	return alignNone
+}
+
+// line returns the buffer line of table row r; r of -1 is the delimiter row
+func (t *mdTable) line(r int) int {
+	switch {
+	case r < 0:
+		return t.start + 1
+	case r == 0:
+		return t.start
+	}
+	return t.start + r + 1
+}
+
+// cellAt finds the cell at pos: its row (-1 on the delimiter row), column,
+// and the cursor's offset into the cell's text
+func (t *mdTable) cellAt(content []string, pos Position) (r, c, offset int) {
+	r = pos.row - t.start - 1
+	switch {
+	case pos.row == t.start:
+		r = 0
+	case pos.row == t.start+1:
+		r = -1
+	}
+
+	cells := splitTableRow(content[pos.row])
+	for i, cell := range cells {
+		if pos.col >= cell.from {
+			c = i
+		}
+	}
+	c = min(c, len(t.aligns)-1)
+	if c < len(cells) {
+		offset = max(0, min(pos.col-cells[c].start, len(cells[c].text)))
+	}
+	return r, c, offset
+}
+
+// render lays the table out with every column padded to its widest cell,
+// measured in display width so wide characters line up. It returns the
+// lines, the delimiter row second, and where each cell's text starts.
+func (t *mdTable) render() (lines []string, starts [][]int) {
+	widths := make([]int, len(t.aligns))
+	for c := range widths {
+		widths[c] = 3
+		for _, row := range t.rows {
+			widths[c] = max(widths[c], ansi.StringWidth(row[c]))
+		}
+	}
+
+	renderRow := func(cells []string) (string, []int) {
+		var b strings.Builder
+		cellStarts := make([]int, len(cells))
+		b.WriteString(t.indent + "|")
+		for c, text := range cells {
+			gap := widths[c] - ansi.StringWidth(text)
+			left := 0
+			switch t.aligns[c] {
+			case alignRight:
+				left = gap
+			case alignCenter:
+				left = gap / 2
+			}
+			b.WriteString(" " + strings.Repeat(" ", left))
+			cellStarts[c] = b.Len()
+			b.WriteString(text + strings.Repeat(" ", gap-left) + " |")
+		}
+		return b.String(), cellStarts
+	}
+
+	delimiter := make([]string, len(t.aligns))
+	for c, align := range t.aligns {
+		dashes := widths[c]
+		switch align {
+		case alignLeft:
+			delimiter[c] = ":" + strings.Repeat("-", dashes-1)
+		case alignRight:
+			delimiter[c] = strings.Repeat("-", dashes-1) + ":"
+		case alignCenter:
+			delimiter[c] = ":" + strings.Repeat("-", dashes-2) + ":"
+		default:
+			delimiter[c] = strings.Repeat("-", dashes)
+		}
+	}
+
+	for r, row := range t.rows {
+		line, cellStarts := renderRow(row)
+		lines = append(lines, line)
+		starts = append(starts, cellStarts)
+		if r == 0 {
+			line, cellStarts = renderRow(delimiter) // already full width, so never padded
+			lines = append(lines, line)
+			starts = append(starts, cellStarts)
+		}
+	}
+	return lines, starts
+}
+
+// renderPlain lays the table out as plain text for the text export: cells
+// without their Markdown, columns as narrow as their widest cell and a rule
+// under the header
+func (t *mdTable) renderPlain() []string {
+	rows := make([][]string, len(t.rows))
+	widths := make([]int, len(t.aligns))
+	for r, row := range t.rows {
+		for c, text := range row {
+			text = stripInlineMarkdown(strings.ReplaceAll(text, `\|`, "|"))
+			rows[r] = append(rows[r], text)
+			widths[c] = max(widths[c], ansi.StringWidth(text))
+		}
+	}
+
+	var lines []string
+	for r, row := range rows {
+		cells := make([]string, len(row))
+		for c, text := range row {
+			gap := widths[c] - ansi.StringWidth(text)
+			left := 0
+			switch t.aligns[c] {
+			case alignRight:
+				left = gap
+			case alignCenter:
+				left = gap / 2
+			}
+			cells[c] = strings.Repeat(" ", left) + text + strings.Repeat(" ", gap-left)
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " | "), " "))
+		if r == 0 {
+			rule := make([]string, len(widths))
+			for c, width := range widths {
+				rule[c] = strings.Repeat("-", width)
+			}
+			lines = append(lines, strings.Join(rule, "-|-"))
+		}
+	}
+	return lines
+}
+
+// apply writes the table back into content and returns the new content
+// with the cursor placed in cell (r, c) at offset
+func (t *mdTable) apply(content []string, r, c, offset int) ([]string, Position) {
+	lines, starts := t.render()
+
+	updated := make([]string, 0, len(content)-(t.end-t.start+1)+len(lines))
+	updated = append(updated, content[:t.start]...)
+	updated = append(updated, lines...)
+	updated = append(updated, content[t.end+1:]...)
+	t.end = t.start + len(lines) - 1
+
+	c = max(0, min(c, len(t.aligns)-1))
+	index := t.line(r) - t.start
+	text := ""
+	if r >= 0 {
+		text = t.rows[r][c]
+	}
+	return updated, Position{row: t.start + index, col: starts[index][c] + min(offset, len(text))}
+}
+
+// formatTableAt realigns the table around pos, keeping the cursor in the
+// same place within its cell. ok is false when pos isn't in a table.
+func formatTableAt(content []string, pos Position) ([]string, Position, bool) {
+	t, found := findTable(content, pos.row)
+	if !found {
+		return content, pos, false
+	}
+	r, c, offset := t.cellAt(content, pos)
+	updated, pos := t.apply(content, r, c, offset)
+	return updated, pos, true
+}
+
+// tableNextCell realigns the table around pos and moves to the start of the
+// next cell (dir 1) or the previous one (dir -1), skipping the delimiter row.
+// Tabbing past the last cell adds a new row.
+func tableNextCell(content []string, pos Position, dir int) ([]string, Position, bool) {
+	t, found := findTable(content, pos.row)
+	if !found {
+		return content, pos, false
+	}
+	r, c, _ := t.cellAt(content, pos)
+	r = max(r, 0)
+
+	c += dir
+	switch {
+	case c >= len(t.aligns):
+		r, c = r+1, 0
+		if r == len(t.rows) {
+			t.insertRow(r)
+		}
+	case c < 0 && r > 0:
+		r, c = r-1, len(t.aligns)-1
+	case c < 0:
+		c = 0
+	}
+	updated, pos := t.apply(content, r, c, 0)
+	return updated, pos, true
+}
+
+func (t *mdTable) insertRow(at int) {
+	t.rows = append(t.rows[:at], append([][]string{make([]string, len(t.aligns))}, t.rows[at:]...)...)
+}
+
+func (t *mdTable) insertColumn(at int) {
+	for i, row := range t.rows {
+		t.rows[i] = append(row[:at], append([]string{""}, row[at:]...)...)
+	}
+	t.aligns = append(t.aligns[:at], append([]tableAlign{alignNone}, t.aligns[at:]...)...)
+}
+
+func (t *mdTable) deleteColumn(c int) {
+	for i, row := range t.rows {
+		t.rows[i] = append(row[:c], row[c+1:]...)
+	}
+	t.aligns = append(t.aligns[:c], t.aligns[c+1:]...)
+}
+
+func (t *mdTable) swapColumns(a, b int) {
+	for _, row := range t.rows {
+		row[a], row[b] = row[b], row[a]
+	}
+	t.aligns[a], t.aligns[b] = t.aligns[b], t.aligns[a]
+}
+
+// sortRows sorts the body rows by column c, numerically when every cell in
+// the column is a number. The header stays on top.
+func (t *mdTable) sortRows(c int, descending bool) {
+	body := t.rows[1:]
+	numeric := true
+	for _, row := range body {
+		if _, err := parseTableNumber(row[c]); err != nil && row[c] != "" {
+			numeric = false
+			break
+		}
+	}
+
+	less := func(a, b string) bool {
+		if numeric {
+			x, _ := parseTableNumber(a)
+			y, _ := parseTableNumber(b)
+			return x < y
+		}
+		return strings.ToLower(stripInlineMarkdown(a)) < strings.ToLower(stripInlineMarkdown(b))
+	}
+	sort.SliceStable(body, func(i, j int) bool {
+		if descending {
+			return less(body[j][c], body[i][c])
+		}
+		return less(body[i][c], body[j][c])
+	})
+}
+
+// parseTableNumber reads a cell such as "1,024", "$3.50" or "75%" as a number
+func parseTableNumber(text string) (float64, error) {
+	text = strings.TrimSpace(stripInlineMarkdown(text))
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "$"), "%")
+	return strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+}
+
+// tableUsage lists the ":table" subcommands
+const tableUsage = "usage is :table format | row add|delete|up|down | col add|delete|left|right | align left|center|right|none | sort [desc]"
+
+// tableCommand implements ":table" on the table at pos. It returns the new
+// content, the cursor position and a status message.
+func tableCommand(args []string, content []string, pos Position) ([]string, Position, string, error) {
+	if len(args) == 0 {
+		return nil, pos, "", errors.New(tableUsage)
+	}
+	t, found := findTable(content, pos.row)
+	if !found {
+		return nil, pos, "", errNotInTable
+	}
+	r, c, offset := t.cellAt(content, pos)
+	body := r > 0 // the header and delimiter rows can't be moved or deleted
+
+	action := ""
+	if len(args) > 1 {
+		action = args[1]
+	}
+	status := ""
+
+	switch args[0] + " " + action {
+	case "format ":
+		status = "Table formatted"
+
+	case "row add":
+		r, offset = max(r, 0)+1, 0
+		t.insertRow(r)
+		status = "Row added"
+	case "row delete":
+		if !body {
+			return nil, pos, "", errors.New("the header row can't be deleted")
+		}
+		t.rows = append(t.rows[:r], t.rows[r+1:]...)
+		r = min(r, len(t.rows)-1)
+		status = "Row deleted"
+	case "row up", "row down":
+		other := r + 1
+		if action == "up" {
+			other = r - 1
+		}
+		if !body || other < 1 || other >= len(t.rows) {
+			return nil, pos, "", fmt.Errorf("can't move the row %s", action)
+		}
+		t.rows[r], t.rows[other] = t.rows[other], t.rows[r]
+		r = other
+		status = "Row moved " + action
+
+	case "col add":
+		c, offset = c+1, 0
+		t.insertColumn(c)
+		status = "Column added"
+	case "col delete":
+		if len(t.aligns) == 1 {
+			return nil, pos, "", errors.New("a table needs at least one column")
+		}
+		t.deleteColumn(c)
+		offset = 0
+		status = "Column deleted"
+	case "col left", "col right":
+		other := c + 1
+		if action == "left" {
+			other = c - 1
+		}
+		if other < 0 || other >= len(t.aligns) {
+			return nil, pos, "", fmt.Errorf("can't move the column %s", action)
+		}
+		t.swapColumns(c, other)
+		c = other
+		status = "Column moved " + action
+
+	case "align left", "align center", "align right", "align none":
+		t.aligns[c] = map[string]tableAlign{"left": alignLeft, "center": alignCenter, "right": alignRight, "none": alignNone}[action]
+		status = "Column aligned " + action
+
+	case "sort ", "sort desc":
+		t.sortRows(c, action == "desc")
+		r, offset = 0, 0
+		status = "Rows sorted by column " + strconv.Itoa(c+1)
+
+	default:
+		return nil, pos, "", errors.New(tableUsage)
+	}
+
+	updated, pos := t.apply(content, r, c, offset)
+	return updated, pos, status, nil
+}
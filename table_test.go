@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTableRow(t *testing.T) {
+	tests := []struct {
+		line string
+		want []tableCell
+	}{
+		{"| a | b |", []tableCell{{"a", 1, 2}, {"b", 5, 6}}},
+		{"a | b", []tableCell{{"a", 0, 0}, {"b", 3, 4}}},
+		{"  | a |", []tableCell{{"a", 3, 4}}},
+		{"| a |  |", []tableCell{{"a", 1, 2}, {"", 5, 7}}},
+		{`| a \| b | c |`, []tableCell{{`a \| b`, 1, 2}, {"c", 10, 11}}},
+		{"|a|b", []tableCell{{"a", 1, 1}, {"b", 3, 3}}},
+		{"| :-- | --: |", []tableCell{{":--", 1, 2}, {"--:", 7, 8}}},
+	}
+	for _, tt := range tests {
+		if got := splitTableRow(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitTableRow(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFindTable(t *testing.T) {
+	doc := strings.Split(`text | with a pipe
+| a | b | c |
+|:--|:-:|--:|
+| 1 | 2 | 3 |
+| 4 | 5 | 6 | 7 |
+| 8 |
+
+after`, "\n")
+
+	tests := []struct {
+		name string
+		row  int
+		ok   bool
+	}{
+		{"paragraph above", 0, false},
+		{"header", 1, true},
+		{"delimiter", 2, true},
+		{"body", 4, true},
+		{"last row", 5, true},
+		{"blank line", 6, false},
+		{"after", 7, false},
+		{"out of range", 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, ok := findTable(doc, tt.row)
+			if ok != tt.ok {
+				t.Fatalf("findTable(row %d) found %v, want %v", tt.row, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if table.start != 1 || table.end != 5 {
+				t.Errorf("table spans lines %d-%d, want 1-5", table.start, table.end)
+			}
+			// The extra cell on row 4 becomes a fourth column, and short
+			// rows are filled out
+			wantAligns := []tableAlign{alignLeft, alignCenter, alignRight, alignNone}
+			if !reflect.DeepEqual(table.aligns, wantAligns) {
+				t.Errorf("aligns = %v, want %v", table.aligns, wantAligns)
+			}
+			wantRows := [][]string{{"a", "b", "c", ""}, {"1", "2", "3", ""}, {"4", "5", "6", "7"}, {"8", "", "", ""}}
+			if !reflect.DeepEqual(table.rows, wantRows) {
+				t.Errorf("rows = %q, want %q", table.rows, wantRows)
+			}
+		})
+	}
+}
+
+func TestFindTableNeedsMatchingDelimiter(t *testing.T) {
+	tests := []struct {
+		name, doc string
+	}{
+		{"no delimiter", "| a | b |\n| 1 | 2 |"},
+		{"too few delimiter cells", "| a | b |\n| --- |\n| 1 | 2 |"},
+		{"delimiter first", "|---|---|\n| a | b |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := findTable(strings.Split(tt.doc, "\n"), 0); ok {
+				t.Errorf("findTable(%q) found a table", tt.doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// taskDateRe matches the completion date toggleTask stamps on a task, in the
+// "✅ YYYY-MM-DD" form other Markdown task tools understand
+var taskDateRe = regexp.MustCompile(` ✅ \d{4}-\d{2}-\d{2}$`)
+
+// Task is a list item with a checkbox, "- [ ] open" or "- [x] done"
+type Task struct {
+	File string // empty for a task in the buffer
+	Line int    // 0-based
+	Done bool
+	Text string // the item's text after the checkbox
+}
+
+// toggleTask ticks or clears the checkbox of the task on line, and turns a
+// plain list item into an open task. A non-empty date is stamped on a task
+// as it is completed and removed again if it is reopened. ok is false when
+// line is not a list item.
+func toggleTask(line, date string) (string, bool) {
+	item, ok := parseListItem(line)
+	if !ok || item.marker == "" {
+		return line, false
+	}
+	box := len(item.lead) + len(item.marker) + len(item.space)
+
+	if !item.task {
+		return line[:len(item.lead)+len(item.marker)] + item.space + "[ ] " + line[min(box, len(line)):], true
+	}
+	if item.done {
+		return taskDateRe.ReplaceAllString(line[:box]+"[ ]"+line[box+3:], ""), true
+	}
+	line = line[:box] + "[x]" + line[box+3:]
+	if date != "" {
+		line = strings.TrimRight(line, " \t") + " ✅ " + date
+	}
+	return line, true
+}
+
+// toggleTaskAt toggles the task on pos's line. The cursor stays on the same
+// character of the item's text.
+func toggleTaskAt(content []string, pos Position, date string) ([]string, Position, bool) {
+	line := content[pos.row]
+	toggled, ok := toggleTask(line, date)
+	if !ok {
+		return content, pos, false
+	}
+
+	before, _ := parseListItem(line)
+	after, _ := parseListItem(toggled)
+	if pos.col >= before.length {
+		pos.col += after.length - before.length
+	}
+	pos.col = max(0, min(pos.col, len(toggled)))
+
+	updated := append([]string(nil), content...)
+	updated[pos.row] = toggled
+	return updated, pos, true
+}
+
+// taskDate is the completion date to stamp on tasks, empty unless the
+// task_done_date setting is on
+func taskDate(config Config) string {
+	if !config.TaskDoneDate {
+		return ""
+	}
+	return time.Now().Format("2006-01-02")
+}
+
+// countTasks returns how many of tasks are done
+func countTasks(tasks []Task) (done, total int) {
+	for _, t := range tasks {
+		if t.Done {
+			done++
+		}
+	}
+	return done, len(tasks)
+}
+
+// collectTasks returns the tasks in content outside code blocks and front
+// matter, marked as coming from file
+func collectTasks(content []string, file string) []Task {
+	doc := newDocStructure()
+	doc.update(content)
+
+	tasks := make([]Task, len(doc.tasks))
+	for i, t := range doc.tasks {
+		t.File = file
+		tasks[i] = t
+	}
+	return tasks
+}
+
+// collectDirTasks gathers the tasks of every Markdown file under root,
+// skipping hidden directories. Files are visited in lexical order.
+func collectDirTasks(root string) ([]Task, error) {
+	files, err := markdownFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, collectTasks(strings.Split(string(data), "\n"), path)...)
+	}
+	return tasks, nil
+}
+
+// markdownFiles lists the Markdown files under root in lexical order,
+// skipping hidden directories
+func markdownFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMarkdownFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func isMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
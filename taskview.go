@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// taskView lists the tasks of the buffer, or of every Markdown file under a
+// directory, in place of the editor
+type taskView struct {
+	open  bool
+	dir   string // empty while listing the buffer's tasks
+	found []Task // the directory's tasks, read when the view opened
+	sel   int
+}
+
+// taskList returns the tasks on show. The buffer's come from the document
+// index, so they follow edits made from the view.
+func (m Model) taskList() []Task {
+	if m.tasks.dir == "" {
+		return m.doc.tasks
+	}
+	return m.tasks.found
+}
+
+// openTaskView implements ":tasks [DIR]"
+func (m Model) openTaskView(args []string) (tea.Model, tea.Cmd) {
+	if len(args) > 1 {
+		m.setStatusMsg("Usage: :tasks [DIR]", true)
+		return m, nil
+	}
+
+	view := taskView{open: true}
+	if len(args) == 1 {
+		found, err := collectDirTasks(args[0])
+		if err != nil {
+			m.setStatusMsg("Tasks: "+err.Error(), true)
+			return m, nil
+		}
+		view.dir, view.found = args[0], found
+	}
+	m.tasks = view
+	m.locations.open = false
+	m.stats.open = false
+
+	tasks := m.taskList()
+	if len(tasks) == 0 {
+		m.tasks = taskView{}
+		m.setStatusMsg("No tasks found", false)
+		return m, nil
+	}
+
+	// Start on the buffer's first task at or below the cursor
+	if view.dir == "" {
+		for i, t := range tasks {
+			if t.Line >= m.cursor.row {
+				m.tasks.sel = i
+				break
+			}
+		}
+	}
+	return m, nil
+}
+
+// handleTaskViewMode moves the selection, toggles tasks in the buffer and
+// jumps to the selected task
+func (m Model) handleTaskViewMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	tasks := m.taskList()
+	m.tasks.sel = min(m.tasks.sel, max(0, len(tasks)-1))
+
+	switch msg.String() {
+	case "j", "down":
+		if m.tasks.sel < len(tasks)-1 {
+			m.tasks.sel++
+		}
+
+	case "k", "up":
+		if m.tasks.sel > 0 {
+			m.tasks.sel--
+		}
+
+	case "g", "home":
+		m.tasks.sel = 0
+
+	case "G", "end":
+		m.tasks.sel = max(0, len(tasks)-1)
+
+	case "enter":
+		if len(tasks) > 0 {
+			return m.jumpToTask(tasks[m.tasks.sel])
+		}
+
+	case "x", " ":
+		if len(tasks) == 0 {
+			break
+		}
+		if m.tasks.dir != "" {
+			m.setStatusMsg("Open the file (Enter) to change its tasks", true)
+			break
+		}
+		if m.readOnly {
+			m.setStatusMsg(readOnlyMsg, true)
+			break
+		}
+		row := tasks[m.tasks.sel].Line
+		m.content, _, _ = toggleTaskAt(m.content, Position{row: row, col: 0}, taskDate(m.config))
+		m.saved = false
+
+	case "esc", "q":
+		m.tasks = taskView{}
+
+	case ":":
+		m.mode = ModeCommand
+		m.commandLine = ""
+	}
+	return m, nil
+}
+
+// jumpToTask closes the view and puts the cursor on task's text, opening
+// its file first if it is not the buffer's
+func (m Model) jumpToTask(task Task) (tea.Model, tea.Cmd) {
+	jumped, ok := m.jumpToLocation(task.File, task.Line)
+	if !ok {
+		return jumped, nil
+	}
+
+	jumped.tasks = taskView{}
+	if item, ok := parseListItem(jumped.content[jumped.cursor.row]); ok {
+		jumped.cursor.col = item.length
+	}
+	return jumped, nil
+}
+
+// renderTaskView lists the tasks under a header with the counts. Done tasks
+// are dimmed.
+func (m Model) renderTaskView(height int) string {
+	tasks := m.taskList()
+	done, total := countTasks(tasks)
+
+	source := "this buffer"
+	if m.tasks.dir != "" {
+		source = m.tasks.dir
+	}
+	title := fmt.Sprintf("Tasks in %s: %d open, %d done", source, total-done, done)
+
+	rows := make([]listRow, len(tasks))
+	for i, t := range tasks {
+		box := "[ ] "
+		if t.Done {
+			box = "[x] "
+		}
+		file := t.File
+		if rel, err := filepath.Rel(m.tasks.dir, t.File); err == nil && file != "" {
+			file = rel
+		}
+		rows[i] = listRow{text: box + t.Text, location: lineLocation(file, t.Line), dim: t.Done}
+	}
+	return renderList(title, rows, m.tasks.sel, m.width, height)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const tocStopMarker = "<!-- tocstop -->"
+
+var (
+	// tocStartRe matches "<!-- toc -->", optionally with depth settings such
+	// as "<!-- toc min=2 max=3 -->"
+	tocStartRe   = regexp.MustCompile(`^\s*<!--\s*toc((?:\s+[a-z]+=\S*)*)\s*-->\s*$`)
+	tocStopRe    = regexp.MustCompile(`^\s*<!--\s*tocstop\s*-->\s*$`)
+	tocSettingRe = regexp.MustCompile(`([a-z]+)=(\S*)`)
+)
+
+// tocOptions selects the heading levels a table of contents lists
+type tocOptions struct {
+	MinLevel int
+	MaxLevel int
+}
+
+func defaultTOCOptions() tocOptions {
+	return tocOptions{MinLevel: 1, MaxLevel: 6}
+}
+
+func (o tocOptions) validate() error {
+	if o.MinLevel < 1 || o.MaxLevel > 6 || o.MinLevel > o.MaxLevel {
+		return fmt.Errorf("invalid heading levels %d-%d (levels run from 1 to 6)", o.MinLevel, o.MaxLevel)
+	}
+	return nil
+}
+
+// marker returns the opening marker, recording any non-default levels so
+// regenerating the TOC on save keeps them
+func (o tocOptions) marker() string {
+	settings := ""
+	if o.MinLevel != 1 {
+		settings += " min=" + strconv.Itoa(o.MinLevel)
+	}
+	if o.MaxLevel != 6 {
+		settings += " max=" + strconv.Itoa(o.MaxLevel)
+	}
+	return "<!-- toc" + settings + " -->"
+}
+
+// parseTOCSettings reads the "min=N max=N" settings of an opening marker
+func parseTOCSettings(settings string) (tocOptions, error) {
+	opts := defaultTOCOptions()
+	for _, match := range tocSettingRe.FindAllStringSubmatch(settings, -1) {
+		n, err := strconv.Atoi(match[2])
+		if err != nil {
+			return opts, fmt.Errorf("invalid toc setting %s", match[0])
+		}
+		switch match[1] {
+		case "min":
+			opts.MinLevel = n
+		case "max":
+			opts.MaxLevel = n
+		default:
+			return opts, fmt.Errorf("unknown toc setting %s", match[0])
+		}
+	}
+	return opts, opts.validate()
+}
+
+// tocBlock is a table of contents between "<!-- toc -->" and
+// "<!-- tocstop -->" markers; start and stop are the marker lines
+type tocBlock struct {
+	start int
+	stop  int
+	opts  tocOptions
+}
+
+// findTOC locates the first pair of TOC markers outside code blocks
+func findTOC(content []string) (tocBlock, bool, error) {
+	doc := newDocStructure()
+	doc.update(content)
+
+	for i, line := range content {
+		match := tocStartRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if _, inCode := doc.codeBlockAt(i); inCode {
+			continue
+		}
+
+		opts, err := parseTOCSettings(match[1])
+		if err != nil {
+			return tocBlock{}, false, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		for j := i + 1; j < len(content); j++ {
+			if tocStopRe.MatchString(content[j]) {
+				return tocBlock{start: i, stop: j, opts: opts}, true, nil
+			}
+		}
+		return tocBlock{}, false, fmt.Errorf("line %d: %s has no matching %s", i+1, strings.TrimSpace(line), tocStopMarker)
+	}
+	return tocBlock{}, false, nil
+}
+
+// generateTOC lists content's headings as nested links to their GitHub
+// anchors. Every heading takes part in numbering repeated anchors, including
+// the ones outside the selected levels, so the links resolve on GitHub.
+func generateTOC(content []string, opts tocOptions) []string {
+	doc := newDocStructure()
+	doc.update(content)
+
+	type entry struct {
+		level  int
+		title  string
+		anchor string
+	}
+	var entries []entry
+	top := 6
+	slugs := newSlugger()
+	for _, h := range doc.headings {
+		anchor := slugs.slug(h.Text)
+		if h.Level < opts.MinLevel || h.Level > opts.MaxLevel {
+			continue
+		}
+		entries = append(entries, entry{h.Level, h.Title(), anchor})
+		top = min(top, h.Level)
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`)
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = fmt.Sprintf("%s- [%s](#%s)", strings.Repeat("  ", e.level-top), escaper.Replace(e.title), e.anchor)
+	}
+	return lines
+}
+
+// tocLines is the complete block for opts: markers, blank lines and entries
+func tocLines(content []string, opts tocOptions) []string {
+	lines := []string{opts.marker(), ""}
+	if entries := generateTOC(content, opts); len(entries) > 0 {
+		lines = append(append(lines, entries...), "")
+	}
+	return append(lines, tocStopMarker)
+}
+
+// replace returns content with the block regenerated for opts, and row
+// moved along with the text around it
+func (b tocBlock) replace(content []string, opts tocOptions, row int) ([]string, int) {
+	lines := tocLines(content, opts)
+
+	updated := make([]string, 0, len(content)-(b.stop-b.start+1)+len(lines))
+	updated = append(updated, content[:b.start]...)
+	updated = append(updated, lines...)
+	updated = append(updated, content[b.stop+1:]...)
+
+	switch {
+	case row > b.stop:
+		row += len(lines) - (b.stop - b.start + 1)
+	case row > b.start:
+		row = b.start
+	}
+	return updated, row
+}
+
+// updateTOC regenerates the table of contents in content, if it has one,
+// and moves row along with the lines below it. Writing a file calls this so
+// the TOC never goes stale.
+func updateTOC(content []string, row int) (updated []string, newRow int, changed bool, err error) {
+	block, found, err := findTOC(content)
+	if !found {
+		return content, row, false, err
+	}
+
+	updated, newRow = block.replace(content, block.opts, row)
+	return updated, newRow, !slices.Equal(updated, content), nil
+}
+
+// tocCommand implements ":toc [MIN [MAX]]": it regenerates the document's
+// table of contents, or inserts a new one at row when there is none. It
+// returns the new content, the cursor row and a status message.
+func tocCommand(args []string, content []string, row int) ([]string, int, string, error) {
+	opts := defaultTOCOptions()
+	levels := []*int{&opts.MinLevel, &opts.MaxLevel}
+	if len(args) > len(levels) {
+		return nil, 0, "", fmt.Errorf("usage is :toc [MIN [MAX]]")
+	}
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("usage is :toc [MIN [MAX]]")
+		}
+		*levels[i] = n
+	}
+	if err := opts.validate(); err != nil {
+		return nil, 0, "", err
+	}
+
+	block, found, err := findTOC(content)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if found {
+		if len(args) == 0 {
+			opts = block.opts
+		}
+		updated, newRow := block.replace(content, opts, row)
+		return updated, newRow, "Table of contents updated", nil
+	}
+
+	// The TOC replaces an empty cursor line, or goes below the cursor line
+	at := row + 1
+	if strings.TrimSpace(content[row]) == "" {
+		at = row
+	}
+	updated := append([]string(nil), content[:at]...)
+	updated = append(updated, tocLines(content, opts)...)
+	updated = append(updated, content[row+1:]...)
+	return updated, at, "Table of contents inserted", nil
+}
+
+// runTOC implements `hani toc`, which prints a document's table of contents
+// or, with --write, regenerates the one between its markers
+func runTOC(args []string) int {
+	fs := newSubcommandFlags("toc", "hani toc [--min N] [--max N] [--write] FILE|-")
+	minLevel := fs.Int("min", 0, "lowest heading `level` to list (default: the marker's, or 1)")
+	maxLevel := fs.Int("max", 0, "deepest heading `level` to list (default: the marker's, or 6)")
+	write := fs.Bool("write", false, "update the TOC between the markers in FILE instead of printing it")
+	fs.BoolVar(write, "w", false, "shorthand for --write")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return flagExitCode(err)
+	}
+	if len(files) != 1 || (*write && files[0] == "-") {
+		fs.Usage()
+		return 2
+	}
+
+	source, err := readDocument(files[0])
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	content := strings.Split(string(source), "\n")
+
+	block, found, err := findTOC(content)
+	if err != nil {
+		return fatalf("%s: %v", files[0], err)
+	}
+
+	opts := defaultTOCOptions()
+	if found {
+		opts = block.opts
+	}
+	if *minLevel != 0 {
+		opts.MinLevel = *minLevel
+	}
+	if *maxLevel != 0 {
+		opts.MaxLevel = *maxLevel
+	}
+	if err := opts.validate(); err != nil {
+		return fatalf("%v", err)
+	}
+
+	if !*write {
+		for _, line := range generateTOC(content, opts) {
+			fmt.Println(line)
+		}
+		return 0
+	}
+
+	if !found {
+		return fatalf("%s: no <!-- toc --> marker (insert one, or run :toc in the editor)", files[0])
+	}
+	updated, _ := block.replace(content, opts, 0)
+	if slices.Equal(updated, content) {
+		return 0
+	}
+	if err := os.WriteFile(files[0], []byte(strings.Join(updated, "\n")), 0644); err != nil {
+		return fatalf("%v", err)
+	}
+	return 0
+}
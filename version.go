@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"runtime"
+	"strings"
 )
 
 // Version information - update these when releasing new versions
@@ -53,29 +54,87 @@ func PrintVersionShort() {
 
 // PrintHelp prints usage information
 func PrintHelp() {
-	fmt.Printf("Hani - A TUI Markdown Editor v%s\n\n", Version)
-	fmt.Println("USAGE:")
-	fmt.Println("  hani [filename]     Start editor with optional file")
-	fmt.Println("  hani -v, --version  Show version information")
-	fmt.Println("  hani -h, --help     Show this help message")
-	fmt.Println()
-	fmt.Println("EXAMPLES:")
-	fmt.Println("  hani                Create a new markdown file")
-	fmt.Println("  hani README.md      Edit an existing file")
-	fmt.Println("  hani document.md    Create or edit document.md")
-	fmt.Println()
-	fmt.Println("KEY BINDINGS:")
-	fmt.Println("  Tab/Shift+Tab       Switch between editor and preview")
-	fmt.Println("  Ctrl+S              Save file")
-	fmt.Println("  Ctrl+Q              Quit application")
-	fmt.Println("  i                   Enter insert mode")
-	fmt.Println("  Esc                 Return to normal mode")
-	fmt.Println("  h,j,k,l             Navigate (left, down, up, right)")
-	fmt.Println("  w,b,e               Word movements")
-	fmt.Println("  0,$                 Line beginning/end")
-	fmt.Println("  gg,G                File beginning/end")
-	fmt.Println("  o,O                 Insert new line")
-	fmt.Println("  x,dd                Delete operations")
-	fmt.Println()
-	fmt.Println("For more information, visit: https://github.com/your-username/hani")
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hani - A TUI Markdown Editor v%s\n\n", Version)
+	b.WriteString("USAGE:\n")
+	b.WriteString("  hani [options] [file]\n")
+	b.WriteString("                      Start editor with optional file (- reads stdin)\n")
+	b.WriteString("  hani render FILE|-  Print the rendered document and exit\n")
+	b.WriteString("                      (--width N, --style NAME, --no-color)\n")
+	b.WriteString("  hani export [--format FMT] [-o FILE] FILE|-\n")
+	b.WriteString("                      Convert the document (html, ansi, text, man)\n")
+	b.WriteString("  hani serve [--port N] FILE\n")
+	b.WriteString("                      Preview in the browser, reloading on save\n")
+	b.WriteString("  hani toc [--min N] [--max N] [--write] FILE|-\n")
+	b.WriteString("                      Print the table of contents, or update it in FILE\n")
+	b.WriteString("  hani check [--external] FILE|DIR...\n")
+	b.WriteString("                      Report broken links; exit status 1 if there are any\n")
+	b.WriteString("  hani lint [--format text|json] [--config FILE] FILE|DIR...\n")
+	b.WriteString("                      Check Markdown style rules (--rules lists them)\n")
+	b.WriteString("  hani fmt [-w | -l] [--wrap N] FILE...|-\n")
+	b.WriteString("                      Format documents (-w rewrites, -l lists changed files)\n")
+	b.WriteString("  hani stats [--json] FILE...|-\n")
+	b.WriteString("                      Print word counts, reading time and other statistics\n")
+	b.WriteString("\n")
+	writeFlagHelp(&b)
+	b.WriteString("\n")
+	b.WriteString("EXAMPLES:\n")
+	b.WriteString("  hani                Create a new markdown file\n")
+	b.WriteString("  hani README.md      Edit an existing file\n")
+	b.WriteString("  hani +42 notes.md   Open notes.md at line 42\n")
+	b.WriteString("  hani -R --split +/^## README.md\n")
+	b.WriteString("  hani render README.md | less -R\n")
+	b.WriteString("\n")
+	b.WriteString("KEY BINDINGS:\n")
+	b.WriteString("  Tab/Shift+Tab       Switch between editor and preview\n")
+	b.WriteString("                      (in insert mode: next/previous table cell, or\n")
+	b.WriteString("                      nest/un-nest a list item)\n")
+	b.WriteString("  Ctrl+S              Save file\n")
+	b.WriteString("  Ctrl+Q              Quit application\n")
+	b.WriteString("  i                   Enter insert mode\n")
+	b.WriteString("  Ctrl+N, Ctrl+P      Complete a word, link path or #anchor (insert mode)\n")
+	b.WriteString("  PREFIX Tab          Expand a snippet, then Tab/Shift+Tab between its fields\n")
+	b.WriteString("                      (snippets in ~/.config/hani/snippets; :snippets lists them)\n")
+	b.WriteString("  Esc                 Return to normal mode\n")
+	b.WriteString("  h,j,k,l             Navigate (left, down, up, right)\n")
+	b.WriteString("  w,b,e               Word movements\n")
+	b.WriteString("  0,$                 Line beginning/end\n")
+	b.WriteString("  gg,G                File beginning/end\n")
+	b.WriteString(outlineHelp)
+	b.WriteString("  gz, :zen            Toggle zen mode (the text alone, typewriter scrolling)\n")
+	b.WriteString("  :focus [MODE]       Dim all but the paragraph or sentence (or off) in zen mode\n")
+	b.WriteString("  o,O                 Insert new line\n")
+	b.WriteString("  x,dd                Delete operations\n")
+	b.WriteString("  gc                  Check or uncheck the task on the line\n")
+	b.WriteString("  gq{motion}, gqap    Rewrap lines, or the paragraph, to word_wrap\n")
+	b.WriteString("  ys{motion}{c}       Surround with c (*, _, `, b for **, l for a link, brackets);\n")
+	b.WriteString("                      cs{from}{to} changes and ds{c} deletes the delimiters\n")
+	b.WriteString("  :bold :italic :code Toggle the style of the word; :link [URL] links it\n")
+	b.WriteString("  :meta [KEY [VALUE]] List, show or set the front matter's fields\n")
+	b.WriteString("  gf, Enter           Follow the link under the cursor (Ctrl+O goes back;\n")
+	b.WriteString("                      in the preview, n/N select a link and Enter follows it)\n")
+	b.WriteString("  ]d, [d              Next/previous lint problem\n")
+	b.WriteString("  ]s, [s              Next/previous misspelled word (:set spell)\n")
+	b.WriteString("  z=, zg              Suggest spellings, add the word to the word list\n")
+	b.WriteString("  :w :q :wq           Write/quit from the command line\n")
+	b.WriteString("  :w! :w !cmd         Force a write, or pipe the buffer (:w !sudo tee %)\n")
+	b.WriteString("  :set ro / noro      Make the buffer read-only or editable\n")
+	b.WriteString("  :set [no]hardwrap   Break lines at word_wrap while typing\n")
+	b.WriteString("  :set [no]spell      Underline misspelled words (Hunspell dictionaries)\n")
+	b.WriteString("  :export FMT [file]  Export the buffer (html, ansi, text, man)\n")
+	b.WriteString("  :serve [port]       Live browser preview (:serve stop to end)\n")
+	b.WriteString("  :toc [min [max]]    Insert or update the table of contents\n")
+	b.WriteString("  :renumber           Fix the numbering of ordered lists\n")
+	b.WriteString("  :checklinks[!]      List broken links (! also requests URLs)\n")
+	b.WriteString("  :lint               List lint problems (rules from .markdownlint.json)\n")
+	b.WriteString("  :[range]Format      Format the buffer, or lines in range (:5,12Format)\n")
+	b.WriteString("  :stats              Word counts by section, links, images, sentences\n")
+	b.WriteString("  :goal [session] N   Set a word goal (:goal off clears it)\n")
+	b.WriteString("  :goals              Words written each day and the writing streak\n")
+	b.WriteString("  :tasks [dir]        List the buffer's tasks, or those of every note in dir\n")
+	b.WriteString("  :table CMD          Edit the table: format, row/col add|delete|...,\n")
+	b.WriteString("                      align left|center|right|none, sort [desc]\n")
+	b.WriteString("\n")
+	b.WriteString("For more information, visit: https://github.com/your-username/hani\n")
+	fmt.Print(b.String())
 }
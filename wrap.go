@@ -0,0 +1,212 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// reflowLines rewraps the paragraphs, list items and quotes on lines from
+// to to (inclusive) to width columns, as gq does. List items keep their
+// hanging indent and quotes their markers. Code blocks, tables, headings,
+// HTML blocks and the front matter are left as they are. The cursor moves
+// along with the text.
+func reflowLines(content []string, pos Position, from, to, width int) ([]string, Position) {
+	from, to = max(0, from), min(len(content)-1, to)
+	if from > to || width <= 0 {
+		return content, pos
+	}
+
+	// The formatter finds the blocks; with no markers set it leaves the
+	// text's style alone and only rewraps it
+	doc := newDocStructure()
+	doc.update(content)
+	if to+1 < len(content) && setextUnderlineRe.MatchString(content[to+1]) {
+		for _, h := range doc.headings {
+			if h.Line == to {
+				to++ // keep the heading with its underline
+			}
+		}
+	}
+	f := &formatter{lines: content[:to+1], doc: doc, opts: formatOptions{wrap: width}, cursor: Position{row: -1}}
+	if pos.row >= from && pos.row <= to {
+		f.cursor = pos
+	}
+	f.out = slices.Clone(content[:from])
+	_, frontMatterEnd, hasFrontMatter := f.doc.frontMatter()
+
+	for i := from; i <= to; {
+		line := content[i]
+		end := i + 1
+		switch {
+		case strings.TrimSpace(line) == "":
+
+		case hasFrontMatter && i <= frontMatterEnd:
+			end = min(frontMatterEnd, to) + 1
+
+		case f.inCodeBlock(i):
+			block, _ := f.doc.codeBlockAt(i)
+			end = min(block.end, to) + 1
+
+		case f.tableStart(i), isTableRow(line) && i > 0 && f.inTable(i):
+			for end <= to && isTableRow(content[end]) {
+				end++
+			}
+
+		case f.setextHeading(i):
+			end = min(i+2, to+1)
+
+		case htmlBlockRe.MatchString(line) && !linkAutoRe.MatchString(line):
+			for end <= to && strings.TrimSpace(content[end]) != "" {
+				end++
+			}
+
+		case f.indentedCode(i):
+			for end <= to && (strings.TrimSpace(content[end]) == "" || indentWidth(content[end]) >= 4) {
+				end++
+			}
+
+		case atxHeadingRe.MatchString(line), thematicBreakRe.MatchString(line), linkDefRe.MatchString(line):
+			f.inList = false
+
+		default:
+			i = f.paragraph(i)
+			continue
+		}
+		f.emit(i, end, content[i:end]...)
+		i = end
+	}
+
+	reflowed := slices.Concat(f.out, content[to+1:])
+	switch {
+	case f.cursor.row >= 0:
+		pos = f.moved
+	case pos.row > to:
+		pos.row += len(reflowed) - len(content)
+	}
+	pos.row = max(0, min(pos.row, len(reflowed)-1))
+	pos.col = max(0, min(pos.col, len(reflowed[pos.row])))
+	return reflowed, pos
+}
+
+// inCodeBlock reports whether line i is part of a fenced code block
+func (f *formatter) inCodeBlock(i int) bool {
+	_, ok := f.doc.codeBlockAt(i)
+	return ok
+}
+
+// inTable reports whether line i is a row below a table's header
+func (f *formatter) inTable(i int) bool {
+	for j := i - 1; j >= 0 && isTableRow(f.lines[j]); j-- {
+		if f.tableStart(j) {
+			return true
+		}
+	}
+	return false
+}
+
+// paragraphAround returns the lines of the paragraph on row, for gqap: the
+// run of non-blank lines around it, or the next one if row is blank.
+// Headings and fences end a paragraph like blank lines do.
+func paragraphAround(content []string, row int) (from, to int) {
+	breaks := func(i int) bool {
+		line := content[i]
+		return strings.TrimSpace(line) == "" || atxHeadingRe.MatchString(line) || fenceOpenRe.MatchString(line)
+	}
+	for row < len(content)-1 && breaks(row) {
+		row++
+	}
+	from, to = row, row
+	for from > 0 && !breaks(from-1) {
+		from--
+	}
+	for to < len(content)-1 && !breaks(to+1) {
+		to++
+	}
+	return from, to
+}
+
+// reflowMotion returns the lines a gq motion covers from the cursor: "q"
+// (gqq, or gqgq) the cursor's line, "j" and "k" one more line, "gg" and
+// "G" to the start or end of the document, and "ap" and "ip" the
+// paragraph. ok is false for motions gq doesn't take.
+func reflowMotion(content []string, pos Position, motion string) (from, to int, ok bool) {
+	row := pos.row
+	switch motion {
+	case "q", "gq":
+		return row, row, true
+	case "j", "down":
+		return row, min(row+1, len(content)-1), true
+	case "k", "up":
+		return max(row-1, 0), row, true
+	case "gg":
+		return 0, row, true
+	case "G":
+		return row, len(content) - 1, true
+	case "ap", "ip":
+		from, to = paragraphAround(content, row)
+		return from, to, true
+	}
+	return 0, 0, false
+}
+
+// hardWrap breaks the cursor's line once typing has taken it past width
+// columns: at the last space that leaves the text before it within width,
+// carrying the list item's hanging indent or the quote's markers over to
+// the new line. Lines in code blocks, tables, headings and the front
+// matter are never broken. ok is false when nothing was broken.
+func hardWrap(content []string, pos Position, width int) ([]string, Position, bool) {
+	line := content[pos.row]
+	if width <= 0 || ansi.StringWidth(line) <= width {
+		return content, pos, false
+	}
+	if atxHeadingRe.MatchString(line) || isTableRow(line) || linkDefRe.MatchString(line) || fenceOpenRe.MatchString(line) {
+		return content, pos, false
+	}
+	doc := newDocStructure()
+	doc.update(content)
+	if _, inCode := doc.codeBlockAt(pos.row); inCode {
+		return content, pos, false
+	}
+	if _, end, ok := doc.frontMatter(); ok && pos.row <= end {
+		return content, pos, false
+	}
+
+	prefix := line[:indentWidthBytes(line)]
+	if item, ok := parseListItem(line); ok {
+		prefix = line[:item.length]
+	}
+
+	// The last space the line can break at; a word that would start a
+	// block on a line of its own is kept with the one before
+	at := -1
+	for i := len(prefix); i < len(line); i++ {
+		if line[i] != ' ' || strings.TrimSpace(line[len(prefix):i]) == "" {
+			continue
+		}
+		if ansi.StringWidth(strings.TrimRight(line[:i], " ")) > width {
+			break
+		}
+		rest := strings.TrimLeft(line[i:], " ")
+		if word, _, _ := strings.Cut(rest, " "); rest != "" && !wrapUnsafeRe.MatchString(word) {
+			at = i
+		}
+	}
+	if at < 0 {
+		return content, pos, false
+	}
+
+	head := strings.TrimRight(line[:at], " ")
+	tailStart := at + len(line[at:]) - len(strings.TrimLeft(line[at:], " "))
+	cont := continuationPrefix(prefix)
+	wrapped := slices.Concat(content[:pos.row], []string{head, cont + line[tailStart:]}, content[pos.row+1:])
+
+	switch {
+	case pos.col >= tailStart:
+		pos = Position{row: pos.row + 1, col: len(cont) + pos.col - tailStart}
+	case pos.col > len(head):
+		pos = Position{row: pos.row + 1, col: len(cont)}
+	}
+	return wrapped, pos, true
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Focus modes for zen mode: what stays bright around the cursor
+const (
+	focusOff       = ""
+	focusParagraph = "paragraph"
+	focusSentence  = "sentence"
+)
+
+// parseFocus checks a focus mode given to ":focus" or as zen_focus; "off"
+// turns the dimming off
+func parseFocus(mode string) (string, error) {
+	switch mode {
+	case focusParagraph, focusSentence:
+		return mode, nil
+	case "off", focusOff:
+		return focusOff, nil
+	}
+	return focusOff, fmt.Errorf("unknown focus %q (paragraph, sentence or off)", mode)
+}
+
+// nextFocus is the focus mode ":focus" with no argument moves on to
+func nextFocus(mode string) string {
+	switch mode {
+	case focusOff:
+		return focusParagraph
+	case focusParagraph:
+		return focusSentence
+	}
+	return focusOff
+}
+
+// zenRow is one screen row in zen mode: bytes start to end of a line, or
+// nothing when line is -1
+type zenRow struct {
+	line       int
+	start, end int
+}
+
+// zenWidth is the width of the zen mode column: zen_width, or word_wrap,
+// within the terminal's width
+func zenWidth(config Config, termWidth int) int {
+	width := config.ZenWidth
+	if width <= 0 {
+		width = config.WordWrap
+	}
+	if width <= 0 {
+		width = DefaultWordWrap
+	}
+	return max(10, min(width, termWidth-2))
+}
+
+// wrapSegments splits line into rows of at most width columns, breaking
+// after spaces where it can, and returns where each row starts
+func wrapSegments(line string, width int) []int {
+	starts := []int{0}
+	start := 0
+	for ansi.StringWidth(line[start:]) > width {
+		cut, lastSpace := start, -1
+		for i, r := range line[start:] {
+			if ansi.StringWidth(line[start:start+i+len(string(r))]) > width {
+				break
+			}
+			cut = start + i + len(string(r))
+			if r == ' ' {
+				lastSpace = cut
+			}
+		}
+		if lastSpace > start {
+			cut = lastSpace
+		}
+		if cut == start {
+			break // a character wider than the column
+		}
+		start = cut
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// lineRows returns line i of content as zen rows of at most width columns
+func lineRows(content []string, i, width int) []zenRow {
+	starts := wrapSegments(content[i], width)
+	rows := make([]zenRow, len(starts))
+	for j, start := range starts {
+		end := len(content[i])
+		if j+1 < len(starts) {
+			end = starts[j+1]
+		}
+		rows[j] = zenRow{line: i, start: start, end: end}
+	}
+	return rows
+}
+
+// zenLayout lays content out in height rows of width columns for zen mode,
+// with long lines wrapped and the cursor's row in the middle of the screen
+// (typewriter scrolling). It returns the rows, the one the cursor is on and
+// the cursor's column in it.
+func zenLayout(content []string, pos Position, width, height int) (rows []zenRow, cursorRow, cursorCol int) {
+	current := lineRows(content, pos.row, width)
+	at := len(current) - 1
+	for j, row := range current {
+		if pos.col < row.end {
+			at = j
+			break
+		}
+	}
+	middle := (height - 1) / 2
+
+	// Rows above the cursor, nearest first
+	var above []zenRow
+	for j := at - 1; j >= 0; j-- {
+		above = append(above, current[j])
+	}
+	for i := pos.row - 1; i >= 0 && len(above) < middle; i-- {
+		segments := lineRows(content, i, width)
+		for j := len(segments) - 1; j >= 0; j-- {
+			above = append(above, segments[j])
+		}
+	}
+	above = above[:min(len(above), middle)]
+
+	rows = make([]zenRow, 0, height)
+	for range middle - len(above) {
+		rows = append(rows, zenRow{line: -1})
+	}
+	for j := len(above) - 1; j >= 0; j-- {
+		rows = append(rows, above[j])
+	}
+	rows = append(rows, current[at:]...)
+	for i := pos.row + 1; i < len(content) && len(rows) < height; i++ {
+		rows = append(rows, lineRows(content, i, width)...)
+	}
+	for len(rows) < height {
+		rows = append(rows, zenRow{line: -1})
+	}
+
+	cursor := current[at]
+	return rows[:height], middle, ansi.StringWidth(content[pos.row][cursor.start:min(pos.col, cursor.end)])
+}
+
+// focusSpan returns the text the focus dim leaves bright: the paragraph or
+// the sentence the cursor is in. ok is false when nothing is dimmed.
+func focusSpan(content []string, pos Position, mode string) (from, to Position, ok bool) {
+	if mode == focusOff {
+		return from, to, false
+	}
+	if strings.TrimSpace(content[pos.row]) == "" {
+		return pos, pos, true
+	}
+	first, last := paragraphAround(content, pos.row)
+	from, to = Position{row: first}, Position{row: last, col: len(content[last])}
+	if mode != focusSentence {
+		return from, to, true
+	}
+
+	// A sentence ends at ".", "!" or "?" before a space or a line break
+	ends := func(row, col int) bool {
+		line := content[row]
+		return strings.IndexByte(".!?", line[col]) >= 0 && (col+1 == len(line) || line[col+1] == ' ')
+	}
+back:
+	for row := pos.row; row >= first; row-- {
+		col := len(content[row]) - 1
+		if row == pos.row {
+			col = min(pos.col, len(content[row])) - 1
+		}
+		for ; col >= 0; col-- {
+			if ends(row, col) {
+				from = Position{row: row, col: col + 1}
+				break back
+			}
+		}
+	}
+forward:
+	for row := pos.row; row <= last; row++ {
+		col := 0
+		if row == pos.row {
+			col = pos.col
+		}
+		for ; col < len(content[row]); col++ {
+			if ends(row, col) {
+				to = Position{row: row, col: col + 1}
+				break forward
+			}
+		}
+	}
+
+	// Start the sentence at its first word
+	for from.col < len(content[from.row]) && content[from.row][from.col] == ' ' {
+		from.col++
+	}
+	if from.col == len(content[from.row]) && from.row < to.row {
+		from = Position{row: from.row + 1}
+	}
+	return from, to, true
+}
+
+// zenRowText draws a zen row: the text between from and to bright and the
+// rest dimmed with dim (when focused), and cursor before byte col (-1 for
+// none) of the line
+func zenRowText(content []string, row zenRow, from, to Position, focused bool, col int, cursor string, dim func(string) string) string {
+	if row.line < 0 {
+		return ""
+	}
+	line := content[row.line]
+
+	// The bright part of this row
+	brightFrom, brightTo := row.start, row.end
+	if focused {
+		brightFrom, brightTo = row.end, row.end
+		if row.line >= from.row && row.line <= to.row {
+			brightFrom, brightTo = row.start, row.end
+			if row.line == from.row {
+				brightFrom = max(brightFrom, from.col)
+			}
+			if row.line == to.row {
+				brightTo = min(brightTo, to.col)
+			}
+		}
+		brightFrom = min(max(brightFrom, row.start), row.end)
+		brightTo = min(max(brightTo, brightFrom), row.end)
+	}
+
+	var b strings.Builder
+	piece := func(start, end int) {
+		if start >= end {
+			return
+		}
+		text := line[start:end]
+		if start < brightFrom || start >= brightTo {
+			text = dim(text)
+		}
+		b.WriteString(text)
+	}
+	for _, cut := range [][2]int{{row.start, brightFrom}, {brightFrom, brightTo}, {brightTo, row.end}} {
+		if col >= cut[0] && col < cut[1] {
+			piece(cut[0], col)
+			b.WriteString(cursor)
+			piece(col, cut[1])
+			continue
+		}
+		piece(cut[0], cut[1])
+	}
+	if col == row.end && col == len(line) {
+		b.WriteString(cursor)
+	}
+	return b.String()
+}
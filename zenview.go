@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// zenActive reports whether the editor is drawn in zen mode: zen mode is on
+// and the editor has the screen, with no list view or outline in front
+func (m Model) zenActive() bool {
+	return m.zen && m.activeTab == TabEditor && !m.split &&
+		!m.tasks.open && !m.locations.open && !m.stats.open && !m.outlineFocus
+}
+
+// toggleZen turns zen mode on or off
+func (m Model) toggleZen() (tea.Model, tea.Cmd) {
+	m.zen = !m.zen
+	if m.zen {
+		m.setStatusMsg("Zen mode (gz or :zen to leave)", false)
+	} else {
+		m.setStatusMsg("Zen mode off", false)
+		m.adjustViewport()
+	}
+	return m, nil
+}
+
+// focusCommand implements ":focus [paragraph|sentence|off]"; with no
+// argument it moves on to the next mode
+func (m Model) focusCommand(args []string) (tea.Model, tea.Cmd) {
+	mode := nextFocus(m.focus)
+	if len(args) > 0 {
+		var err error
+		if mode, err = parseFocus(args[0]); err != nil {
+			m.setStatusMsg("Focus: "+err.Error(), true)
+			return m, nil
+		}
+	}
+	m.focus = mode
+	if mode == focusOff {
+		m.setStatusMsg("Focus off", false)
+	} else {
+		m.setStatusMsg("Focus: "+mode, false)
+	}
+	return m, nil
+}
+
+// renderZen draws zen mode: the text alone in a column in the middle of the
+// terminal, with the cursor's line kept in the middle of the screen. The
+// last line shows the command line and status messages, and is blank
+// otherwise.
+func (m Model) renderZen() string {
+	width := zenWidth(m.config, m.width)
+	height := m.height - 1
+	margin := strings.Repeat(" ", max(0, (m.width-width)/2))
+
+	rows, cursorRow, _ := zenLayout(m.content, m.cursor, width, height)
+	from, to, focused := focusSpan(m.content, m.cursor, m.focus)
+	dim := func(text string) string { return separatorStyle.Render(text) }
+
+	lines := make([]string, 0, height+1)
+	for i, row := range rows {
+		col := -1
+		if i == cursorRow && m.cursorBlink {
+			col = m.cursor.col
+		}
+		lines = append(lines, margin+zenRowText(m.content, row, from, to, focused, col, "█", dim))
+	}
+
+	bottom := ""
+	if m.mode == ModeCommand || m.statusMsg != "" && time.Now().Before(m.statusMsgTimeout) {
+		bottom = m.renderStatusBar()
+	}
+	return strings.Join(append(lines, bottom), "\n")
+}